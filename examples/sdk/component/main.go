@@ -1,62 +1,47 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	wasiExports "pkg/cli"
 	wasiSockets "pkg/sockets"
+	"time"
 )
 
-const numWorkers = 16
-
 type Component struct{}
 
 func (c *Component) Run() error {
-	socket, err := wasiSockets.NewSocket(wasiSockets.IpAddressFamilyIpv4)
-	if err != nil {
-		return err
-	}
-
-	if err := socket.Bind("0.0.0.0:6767"); err != nil {
-		return err
-	}
-
-	listener, err := socket.Listen()
+	listener, err := wasiSockets.ListenDual(6767)
 	if err != nil {
 		return err
 	}
-	defer listener.Close()
 
-	connCh := make(chan *wasiSockets.TcpSocket, numWorkers)
-
-	for range numWorkers {
-		go func() {
-			for conn := range connCh {
-				handleConn(conn)
-			}
-		}()
+	server := &wasiSockets.Server{
+		Handler:     wasiSockets.HandlerFunc(handleConn),
+		MaxConns:    64,
+		ReadTimeout: 10 * time.Second,
+		IdleTimeout: 30 * time.Second,
 	}
 
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			close(connCh)
-			if err == io.EOF {
-				return nil
-			}
-			return err
-		}
-		connCh <- conn
+	err = server.Serve(listener)
+	if errors.Is(err, io.EOF) {
+		return nil
 	}
+	return err
 }
 
-func handleConn(conn *wasiSockets.TcpSocket) {
+func handleConn(ctx context.Context, conn *wasiSockets.TcpSocket) {
 	body := "Hello from Go + wasi:sockets!"
 	response := fmt.Sprintf(
 		"HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
 		len(body), body,
 	)
-	conn.Write([]byte(response))
+	if _, err := conn.WriteContext(ctx, []byte(response)); err != nil {
+		return
+	}
+	conn.CloseWrite()
 }
 
 func init() {
@@ -1,11 +1,13 @@
 package cli
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"pkg/bindings/exports/export_wasi_cli_run"
-
-	// NOTE: The application will not compile unless the
-	// generated wit_exports are imported like this
-	_ "pkg/bindings/exports/wit_exports"
+	"pkg/internal/backend"
+	"runtime/debug"
+	"strings"
 
 	witTypes "go.bytecodealliance.org/pkg/wit/types"
 )
@@ -14,12 +16,96 @@ type Component interface {
 	Run() error
 }
 
+// Implemented by errors that choose the status code the component exits
+// with. Codes outside 1 to 255 exit with 1.
+type ExitCoder interface {
+	error
+	ExitCode() int
+}
+
+// Export c as the component's wasi:cli/run implementation.
+//
+// Before Run is called, os.Args, the process environment and Getwd are set up
+// from what the host provided through wasi:cli/environment, and the log
+// package's standard logger is pointed at Stderr. Once it returns, Stdout and
+// Stderr are flushed.
+//
+// If Run returns an error, or panics, the error is written to stderr and the
+// component exits unsuccessfully. When the error is, or wraps, an ExitCoder,
+// the component exits with its code.
 func RegisterExports(c Component) {
 	export_wasi_cli_run.Exports.Run = func() witTypes.Result[witTypes.Unit, witTypes.Unit] {
-		if err := c.Run(); err != nil {
-			panic(err)
-		}
+		return run(c)
+	}
+}
+
+func run(c Component) witTypes.Result[witTypes.Unit, witTypes.Unit] {
+	setupProcess(Env())
+	log.SetOutput(stderr)
 
+	err := runRecovered(c)
+	if closeErr := stdout.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		report(err)
+	}
+	if closeErr := stderr.close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
 		return witTypes.Ok[witTypes.Unit, witTypes.Unit](witTypes.Unit{})
 	}
+
+	var coder ExitCoder
+	if errors.As(err, &coder) {
+		backend.Current().ExitWithCode(exitCode(coder.ExitCode()))
+	}
+	return witTypes.Err[witTypes.Unit, witTypes.Unit](witTypes.Unit{})
+}
+
+// Call c.Run, turning a panic into an error.
+func runRecovered(c Component) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &panicError{value: v, stack: debug.Stack()}
+		}
+	}()
+	return c.Run()
+}
+
+// A panic recovered from Run, along with the stack it was raised on.
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.value, e.stack)
+}
+
+// Write err to stderr, followed by the usage of the command it is about, if
+// any. There is nowhere left to report a failure to, so write errors are
+// ignored.
+func report(err error) {
+	msg := err.Error()
+	if _, ok := err.(*panicError); !ok {
+		msg = "error: " + msg
+	}
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	stderr.Write([]byte(msg))
+
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) && usageErr.Command != nil {
+		usageErr.Command.usage(stderr)
+	}
+}
+
+func exitCode(code int) uint8 {
+	if code < 1 || code > 255 {
+		return 1
+	}
+	return uint8(code)
 }
@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"pkg/bindings/exports/export_wasi_cli_run"
+	"strings"
+	"testing"
+)
+
+type componentFunc func() error
+
+func (f componentFunc) Run() error { return f() }
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+func (e exitError) ExitCode() int { return int(e) }
+
+func TestRunSucceeds(t *testing.T) {
+	host := install(t)
+
+	RegisterExports(componentFunc(func() error { return nil }))
+	if result := export_wasi_cli_run.Run(); result.IsErr() {
+		t.Fatal("Run returned Err")
+	}
+	if stderr := host.Stderr(); len(stderr) != 0 {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if _, exited := host.ExitCode(); exited {
+		t.Error("component exited")
+	}
+}
+
+func TestRunReportsErrors(t *testing.T) {
+	host := install(t)
+
+	RegisterExports(componentFunc(func() error { return errors.New("no config file") }))
+	if result := export_wasi_cli_run.Run(); !result.IsErr() {
+		t.Fatal("Run returned Ok")
+	}
+	if got := string(host.Stderr()); got != "error: no config file\n" {
+		t.Errorf("stderr = %q", got)
+	}
+	if _, exited := host.ExitCode(); exited {
+		t.Error("component exited with a code for an error without one")
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	for _, test := range []struct {
+		code, expected int
+	}{
+		{2, 2},
+		{255, 255},
+		{0, 1},
+		{256, 1},
+		{-1, 1},
+	} {
+		host := install(t)
+
+		RegisterExports(componentFunc(func() error {
+			return fmt.Errorf("usage: %w", exitError(test.code))
+		}))
+		if result := export_wasi_cli_run.Run(); !result.IsErr() {
+			t.Errorf("code %d: Run returned Ok", test.code)
+		}
+		if code, exited := host.ExitCode(); !exited || code != test.expected {
+			t.Errorf("code %d: exited = %v with %d, expected %d", test.code, exited, code, test.expected)
+		}
+		if got, expected := string(host.Stderr()), fmt.Sprintf("error: usage: exit status %d\n", test.code); got != expected {
+			t.Errorf("code %d: stderr = %q, expected %q", test.code, got, expected)
+		}
+	}
+}
+
+func TestRunRecoversPanics(t *testing.T) {
+	host := install(t)
+
+	RegisterExports(componentFunc(func() error { panic("out of cheese") }))
+	if result := export_wasi_cli_run.Run(); !result.IsErr() {
+		t.Fatal("Run returned Ok")
+	}
+	stderr := string(host.Stderr())
+	if !strings.HasPrefix(stderr, "panic: out of cheese\n\ngoroutine ") {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if !strings.Contains(stderr, "TestRunRecoversPanics") {
+		t.Errorf("stderr does not include the stack: %q", stderr)
+	}
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+)
+
+// A command line program, made up of a tree of commands. A Command is a
+// Component, so the root of the tree can be passed to RegisterExports:
+//
+//	greet := &cli.Command{Name: "greet", Short: "Say hello", Action: ...}
+//	name := greet.FlagSet().String("name", "world", "who to greet")
+//	cli.RegisterExports(&cli.Command{Name: "tool", Commands: []*cli.Command{greet}})
+//
+// Each command parses its own flags, then either hands the remaining
+// arguments to the subcommand they name, or to its Action. "-h", "-help" and
+// "help [command]" print help to Stdout.
+type Command struct {
+	// How the command is invoked. The root's Name defaults to the base name
+	// of the program.
+	Name string
+
+	// The arguments the command takes, shown after its name in the usage
+	// line, e.g. "[flags] <file>...". Defaults to "[flags]" and "<command>"
+	// as appropriate.
+	Usage string
+
+	// A one-line description, shown in the parent's list of commands.
+	Short string
+
+	// The full help text. Defaults to Short.
+	Long string
+
+	// Called with the arguments left over once flags have been parsed, if
+	// they do not name a subcommand. A nil Action requires a subcommand.
+	Action func(cmd *Command, args []string) error
+
+	Commands []*Command
+
+	flags  *flag.FlagSet
+	parent *Command
+}
+
+// The command's flags, created on first use. Define flags on it before the
+// command runs. Parse errors are reported as *UsageError, whatever the
+// set's ErrorHandling.
+func (c *Command) FlagSet() *flag.FlagSet {
+	if c.flags == nil {
+		c.flags = flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	}
+	return c.flags
+}
+
+// Run the command with the arguments the component was started with, as
+// set up by RegisterExports.
+func (c *Command) Run() error {
+	var args []string
+	if len(os.Args) > 1 {
+		args = os.Args[1:]
+	}
+	return c.Execute(args)
+}
+
+// Run the command with args, which do not include the command's own name.
+func (c *Command) Execute(args []string) error {
+	flags := c.FlagSet()
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return c.help(Stdout())
+		}
+		return &UsageError{Command: c, Err: err}
+	}
+	args = flags.Args()
+
+	if len(args) > 0 {
+		if sub := c.lookup(args[0]); sub != nil {
+			return sub.Execute(args[1:])
+		}
+		if args[0] == "help" && len(c.Commands) > 0 {
+			return c.helpFor(args[1:])
+		}
+	}
+
+	switch {
+	case c.Action != nil:
+		err := c.Action(c, args)
+		var usageErr *UsageError
+		if errors.As(err, &usageErr) && usageErr.Command == nil {
+			usageErr.Command = c
+		}
+		return err
+	case len(args) > 0:
+		return &UsageError{Command: c, Err: fmt.Errorf("unknown command %q", args[0])}
+	default:
+		return &UsageError{Command: c, Err: errors.New("no command given")}
+	}
+}
+
+// The subcommand called name, if there is one.
+func (c *Command) lookup(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			sub.parent = c
+			return sub
+		}
+	}
+	return nil
+}
+
+// Handle "help [command]".
+func (c *Command) helpFor(names []string) error {
+	cmd := c
+	for _, name := range names {
+		sub := cmd.lookup(name)
+		if sub == nil {
+			return &UsageError{Command: cmd, Err: fmt.Errorf("unknown command %q", name)}
+		}
+		cmd = sub
+	}
+	return cmd.help(Stdout())
+}
+
+// The names of the command and its parents, as typed on the command line.
+func (c *Command) path() string {
+	name := c.Name
+	if c.parent == nil && name == "" && len(os.Args) > 0 {
+		name = path.Base(os.Args[0])
+	}
+	if c.parent == nil {
+		return name
+	}
+	return c.parent.path() + " " + name
+}
+
+// Write the command's usage line, its subcommands and its flags to w.
+func (c *Command) usage(w io.Writer) {
+	usage := c.Usage
+	if usage == "" {
+		var parts []string
+		if c.hasFlags() {
+			parts = append(parts, "[flags]")
+		}
+		if len(c.Commands) > 0 {
+			parts = append(parts, "<command>")
+		}
+		usage = strings.Join(parts, " ")
+	}
+	fmt.Fprintf(w, "Usage: %s\n", strings.TrimSpace(c.path()+" "+usage))
+
+	if len(c.Commands) > 0 {
+		fmt.Fprintf(w, "\nCommands:\n")
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, sub := range c.Commands {
+			fmt.Fprintf(tw, "  %s\t%s\n", sub.Name, sub.Short)
+		}
+		tw.Flush()
+	}
+
+	if c.hasFlags() {
+		fmt.Fprintf(w, "\nFlags:\n")
+		c.flags.SetOutput(w)
+		c.flags.PrintDefaults()
+		c.flags.SetOutput(io.Discard)
+	}
+}
+
+func (c *Command) hasFlags() bool {
+	has := false
+	if c.flags != nil {
+		c.flags.VisitAll(func(*flag.Flag) { has = true })
+	}
+	return has
+}
+
+// Write the full help for the command to w.
+func (c *Command) help(w *Writer) error {
+	long := c.Long
+	if long == "" {
+		long = c.Short
+	}
+	if long != "" {
+		fmt.Fprintf(w, "%s\n\n", strings.TrimSpace(long))
+	}
+	c.usage(w)
+	if len(c.Commands) > 0 {
+		fmt.Fprintf(w, "\nRun '%s help <command>' for more about a command.\n", c.path())
+	}
+	return w.Flush()
+}
+
+// Reports that a command was invoked incorrectly. The component exits with
+// code 2, after printing the error and the command's usage to stderr.
+type UsageError struct {
+	// The command that was invoked incorrectly. Errors returned by an Action
+	// without one are attributed to the command whose Action it was.
+	Command *Command
+
+	Err error
+}
+
+// Build a *UsageError for an Action to return.
+func UsageErrorf(format string, a ...any) error {
+	return &UsageError{Err: fmt.Errorf(format, a...)}
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UsageError) ExitCode() int {
+	return 2
+}
@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"pkg/bindings/exports/export_wasi_cli_run"
+	"pkg/fake"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// A small calculator with nested commands and flags.
+func newTool() *Command {
+	greet := &Command{
+		Name:  "greet",
+		Short: "Say hello",
+		Usage: "[flags] [greeting]",
+		Action: func(cmd *Command, args []string) error {
+			greeting := "hello"
+			if len(args) > 1 {
+				return UsageErrorf("too many arguments")
+			}
+			if len(args) == 1 {
+				greeting = args[0]
+			}
+			fmt.Fprintf(Stdout(), "%s, %s\n", greeting, cmd.FlagSet().Lookup("name").Value)
+			return nil
+		},
+	}
+	greet.FlagSet().String("name", "world", "who to greet")
+
+	add := &Command{
+		Name:  "add",
+		Short: "Add numbers",
+		Long:  "Add numbers together and print the sum.",
+		Action: func(_ *Command, args []string) error {
+			sum := 0
+			for _, arg := range args {
+				n, err := strconv.Atoi(arg)
+				if err != nil {
+					return err
+				}
+				sum += n
+			}
+			fmt.Fprintln(Stdout(), sum)
+			return nil
+		},
+	}
+
+	tool := &Command{
+		Name:  "tool",
+		Short: "A tool for testing commands",
+		Commands: []*Command{
+			greet,
+			{Name: "math", Short: "Do arithmetic", Commands: []*Command{add}},
+		},
+	}
+	tool.FlagSet().Bool("v", false, "verbose output")
+	return tool
+}
+
+// Run the tool through wasi:cli/run with args.
+func runTool(t *testing.T, args ...string) (host *fake.Host, ok bool) {
+	t.Helper()
+
+	host = install(t)
+	host.SetArguments(append([]string{"tool"}, args...)...)
+	RegisterExports(newTool())
+	return host, !export_wasi_cli_run.Run().IsErr()
+}
+
+func TestCommandDispatch(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		stdout string
+	}{
+		{[]string{"greet"}, "hello, world\n"},
+		{[]string{"-v", "greet", "-name", "gopher", "hi"}, "hi, gopher\n"},
+		{[]string{"math", "add", "1", "2", "3"}, "6\n"},
+	} {
+		host, ok := runTool(t, test.args...)
+		if !ok {
+			t.Errorf("%q: Run returned Err: %s", test.args, host.Stderr())
+		}
+		if got := string(host.Stdout()); got != test.stdout {
+			t.Errorf("%q: stdout = %q, expected %q", test.args, got, test.stdout)
+		}
+	}
+}
+
+func TestCommandUsageErrors(t *testing.T) {
+	for _, test := range []struct {
+		args   []string
+		stderr string
+	}{
+		{nil, "error: no command given\nUsage: tool [flags] <command>\n"},
+		{[]string{"frobnicate"}, "error: unknown command \"frobnicate\"\nUsage: tool [flags] <command>\n"},
+		{[]string{"math", "sub"}, "error: unknown command \"sub\"\nUsage: tool math <command>\n"},
+		{[]string{"greet", "-loud"}, "error: flag provided but not defined: -loud\nUsage: tool greet [flags] [greeting]\n"},
+		{[]string{"greet", "a", "b"}, "error: too many arguments\nUsage: tool greet [flags] [greeting]\n"},
+		{[]string{"help", "math", "mul"}, "error: unknown command \"mul\"\nUsage: tool math <command>\n"},
+	} {
+		host, ok := runTool(t, test.args...)
+		if ok {
+			t.Errorf("%q: Run returned Ok", test.args)
+		}
+		if code, exited := host.ExitCode(); !exited || code != 2 {
+			t.Errorf("%q: exited = %v with %d, expected 2", test.args, exited, code)
+		}
+		if got := string(host.Stderr()); !strings.HasPrefix(got, test.stderr) {
+			t.Errorf("%q: stderr = %q, expected it to start with %q", test.args, got, test.stderr)
+		}
+	}
+}
+
+func TestCommandActionErrors(t *testing.T) {
+	host, ok := runTool(t, "math", "add", "1", "two")
+	if ok {
+		t.Error("Run returned Ok")
+	}
+	if _, exited := host.ExitCode(); exited {
+		t.Error("component exited with a code for an error without one")
+	}
+	if got := string(host.Stderr()); !strings.HasPrefix(got, "error: strconv.Atoi: ") || strings.Contains(got, "Usage") {
+		t.Errorf("stderr = %q", got)
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	const toolHelp = `A tool for testing commands
+
+Usage: tool [flags] <command>
+
+Commands:
+  greet  Say hello
+  math   Do arithmetic
+
+Flags:
+  -v	verbose output
+
+Run 'tool help <command>' for more about a command.
+`
+	const addHelp = `Add numbers together and print the sum.
+
+Usage: tool math add
+`
+	const greetHelp = `Say hello
+
+Usage: tool greet [flags] [greeting]
+
+Flags:
+  -name string
+    	who to greet (default "world")
+`
+
+	for _, test := range []struct {
+		args []string
+		help string
+	}{
+		{[]string{"-h"}, toolHelp},
+		{[]string{"help"}, toolHelp},
+		{[]string{"math", "add", "-help"}, addHelp},
+		{[]string{"help", "math", "add"}, addHelp},
+		{[]string{"greet", "-h"}, greetHelp},
+	} {
+		host, ok := runTool(t, test.args...)
+		if !ok {
+			t.Errorf("%q: Run returned Err: %s", test.args, host.Stderr())
+		}
+		if got := string(host.Stdout()); got != test.help {
+			t.Errorf("%q: stdout = %q, expected %q", test.args, got, test.help)
+		}
+	}
+}
+
+func TestCommandExecute(t *testing.T) {
+	install(t)
+
+	err := newTool().Execute([]string{"greet", "x", "y"})
+	var usageErr *UsageError
+	if !errors.As(err, &usageErr) || usageErr.Command.Name != "greet" || usageErr.ExitCode() != 2 {
+		t.Errorf("Execute error = %#v", err)
+	}
+}
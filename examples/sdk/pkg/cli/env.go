@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path"
+	"pkg/internal/backend"
+	"strings"
+	"sync"
+)
+
+// The environment the host started the component with, read from
+// wasi:cli/environment.
+type Environment struct {
+	// The command-line arguments, usually including the program name.
+	Args []string
+
+	// Environment variables as key-value pairs, in the order the host
+	// provided them.
+	Vars [][2]string
+
+	// The initial working directory, or "" if the host did not provide one.
+	Cwd string
+}
+
+// Read the environment from the host. Unlike os.Args and os.Getenv, this
+// does not depend on the process state Run sets up, so it is safe to use
+// from code that must not touch globals. The result belongs to the caller.
+func Env() *Environment {
+	h := backend.Current()
+	cwd, _ := h.GetInitialCwd()
+	return &Environment{
+		Args: h.GetArguments(),
+		Vars: h.GetEnvironment(),
+		Cwd:  cwd,
+	}
+}
+
+// The value of the variable named key, and whether it is set. If the host
+// provided the same key more than once, the last value wins, as it does for
+// os.LookupEnv.
+func (e *Environment) LookupEnv(key string) (string, bool) {
+	for i := len(e.Vars) - 1; i >= 0; i-- {
+		if e.Vars[i][0] == key {
+			return e.Vars[i][1], true
+		}
+	}
+	return "", false
+}
+
+// The value of the variable named key, or "" if it is not set.
+func (e *Environment) Getenv(key string) string {
+	v, _ := e.LookupEnv(key)
+	return v
+}
+
+// The variables in "key=value" form, like os.Environ.
+func (e *Environment) Environ() []string {
+	environ := make([]string, len(e.Vars))
+	for i, kv := range e.Vars {
+		environ[i] = kv[0] + "=" + kv[1]
+	}
+	return environ
+}
+
+// Returned by Getwd and Abs when the host did not provide a working
+// directory.
+var ErrNoWorkingDirectory = errors.New("cli: the host did not provide a working directory")
+
+var (
+	cwdMu sync.RWMutex
+	cwd   string
+)
+
+// The working directory the host started the component in. wasi:cli has no
+// way to change it, so it stays the same for the life of the component.
+func Getwd() (string, error) {
+	cwdMu.RLock()
+	defer cwdMu.RUnlock()
+	if cwd == "" {
+		return "", ErrNoWorkingDirectory
+	}
+	return cwd, nil
+}
+
+// Make p absolute by joining it to the working directory, like
+// filepath.Abs. The result is cleaned.
+func Abs(p string) (string, error) {
+	if path.IsAbs(p) {
+		return path.Clean(p), nil
+	}
+	wd, err := Getwd()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(wd, p), nil
+}
+
+// Make the host's environment visible through os.Args, os.Getenv and Getwd
+// before Run is called, so that packages like flag work as they do natively.
+func setupProcess(env *Environment) {
+	os.Args = env.Args
+
+	os.Clearenv()
+	for _, kv := range env.Vars {
+		// The host may pass keys os.Setenv refuses, such as ones containing
+		// '='. Those are only available through Env.
+		if kv[0] != "" && !strings.Contains(kv[0], "=") {
+			os.Setenv(kv[0], kv[1])
+		}
+	}
+
+	cwdMu.Lock()
+	defer cwdMu.Unlock()
+	cwd = env.Cwd
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"pkg/bindings/exports/export_wasi_cli_run"
+	"pkg/fake"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Install a fake host, and restore the process state Run replaces and the
+// standard streams once the test is done.
+func install(t *testing.T) *fake.Host {
+	t.Helper()
+
+	args, environ := os.Args, os.Environ()
+	t.Cleanup(func() {
+		os.Args = args
+		os.Clearenv()
+		for _, kv := range environ {
+			if key, value, ok := strings.Cut(kv, "="); ok {
+				os.Setenv(key, value)
+			}
+		}
+
+		stdout.close()
+		stderr.close()
+		if stdinStream.stream != nil {
+			stdinStream.stream.Drop()
+			stdinStream.stream = nil
+		}
+		stdin.Reset(stdinStream)
+
+		cwdMu.Lock()
+		defer cwdMu.Unlock()
+		cwd = ""
+	})
+	return fake.Install(t)
+}
+
+func TestRunSetsUpProcess(t *testing.T) {
+	host := install(t)
+	host.SetArguments("greet", "-name", "gopher", "extra")
+	host.Setenv("HOME", "/home/gopher")
+	host.Setenv("LANG", "C")
+	host.SetInitialCwd("/home/gopher/src")
+
+	RegisterExports(componentFunc(func() error {
+		flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		name := flags.String("name", "", "")
+		if err := flags.Parse(os.Args[1:]); err != nil {
+			return err
+		}
+		if *name != "gopher" || !slices.Equal(flags.Args(), []string{"extra"}) {
+			t.Errorf("parsed name %q and args %q", *name, flags.Args())
+		}
+
+		if got := os.Getenv("HOME"); got != "/home/gopher" {
+			t.Errorf("HOME = %q", got)
+		}
+		if environ := os.Environ(); !slices.Equal(environ, []string{"HOME=/home/gopher", "LANG=C"}) {
+			t.Errorf("environ = %q", environ)
+		}
+
+		if wd, err := Getwd(); err != nil || wd != "/home/gopher/src" {
+			t.Errorf("Getwd() = %q, %v", wd, err)
+		}
+		if abs, err := Abs("../bin/./greet"); err != nil || abs != "/home/gopher/bin/greet" {
+			t.Errorf("Abs() = %q, %v", abs, err)
+		}
+		return nil
+	}))
+	if result := export_wasi_cli_run.Run(); result.IsErr() {
+		t.Fatalf("Run returned Err: %s", host.Stderr())
+	}
+}
+
+func TestNoWorkingDirectory(t *testing.T) {
+	install(t)
+	setupProcess(Env())
+
+	if _, err := Getwd(); !errors.Is(err, ErrNoWorkingDirectory) {
+		t.Errorf("Getwd error = %v", err)
+	}
+	if _, err := Abs("file"); !errors.Is(err, ErrNoWorkingDirectory) {
+		t.Errorf("Abs error = %v", err)
+	}
+	if abs, err := Abs("/etc/../tmp/"); err != nil || abs != "/tmp" {
+		t.Errorf("Abs() = %q, %v", abs, err)
+	}
+}
+
+func TestEnv(t *testing.T) {
+	host := install(t)
+	host.SetArguments("tool", "-v")
+	host.Setenv("PATH", "/bin")
+	host.Setenv("A=B", "c")
+	host.SetInitialCwd("/work")
+
+	env := Env()
+	if !slices.Equal(env.Args, []string{"tool", "-v"}) || env.Cwd != "/work" {
+		t.Errorf("Env() = %+v", env)
+	}
+	if v, ok := env.LookupEnv("A=B"); !ok || v != "c" {
+		t.Errorf("LookupEnv(A=B) = %q, %v", v, ok)
+	}
+	if v := env.Getenv("MISSING"); v != "" {
+		t.Errorf("Getenv(MISSING) = %q", v)
+	}
+	if environ := env.Environ(); !slices.Equal(environ, []string{"PATH=/bin", "A=B=c"}) {
+		t.Errorf("Environ() = %q", environ)
+	}
+
+	// Env reads the host directly, so it does not depend on Run having set
+	// up the process, and changes to the process do not affect it.
+	os.Setenv("PATH", "/usr/bin")
+	if v := Env().Getenv("PATH"); v != "/bin" {
+		t.Errorf("PATH = %q after os.Setenv", v)
+	}
+}
+
+func TestEnvLastValueWins(t *testing.T) {
+	env := &Environment{Vars: [][2]string{{"K", "1"}, {"K", "2"}}}
+	if v := env.Getenv("K"); v != "2" {
+		t.Errorf("Getenv(K) = %q", v)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build wasip1
+
+package cli
+
+import (
+	// NOTE: The application will not compile unless the
+	// generated wit_exports are imported like this
+	_ "pkg/bindings/exports/wit_exports"
+)
@@ -0,0 +1,250 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"pkg/internal/backend"
+	"sync"
+	"syscall"
+)
+
+// An error code reported by the host for one of the standard streams. Each
+// code is a sentinel error that can be matched with errors.Is, and also
+// matches the corresponding syscall.Errno.
+type ErrorCode uint8
+
+const (
+	_ ErrorCode = iota
+	ErrIo
+	ErrIllegalByteSequence
+	ErrPipe
+)
+
+var errorCodeMessages = [...]string{
+	ErrIo:                  "input/output error",
+	ErrIllegalByteSequence: "invalid or incomplete multibyte or wide character",
+	ErrPipe:                "broken pipe",
+}
+
+var errorCodeErrnos = [...]syscall.Errno{
+	ErrIo:                  syscall.EIO,
+	ErrIllegalByteSequence: syscall.EILSEQ,
+	ErrPipe:                syscall.EPIPE,
+}
+
+func (c ErrorCode) Error() string {
+	if int(c) < len(errorCodeMessages) && errorCodeMessages[c] != "" {
+		return errorCodeMessages[c]
+	}
+	return fmt.Sprintf("unknown error code %d", uint8(c))
+}
+
+// Reports whether the code matches target, either as itself or as its
+// syscall.Errno.
+func (c ErrorCode) Is(target error) bool {
+	errno := c.Errno()
+	return errno != 0 && (target == errno || errno.Is(target))
+}
+
+// The POSIX equivalent of the code, or 0 if there is none.
+func (c ErrorCode) Errno() syscall.Errno {
+	if int(c) < len(errorCodeErrnos) {
+		return errorCodeErrnos[c]
+	}
+	return 0
+}
+
+// One of the component's standard streams.
+type Stream uint8
+
+const (
+	StreamStdin Stream = iota
+	StreamStdout
+	StreamStderr
+)
+
+var streamNames = [...]string{
+	StreamStdin:  "stdin",
+	StreamStdout: "stdout",
+	StreamStderr: "stderr",
+}
+
+func (s Stream) String() string {
+	if int(s) < len(streamNames) {
+		return streamNames[s]
+	}
+	return fmt.Sprintf("stream %d", uint8(s))
+}
+
+// Describes a failed read or write on one of the standard streams. Err is an
+// ErrorCode when the host reported the failure.
+type StreamError struct {
+	// Either "read" or "write".
+	Op string
+
+	Stream Stream
+
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	return e.Op + " " + e.Stream.String() + ": " + e.Err.Error()
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// Convert an error returned by the host. Errors that do not carry a host
+// error code, such as those injected by a fake host, are passed through as
+// is.
+func fromHostError(err error) error {
+	var hostErr *backend.StdioError
+	if errors.As(err, &hostErr) {
+		return ErrorCode(hostErr.Code + 1)
+	}
+	return err
+}
+
+// A buffered writer over one of the host's output streams. The host stream
+// is opened by the first write that reaches it. Writers are safe to use from
+// multiple goroutines.
+type Writer struct {
+	stream    Stream
+	open      func(backend.Host) backend.OutputStream
+	autoFlush bool
+
+	mu   sync.Mutex
+	buf  *bufio.Writer
+	host backend.OutputStream
+}
+
+var (
+	stdout = newWriter(StreamStdout, false, backend.Host.GetStdout)
+	stderr = newWriter(StreamStderr, true, backend.Host.GetStderr)
+)
+
+func newWriter(stream Stream, autoFlush bool, open func(backend.Host) backend.OutputStream) *Writer {
+	w := &Writer{stream: stream, open: open, autoFlush: autoFlush}
+	w.buf = bufio.NewWriter(hostWriter{w})
+	return w
+}
+
+// The component's standard output. Writes are buffered until Flush is
+// called, the buffer fills up, or Run returns.
+//
+// os.Stdout writes to wasip1's file descriptor 1, which cannot be pointed at
+// a stream, so fmt.Println and the like do not go through Stdout. Use Print,
+// Printf and Println, or fmt.Fprint* with Stdout, instead.
+func Stdout() *Writer {
+	return stdout
+}
+
+// The component's standard error. Unlike Stdout, every write is flushed to
+// the host straight away. As with Stdout, os.Stderr does not go through it,
+// but RegisterExports points the log package's standard logger here before
+// calling Run.
+func Stderr() *Writer {
+	return stderr
+}
+
+// Like fmt.Print, but writes to Stdout.
+func Print(a ...any) (int, error) {
+	return fmt.Fprint(stdout, a...)
+}
+
+// Like fmt.Printf, but writes to Stdout.
+func Printf(format string, a ...any) (int, error) {
+	return fmt.Fprintf(stdout, format, a...)
+}
+
+// Like fmt.Println, but writes to Stdout.
+func Println(a ...any) (int, error) {
+	return fmt.Fprintln(stdout, a...)
+}
+
+// Write p, returning a *StreamError if it, or data buffered before it, could
+// not be written. Once a write has failed, later writes fail with the same
+// error.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n, err := w.buf.Write(p)
+	if err == nil && w.autoFlush {
+		err = w.buf.Flush()
+	}
+	return n, err
+}
+
+// Write any buffered data to the host.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Flush()
+}
+
+// Flush, then finish the host stream and wait for the host to report its
+// outcome. The next write opens a new stream, so the writer stays usable.
+func (w *Writer) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	err := w.buf.Flush()
+	if w.host != nil {
+		if closeErr := w.host.Close(); err == nil && closeErr != nil {
+			err = &StreamError{Op: "write", Stream: w.stream, Err: fromHostError(closeErr)}
+		}
+		w.host = nil
+	}
+	w.buf.Reset(hostWriter{w})
+	return err
+}
+
+// Writes the contents of a Writer's buffer to its host stream. Called with
+// the Writer's mutex held.
+type hostWriter struct {
+	w *Writer
+}
+
+func (hw hostWriter) Write(p []byte) (int, error) {
+	w := hw.w
+	if w.host == nil {
+		w.host = w.open(backend.Current())
+	}
+	n, err := w.host.Write(p)
+	if err != nil {
+		return n, &StreamError{Op: "write", Stream: w.stream, Err: fromHostError(err)}
+	}
+	return n, nil
+}
+
+// Reads from the host's stdin stream, opening it on the first read.
+type stdinReader struct {
+	stream backend.InputStream
+}
+
+func (r *stdinReader) Read(p []byte) (int, error) {
+	if r.stream == nil {
+		r.stream = backend.Current().GetStdin()
+	}
+	n, err := r.stream.Read(p)
+	if err != nil && err != io.EOF {
+		return n, &StreamError{Op: "read", Stream: StreamStdin, Err: fromHostError(err)}
+	}
+	return n, err
+}
+
+var (
+	stdinStream = &stdinReader{}
+	stdin       = bufio.NewReader(stdinStream)
+)
+
+// The component's standard input. Like any bufio.Reader, it must not be used
+// from multiple goroutines at once. Read errors other than io.EOF are
+// reported as *StreamError.
+func Stdin() *bufio.Reader {
+	return stdin
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"pkg/bindings/exports/export_wasi_cli_run"
+	"pkg/internal/backend"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestStdoutIsBufferedUntilRunReturns(t *testing.T) {
+	host := install(t)
+
+	RegisterExports(componentFunc(func() error {
+		fmt.Fprintln(Stdout(), "hello")
+		if got := host.Stdout(); len(got) != 0 {
+			t.Errorf("stdout = %q before flushing", got)
+		}
+		fmt.Fprintln(Stderr(), "warning")
+		if got := string(host.Stderr()); got != "warning\n" {
+			t.Errorf("stderr = %q, expected it to be written straight away", got)
+		}
+		return nil
+	}))
+	if result := export_wasi_cli_run.Run(); result.IsErr() {
+		t.Fatalf("Run returned Err: %s", host.Stderr())
+	}
+	if got := string(host.Stdout()); got != "hello\n" {
+		t.Errorf("stdout = %q", got)
+	}
+}
+
+func TestStdoutFlush(t *testing.T) {
+	host := install(t)
+
+	io.WriteString(Stdout(), "partial")
+	if err := Stdout().Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := string(host.Stdout()); got != "partial" {
+		t.Errorf("stdout = %q", got)
+	}
+}
+
+func TestStdoutConcurrentWrites(t *testing.T) {
+	host := install(t)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			for range 100 {
+				io.WriteString(Stdout(), "line\n")
+			}
+		})
+	}
+	wg.Wait()
+	Stdout().Flush()
+
+	if got := string(host.Stdout()); got != strings.Repeat("line\n", 800) {
+		t.Errorf("stdout has %d bytes, expected %d", len(got), 800*5)
+	}
+}
+
+func TestStdoutError(t *testing.T) {
+	host := install(t)
+	host.InjectError("stdout.write-via-stream", &backend.StdioError{Code: backend.StdioErrorCodePipe})
+
+	RegisterExports(componentFunc(func() error {
+		fmt.Fprintln(Stdout(), "lost")
+		return nil
+	}))
+	if result := export_wasi_cli_run.Run(); !result.IsErr() {
+		t.Fatal("Run returned Ok after stdout failed")
+	}
+	if got := string(host.Stderr()); got != "error: write stdout: broken pipe\n" {
+		t.Errorf("stderr = %q", got)
+	}
+
+	// The failed stream was closed when Run returned, so the next write
+	// goes through a new one.
+	io.WriteString(Stdout(), "found")
+	if err := Stdout().Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := string(host.Stdout()); got != "found" {
+		t.Errorf("stdout = %q", got)
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	host := install(t)
+	host.InjectError("stderr.write-via-stream", &backend.StdioError{Code: backend.StdioErrorCodeIo})
+
+	_, err := io.WriteString(Stderr(), "lost")
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Op != "write" || streamErr.Stream != StreamStderr {
+		t.Fatalf("Write error = %v", err)
+	}
+	if !errors.Is(err, ErrIo) || !errors.Is(err, syscall.EIO) {
+		t.Errorf("Write error %v does not match ErrIo and EIO", err)
+	}
+	if _, again := io.WriteString(Stderr(), "also lost"); !errors.Is(again, ErrIo) {
+		t.Errorf("second Write error = %v", again)
+	}
+
+	for _, test := range []struct {
+		code     backend.StdioErrorCode
+		expected ErrorCode
+		errno    syscall.Errno
+	}{
+		{backend.StdioErrorCodeIo, ErrIo, syscall.EIO},
+		{backend.StdioErrorCodeIllegalByteSequence, ErrIllegalByteSequence, syscall.EILSEQ},
+		{backend.StdioErrorCodePipe, ErrPipe, syscall.EPIPE},
+	} {
+		err := fromHostError(&backend.StdioError{Code: test.code})
+		if err != test.expected || !errors.Is(err, test.errno) {
+			t.Errorf("code %d: got %v, expected %v", test.code, err, test.expected)
+		}
+	}
+	if err := fromHostError(&backend.StdioError{Code: 3}); err.Error() != "unknown error code 4" {
+		t.Errorf("unknown code: got %v", err)
+	}
+}
+
+func TestStdin(t *testing.T) {
+	host := install(t)
+	host.SetStdin([]byte("first line\nsecond line\n"))
+
+	line, err := Stdin().ReadString('\n')
+	if err != nil || line != "first line\n" {
+		t.Fatalf("ReadString() = %q, %v", line, err)
+	}
+	rest, err := io.ReadAll(Stdin())
+	if err != nil || string(rest) != "second line\n" {
+		t.Errorf("ReadAll() = %q, %v", rest, err)
+	}
+}
+
+func TestStdinError(t *testing.T) {
+	host := install(t)
+	host.InjectError("stdin.read-via-stream", &backend.StdioError{Code: backend.StdioErrorCodeIllegalByteSequence})
+
+	_, err := Stdin().ReadByte()
+	var streamErr *StreamError
+	if !errors.As(err, &streamErr) || streamErr.Op != "read" || streamErr.Stream != StreamStdin || !errors.Is(err, ErrIllegalByteSequence) {
+		t.Errorf("ReadByte error = %v", err)
+	}
+}
+
+func TestPrintAndLogDuringRun(t *testing.T) {
+	host := install(t)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetFlags(flags)
+		log.SetOutput(os.Stderr)
+	})
+
+	RegisterExports(componentFunc(func() error {
+		Print("a", "b")
+		Printf(" %d", 1)
+		Println()
+		log.Print("to stderr")
+		return nil
+	}))
+	if result := export_wasi_cli_run.Run(); result.IsErr() {
+		t.Fatalf("Run returned Err: %s", host.Stderr())
+	}
+	if got := string(host.Stdout()); got != "ab 1\n" {
+		t.Errorf("stdout = %q", got)
+	}
+	if got := string(host.Stderr()); got != "to stderr\n" {
+		t.Errorf("stderr = %q", got)
+	}
+}
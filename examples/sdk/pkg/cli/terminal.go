@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"pkg/internal/backend"
+)
+
+// Which of the standard streams are attached to a terminal, as reported by
+// wasi:cli/terminal-stdin, terminal-stdout and terminal-stderr.
+type Terminal struct {
+	Stdin  bool
+	Stdout bool
+	Stderr bool
+
+	// Set when the environment asks for plain output, either through
+	// NO_COLOR (https://no-color.org) or TERM=dumb.
+	NoColor bool
+}
+
+// Ask the host which streams are terminals.
+func Terminals() Terminal {
+	h := backend.Current()
+	env := Env()
+	_, noColor := env.LookupEnv("NO_COLOR")
+	return Terminal{
+		Stdin:   h.GetTerminalStdin(),
+		Stdout:  h.GetTerminalStdout(),
+		Stderr:  h.GetTerminalStderr(),
+		NoColor: noColor || env.Getenv("TERM") == "dumb",
+	}
+}
+
+// Whether s is attached to a terminal.
+func (t Terminal) IsTerminal(s Stream) bool {
+	switch s {
+	case StreamStdin:
+		return t.Stdin
+	case StreamStdout:
+		return t.Stdout
+	case StreamStderr:
+		return t.Stderr
+	}
+	return false
+}
+
+// Whether output written to s should be colored: it is a terminal, and the
+// environment does not ask for plain output.
+func (t Terminal) Color(s Stream) bool {
+	return t.IsTerminal(s) && !t.NoColor
+}
+
+// Whether s is attached to a terminal.
+func IsTerminal(s Stream) bool {
+	return Terminals().IsTerminal(s)
+}
+
+// An ANSI text color or style.
+type Color uint8
+
+const (
+	ColorBold      Color = 1
+	ColorFaint     Color = 2
+	ColorUnderline Color = 4
+	ColorRed       Color = 31
+	ColorGreen     Color = 32
+	ColorYellow    Color = 33
+	ColorBlue      Color = 34
+	ColorMagenta   Color = 35
+	ColorCyan      Color = 36
+)
+
+// Formats text in color when Enabled, and as plain text otherwise.
+type Colorizer struct {
+	Enabled bool
+}
+
+// A Colorizer that is enabled only if output written to s should be colored.
+func NewColorizer(s Stream) Colorizer {
+	return Colorizer{Enabled: Terminals().Color(s)}
+}
+
+// Format a in the style of fmt.Sprint, wrapped in color.
+func (c Colorizer) Sprint(color Color, a ...any) string {
+	if !c.Enabled {
+		return fmt.Sprint(a...)
+	}
+	return fmt.Sprintf("\x1b[%dm%s\x1b[0m", color, fmt.Sprint(a...))
+}
+
+// Format according to format in the style of fmt.Sprintf, wrapped in color.
+func (c Colorizer) Sprintf(color Color, format string, a ...any) string {
+	return c.Sprint(color, fmt.Sprintf(format, a...))
+}
+
+// Write the result of Sprintf to w.
+func (c Colorizer) Fprintf(w io.Writer, color Color, format string, a ...any) (int, error) {
+	return io.WriteString(w, c.Sprintf(color, format, a...))
+}
@@ -0,0 +1,69 @@
+package cli
+
+import "testing"
+
+func TestTerminals(t *testing.T) {
+	host := install(t)
+
+	if terminal := Terminals(); terminal != (Terminal{}) {
+		t.Errorf("Terminals() = %+v without any terminals", terminal)
+	}
+
+	host.SetTerminals(true, false, true)
+	terminal := Terminals()
+	for _, test := range []struct {
+		stream          Stream
+		terminal, color bool
+	}{
+		{StreamStdin, true, true},
+		{StreamStdout, false, false},
+		{StreamStderr, true, true},
+		{Stream(3), false, false},
+	} {
+		if got := terminal.IsTerminal(test.stream); got != test.terminal {
+			t.Errorf("IsTerminal(%v) = %v", test.stream, got)
+		}
+		if got := IsTerminal(test.stream); got != test.terminal {
+			t.Errorf("package IsTerminal(%v) = %v", test.stream, got)
+		}
+		if got := terminal.Color(test.stream); got != test.color {
+			t.Errorf("Color(%v) = %v", test.stream, got)
+		}
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	for _, env := range [][2]string{{"NO_COLOR", ""}, {"NO_COLOR", "1"}, {"TERM", "dumb"}} {
+		host := install(t)
+		host.SetTerminals(false, true, true)
+		host.Setenv(env[0], env[1])
+
+		terminal := Terminals()
+		if !terminal.IsTerminal(StreamStdout) || terminal.Color(StreamStdout) || !terminal.NoColor {
+			t.Errorf("%s=%s: Terminals() = %+v", env[0], env[1], terminal)
+		}
+	}
+}
+
+func TestColorizer(t *testing.T) {
+	host := install(t)
+
+	if got := NewColorizer(StreamStdout).Sprint(ColorRed, "fail"); got != "fail" {
+		t.Errorf("Sprint() = %q when not on a terminal", got)
+	}
+
+	host.SetTerminals(false, true, false)
+	c := NewColorizer(StreamStdout)
+	if got := c.Sprintf(ColorGreen, "%d passed", 3); got != "\x1b[32m3 passed\x1b[0m" {
+		t.Errorf("Sprintf() = %q", got)
+	}
+	if NewColorizer(StreamStderr).Enabled {
+		t.Error("colorizer for stderr is enabled, but stderr is not a terminal")
+	}
+
+	c.Fprintf(Stdout(), ColorBold, "ok")
+	Stdout().Flush()
+	if got := string(host.Stdout()); got != "\x1b[1mok\x1b[0m" {
+		t.Errorf("stdout = %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package fake
+
+import "time"
+
+// A virtual clock, which only moves when told to. Waiting on it advances it
+// immediately, so code that sleeps through the host runs without delay.
+type clock struct {
+	monotonic uint64
+	system    time.Time
+}
+
+func (c *clock) init() {
+	c.system = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Move both clocks forward by d.
+func (h *Host) Advance(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.advance(d)
+}
+
+// Must be called with h.mu held.
+func (h *Host) advance(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	h.clock.monotonic += uint64(d)
+	h.clock.system = h.clock.system.Add(d)
+}
+
+// Set the wall clock, leaving the monotonic clock as it is. The wall clock
+// starts at midnight UTC on 1 January 2000.
+func (h *Host) SetTime(t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clock.system = t
+}
+
+// The current time on the wall clock.
+func (h *Host) now() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clock.system
+}
+
+func (h *Host) MonotonicNow() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clock.monotonic
+}
+
+func (h *Host) MonotonicResolution() time.Duration {
+	return time.Nanosecond
+}
+
+func (h *Host) WaitFor(d time.Duration) {
+	h.Advance(d)
+}
+
+func (h *Host) WaitUntil(mark uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if mark > h.clock.monotonic {
+		h.advance(time.Duration(mark - h.clock.monotonic))
+	}
+}
+
+func (h *Host) SystemNow() time.Time {
+	return h.now()
+}
+
+func (h *Host) SystemResolution() time.Duration {
+	return time.Nanosecond
+}
@@ -0,0 +1,53 @@
+package fake
+
+type environment struct {
+	vars [][2]string
+	args []string
+	cwd  string
+}
+
+// Set an environment variable, replacing any existing value.
+func (h *Host) Setenv(key, value string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, kv := range h.env.vars {
+		if kv[0] == key {
+			h.env.vars[i][1] = value
+			return
+		}
+	}
+	h.env.vars = append(h.env.vars, [2]string{key, value})
+}
+
+// Set the command-line arguments, including the program name.
+func (h *Host) SetArguments(args ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.env.args = append([]string(nil), args...)
+}
+
+// Set the initial working directory. An empty dir means there is none.
+func (h *Host) SetInitialCwd(dir string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.env.cwd = dir
+}
+
+func (h *Host) GetEnvironment() [][2]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([][2]string(nil), h.env.vars...)
+}
+
+func (h *Host) GetArguments() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.env.args...)
+}
+
+func (h *Host) GetInitialCwd() (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.env.cwd, h.env.cwd != ""
+}
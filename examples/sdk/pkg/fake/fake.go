@@ -0,0 +1,109 @@
+// Package fake provides an in-memory host for running the SDK natively.
+//
+// Outside of wasip1 there is no host, and sockets, name lookups and the
+// standard streams fail with an error. Tests install a fake host with
+// Install, which also lets them control it:
+//
+//	func TestHandler(t *testing.T) {
+//		host := fake.Install(t)
+//		host.Preopen("/data")
+//		host.WriteFile("/data/config.json", []byte(`{}`))
+//		host.InjectError("tcp-socket.connect", sockets.ErrConnectionRefused)
+//		...
+//	}
+//
+// The fake supports TCP and UDP between sockets on loopback addresses, name
+// lookup, a virtual clock, deterministic randomness, the CLI environment and
+// standard streams, and a filesystem reached through preopened directories.
+package fake
+
+import (
+	"net/netip"
+	"pkg/internal/backend"
+	"sync"
+	"testing"
+)
+
+// An in-memory host. All methods are safe to call from multiple goroutines.
+type Host struct {
+	mu       sync.Mutex
+	injected map[string][]error
+
+	net   network
+	names map[string][]netip.Addr
+	clock clock
+	rand  random
+	env   environment
+	stdio stdio
+	fs    filesystem
+}
+
+var (
+	_ backend.Host              = (*Host)(nil)
+	_ backend.NetworkInterfaces = (*Host)(nil)
+)
+
+// Create a fake host with an empty environment, no preopened directories,
+// and only "localhost" resolvable.
+func New() *Host {
+	h := &Host{
+		injected: make(map[string][]error),
+	}
+	h.net.init()
+	h.initNames()
+	h.clock.init()
+	h.rand.init()
+	h.fs.init(h)
+	return h
+}
+
+// Make a new fake host current for the rest of the test, restoring the
+// previous host afterwards. Tests that call Install must not run in parallel
+// with each other.
+func Install(t testing.TB) *Host {
+	t.Helper()
+
+	h := New()
+	prev := backend.Set(h)
+	t.Cleanup(func() { backend.Set(prev) })
+	return h
+}
+
+// Make the next call to op fail with err. Errors queue up, so injecting the
+// same op twice fails the next two calls. op names the host function the way
+// wasi:* does, for example "tcp-socket.connect", "udp-socket.send",
+// "resolve-addresses" or "descriptor.open-at". For streams, the
+// "tcp-socket.send", "tcp-socket.receive", "descriptor.read-via-stream" and
+// "descriptor.write-via-stream" errors fail the next read or write.
+//
+// err is returned to the SDK as is, so it is usually one of the SDK's own
+// errors, such as sockets.ErrConnectionReset.
+func (h *Host) InjectError(op string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.injected[op] = append(h.injected[op], err)
+}
+
+// Discard any injected errors that have not been returned yet.
+func (h *Host) ClearErrors() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.injected)
+}
+
+// Take the next error injected for op. Must be called with h.mu held.
+func (h *Host) takeError(op string) error {
+	errs := h.injected[op]
+	if len(errs) == 0 {
+		return nil
+	}
+	h.injected[op] = errs[1:]
+	return errs[0]
+}
+
+// Take the next error injected for op.
+func (h *Host) injectedError(op string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.takeError(op)
+}
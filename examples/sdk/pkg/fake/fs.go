@@ -0,0 +1,984 @@
+package fake
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"pkg/internal/backend"
+	"slices"
+	"strings"
+	"time"
+)
+
+// How many symbolic links a single path may pass through.
+const maxSymlinks = 40
+
+// The fake filesystem is one tree, rooted at "/", which the guest only sees
+// through the directories that have been preopened. Its state is guarded by
+// h.mu.
+type filesystem struct {
+	h        *Host
+	root     *node
+	nextIno  uint64
+	preopens []preopen
+}
+
+type preopen struct {
+	path  string
+	node  *node
+	flags backend.DescriptorFlags
+}
+
+// A file, directory or symbolic link.
+type node struct {
+	ino     uint64
+	typ     backend.DescriptorType
+	links   uint64
+	data    []byte
+	entries map[string]*node
+	target  string
+
+	atime, mtime, ctime time.Time
+
+	// The most recent hint given through Descriptor.Advise.
+	advice backend.Advice
+}
+
+func (fsys *filesystem) init(h *Host) {
+	fsys.h = h
+	fsys.root = fsys.newNode(backend.DescriptorTypeDirectory)
+}
+
+// Must be called with h.mu held.
+func (fsys *filesystem) newNode(typ backend.DescriptorType) *node {
+	fsys.nextIno++
+	now := fsys.h.clock.system
+	n := &node{ino: fsys.nextIno, typ: typ, links: 1, atime: now, mtime: now, ctime: now}
+	if typ == backend.DescriptorTypeDirectory {
+		n.entries = make(map[string]*node)
+	}
+	return n
+}
+
+// Make the directory at path, creating it if needed, available to the guest
+// under the same path.
+func (h *Host) Preopen(path string) error {
+	return h.preopen(path, backend.DescriptorFlagsRead|backend.DescriptorFlagsWrite|backend.DescriptorFlagsMutateDirectory)
+}
+
+// Like Preopen, but the guest may not modify anything beneath path.
+func (h *Host) PreopenReadOnly(path string) error {
+	return h.preopen(path, backend.DescriptorFlagsRead)
+}
+
+func (h *Host) preopen(path string, flags backend.DescriptorFlags) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dir, err := h.fs.mkdirAll("preopen", path)
+	if err != nil {
+		return err
+	}
+	h.fs.preopens = append(h.fs.preopens, preopen{path: clean(path), node: dir, flags: flags})
+	return nil
+}
+
+// Write a file, creating it and its parent directories if needed.
+func (h *Host) WriteFile(name string, data []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dir, err := h.fs.mkdirAll("write-file", path.Dir(clean(name)))
+	if err != nil {
+		return err
+	}
+	base := path.Base(clean(name))
+	n := dir.entries[base]
+	switch {
+	case n == nil:
+		n = h.fs.newNode(backend.DescriptorTypeRegularFile)
+		dir.entries[base] = n
+	case n.typ != backend.DescriptorTypeRegularFile:
+		return hostPathError("write-file", name, backend.FsErrorCodeIsDirectory)
+	}
+	n.data = append([]byte(nil), data...)
+	n.mtime = h.clock.system
+	return nil
+}
+
+// Read a file, following symbolic links.
+func (h *Host) ReadFile(name string) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.fs.lookup("read-file", name)
+	if err != nil {
+		return nil, err
+	}
+	if n.typ != backend.DescriptorTypeRegularFile {
+		return nil, hostPathError("read-file", name, backend.FsErrorCodeIsDirectory)
+	}
+	return append([]byte(nil), n.data...), nil
+}
+
+// Create a directory and any missing parents.
+func (h *Host) MkdirAll(path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.fs.mkdirAll("mkdir-all", path)
+	return err
+}
+
+// Create a symbolic link at name pointing to target, creating the parent
+// directories if needed.
+func (h *Host) Symlink(target, name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	dir, err := h.fs.mkdirAll("symlink", path.Dir(clean(name)))
+	if err != nil {
+		return err
+	}
+	base := path.Base(clean(name))
+	if dir.entries[base] != nil {
+		return hostPathError("symlink", name, backend.FsErrorCodeExist)
+	}
+	n := h.fs.newNode(backend.DescriptorTypeSymbolicLink)
+	n.target = target
+	dir.entries[base] = n
+	return nil
+}
+
+// Report the most recent hint given for a file through Descriptor.Advise.
+func (h *Host) Advice(name string) (backend.Advice, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	n, err := h.fs.lookup("advice", name)
+	if err != nil {
+		return 0, err
+	}
+	return n.advice, nil
+}
+
+// Find the node at an absolute host path. Must be called with h.mu held.
+func (fsys *filesystem) lookup(op, name string) (*node, error) {
+	r, err := fsys.walk(fsys.root, strings.TrimPrefix(clean(name), "/"), true)
+	if err != nil {
+		return nil, hostPathError(op, name, err.(*backend.FsError).Code)
+	}
+	if r.node == nil {
+		return nil, hostPathError(op, name, backend.FsErrorCodeNoEntry)
+	}
+	return r.node, nil
+}
+
+// Must be called with h.mu held.
+func (fsys *filesystem) mkdirAll(op, name string) (*node, error) {
+	dir := fsys.root
+	for part := range strings.SplitSeq(strings.TrimPrefix(clean(name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		child := dir.entries[part]
+		if child == nil {
+			child = fsys.newNode(backend.DescriptorTypeDirectory)
+			dir.entries[part] = child
+		}
+		if child.typ != backend.DescriptorTypeDirectory {
+			return nil, hostPathError(op, name, backend.FsErrorCodeNotDirectory)
+		}
+		dir = child
+	}
+	return dir, nil
+}
+
+func clean(name string) string {
+	return path.Clean("/" + name)
+}
+
+func hostPathError(op, name string, code backend.FsErrorCode) error {
+	return &fs.PathError{Op: op, Path: name, Err: &backend.FsError{Code: code}}
+}
+
+func (h *Host) GetDirectories() []backend.Preopen {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	preopens := make([]backend.Preopen, len(h.fs.preopens))
+	for i, p := range h.fs.preopens {
+		preopens[i] = backend.Preopen{
+			Descriptor: &descriptor{h: h, node: p.node, flags: p.flags},
+			Path:       p.path,
+		}
+	}
+	return preopens
+}
+
+// The result of resolving a path: the directory holding the final component,
+// the final component, and the node it names, if there is one. When the path
+// names the starting directory or one of its ancestors, dir is nil and name
+// is empty.
+type walkResult struct {
+	dir  *node
+	name string
+	node *node
+
+	// The directories passed through on the way, starting with the base.
+	stack []*node
+}
+
+// Resolve path relative to base. Like WASI, absolute paths and paths that
+// leave base are refused. Symbolic links are followed everywhere except in
+// the final component, unless follow is set. Must be called with h.mu held.
+func (fsys *filesystem) walk(base *node, path string, follow bool) (walkResult, error) {
+	if strings.HasPrefix(path, "/") {
+		return walkResult{}, fsError(backend.FsErrorCodeNotPermitted)
+	}
+
+	stack := []*node{base}
+	parts := strings.Split(path, "/")
+	hops := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if len(stack) == 1 {
+				return walkResult{}, fsError(backend.FsErrorCodeNotPermitted)
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		dir := stack[len(stack)-1]
+		if dir.typ != backend.DescriptorTypeDirectory {
+			return walkResult{}, fsError(backend.FsErrorCodeNotDirectory)
+		}
+
+		last := !slices.ContainsFunc(parts, func(p string) bool { return p != "" && p != "." })
+		child := dir.entries[part]
+		switch {
+		case child == nil && last:
+			return walkResult{dir: dir, name: part, stack: stack}, nil
+		case child == nil:
+			return walkResult{}, fsError(backend.FsErrorCodeNoEntry)
+		case child.typ == backend.DescriptorTypeSymbolicLink && (follow || !last):
+			hops++
+			if hops > maxSymlinks {
+				return walkResult{}, fsError(backend.FsErrorCodeLoop)
+			}
+			if strings.HasPrefix(child.target, "/") {
+				return walkResult{}, fsError(backend.FsErrorCodeNotPermitted)
+			}
+			parts = append(strings.Split(child.target, "/"), parts...)
+		case last:
+			return walkResult{dir: dir, name: part, node: child, stack: stack}, nil
+		default:
+			stack = append(stack, child)
+		}
+	}
+
+	return walkResult{node: stack[len(stack)-1], stack: stack[:len(stack)-1]}, nil
+}
+
+func fsError(code backend.FsErrorCode) error {
+	return &backend.FsError{Code: code}
+}
+
+// A descriptor for a node in the fake filesystem.
+type descriptor struct {
+	h       *Host
+	node    *node
+	flags   backend.DescriptorFlags
+	dropped bool
+}
+
+// Fail with an injected error, or if the descriptor has been dropped. Must be
+// called with h.mu held.
+func (d *descriptor) check(op string) error {
+	if err := d.h.takeError("descriptor." + op); err != nil {
+		return err
+	}
+	if d.dropped {
+		return fsError(backend.FsErrorCodeBadDescriptor)
+	}
+	return nil
+}
+
+// Like check, but also requires the descriptor to be a directory the guest
+// may modify.
+func (d *descriptor) checkMutate(op string) error {
+	if err := d.check(op); err != nil {
+		return err
+	}
+	if d.node.typ != backend.DescriptorTypeDirectory {
+		return fsError(backend.FsErrorCodeNotDirectory)
+	}
+	if d.flags&backend.DescriptorFlagsMutateDirectory == 0 {
+		return fsError(backend.FsErrorCodeReadOnly)
+	}
+	return nil
+}
+
+// Resolve path relative to a directory descriptor.
+func (d *descriptor) walk(path string, flags backend.PathFlags) (walkResult, error) {
+	if d.node.typ != backend.DescriptorTypeDirectory {
+		return walkResult{}, fsError(backend.FsErrorCodeNotDirectory)
+	}
+	return d.h.fs.walk(d.node, path, flags&backend.PathFlagsSymlinkFollow != 0)
+}
+
+func (d *descriptor) ReadViaStream(offset uint64) backend.InputStream {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := &fileReader{h: h, node: d.node, offset: offset}
+	switch {
+	case d.dropped:
+		s.err = fsError(backend.FsErrorCodeBadDescriptor)
+	case d.flags&backend.DescriptorFlagsRead == 0:
+		s.err = fsError(backend.FsErrorCodeBadDescriptor)
+	case d.node.typ == backend.DescriptorTypeDirectory:
+		s.err = fsError(backend.FsErrorCodeIsDirectory)
+	}
+	return s
+}
+
+func (d *descriptor) WriteViaStream(offset uint64) backend.OutputStream {
+	return d.writer(offset, false)
+}
+
+func (d *descriptor) AppendViaStream() backend.OutputStream {
+	return d.writer(0, true)
+}
+
+func (d *descriptor) writer(offset uint64, append bool) backend.OutputStream {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := &fileWriter{h: h, node: d.node, offset: offset, append: append}
+	switch {
+	case d.dropped:
+		s.err = fsError(backend.FsErrorCodeBadDescriptor)
+	case d.flags&backend.DescriptorFlagsWrite == 0:
+		s.err = fsError(backend.FsErrorCodeBadDescriptor)
+	case d.node.typ == backend.DescriptorTypeDirectory:
+		s.err = fsError(backend.FsErrorCodeIsDirectory)
+	}
+	return s
+}
+
+// Copy in, which must read a file of the same host, as the host would,
+// without handing the data to the caller.
+func (d *descriptor) SpliceViaStream(in backend.InputStream, offset uint64) (bool, error) {
+	r, ok := in.(*fileReader)
+	if !ok || r.h != d.h {
+		return false, nil
+	}
+
+	w := d.writer(offset, false)
+	if _, err := io.Copy(w, r); err != nil {
+		w.Drop()
+		return true, err
+	}
+	return true, w.Close()
+}
+
+func (d *descriptor) Advise(offset, length uint64, advice backend.Advice) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("advise"); err != nil {
+		return err
+	}
+	if advice > backend.AdviceNoReuse {
+		return fsError(backend.FsErrorCodeInvalid)
+	}
+	d.node.advice = advice
+	return nil
+}
+
+func (d *descriptor) SyncData() error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return d.check("sync-data")
+}
+
+func (d *descriptor) GetFlags() (backend.DescriptorFlags, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("get-flags"); err != nil {
+		return 0, err
+	}
+	return d.flags, nil
+}
+
+func (d *descriptor) GetType() (backend.DescriptorType, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("get-type"); err != nil {
+		return 0, err
+	}
+	return d.node.typ, nil
+}
+
+func (d *descriptor) SetSize(size uint64) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("set-size"); err != nil {
+		return err
+	}
+	if d.node.typ == backend.DescriptorTypeDirectory {
+		return fsError(backend.FsErrorCodeIsDirectory)
+	}
+	if d.flags&backend.DescriptorFlagsWrite == 0 {
+		return fsError(backend.FsErrorCodeBadDescriptor)
+	}
+	d.node.resize(size)
+	d.node.mtime = h.clock.system
+	return nil
+}
+
+func (n *node) resize(size uint64) {
+	if size <= uint64(len(n.data)) {
+		n.data = n.data[:size]
+		return
+	}
+	n.data = append(n.data, make([]byte, size-uint64(len(n.data)))...)
+}
+
+func (d *descriptor) SetTimes(atime, mtime backend.NewTimestamp) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("set-times"); err != nil {
+		return err
+	}
+	d.node.setTimes(atime, mtime, h.clock.system)
+	return nil
+}
+
+func (n *node) setTimes(atime, mtime backend.NewTimestamp, now time.Time) {
+	apply := func(t *time.Time, ts backend.NewTimestamp) {
+		switch ts.Kind {
+		case backend.NewTimestampNow:
+			*t = now
+		case backend.NewTimestampTimestamp:
+			*t = ts.Time
+		}
+	}
+	apply(&n.atime, atime)
+	apply(&n.mtime, mtime)
+	n.ctime = now
+}
+
+func (d *descriptor) ReadDirectory() backend.DirectoryEntryStream {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("read-directory"); err != nil {
+		return &directoryEntryStream{err: err}
+	}
+	if d.node.typ != backend.DescriptorTypeDirectory {
+		return &directoryEntryStream{err: fsError(backend.FsErrorCodeNotDirectory)}
+	}
+
+	// Entries are listed in name order, so tests see a stable order.
+	entries := make([]backend.DirectoryEntry, 0, len(d.node.entries))
+	for name, n := range d.node.entries {
+		entries = append(entries, backend.DirectoryEntry{Type: n.typ, Name: name})
+	}
+	slices.SortFunc(entries, func(a, b backend.DirectoryEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return &directoryEntryStream{entries: entries}
+}
+
+func (d *descriptor) Sync() error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return d.check("sync")
+}
+
+func (d *descriptor) CreateDirectoryAt(path string) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("create-directory-at"); err != nil {
+		return err
+	}
+	r, err := d.walk(path, 0)
+	if err != nil {
+		return err
+	}
+	if r.node != nil {
+		return fsError(backend.FsErrorCodeExist)
+	}
+	r.dir.entries[r.name] = h.fs.newNode(backend.DescriptorTypeDirectory)
+	r.dir.mtime = h.clock.system
+	return nil
+}
+
+func (d *descriptor) Stat() (backend.DescriptorStat, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("stat"); err != nil {
+		return backend.DescriptorStat{}, err
+	}
+	return d.node.stat(), nil
+}
+
+func (n *node) stat() backend.DescriptorStat {
+	return backend.DescriptorStat{
+		Type:                      n.typ,
+		LinkCount:                 n.links,
+		Size:                      n.size(),
+		DataAccessTimestamp:       n.atime,
+		DataModificationTimestamp: n.mtime,
+		StatusChangeTimestamp:     n.ctime,
+	}
+}
+
+func (n *node) size() uint64 {
+	if n.typ == backend.DescriptorTypeSymbolicLink {
+		return uint64(len(n.target))
+	}
+	return uint64(len(n.data))
+}
+
+func (d *descriptor) StatAt(flags backend.PathFlags, path string) (backend.DescriptorStat, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("stat-at"); err != nil {
+		return backend.DescriptorStat{}, err
+	}
+	n, err := d.find(path, flags)
+	if err != nil {
+		return backend.DescriptorStat{}, err
+	}
+	return n.stat(), nil
+}
+
+// Resolve path to an existing node.
+func (d *descriptor) find(path string, flags backend.PathFlags) (*node, error) {
+	r, err := d.walk(path, flags)
+	if err != nil {
+		return nil, err
+	}
+	if r.node == nil {
+		return nil, fsError(backend.FsErrorCodeNoEntry)
+	}
+	return r.node, nil
+}
+
+func (d *descriptor) SetTimesAt(flags backend.PathFlags, path string, atime, mtime backend.NewTimestamp) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("set-times-at"); err != nil {
+		return err
+	}
+	n, err := d.find(path, flags)
+	if err != nil {
+		return err
+	}
+	n.setTimes(atime, mtime, h.clock.system)
+	return nil
+}
+
+func (d *descriptor) LinkAt(oldFlags backend.PathFlags, oldPath string, newDir backend.Descriptor, newPath string) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("link-at"); err != nil {
+		return err
+	}
+	other, ok := newDir.(*descriptor)
+	if !ok || other.h != h || other.dropped {
+		return fsError(backend.FsErrorCodeBadDescriptor)
+	}
+	if other.flags&backend.DescriptorFlagsMutateDirectory == 0 {
+		return fsError(backend.FsErrorCodeReadOnly)
+	}
+
+	n, err := d.find(oldPath, oldFlags)
+	if err != nil {
+		return err
+	}
+	if n.typ == backend.DescriptorTypeDirectory {
+		return fsError(backend.FsErrorCodeNotPermitted)
+	}
+	r, err := other.walk(newPath, 0)
+	if err != nil {
+		return err
+	}
+	if r.node != nil {
+		return fsError(backend.FsErrorCodeExist)
+	}
+
+	r.dir.entries[r.name] = n
+	n.links++
+	n.ctime = h.clock.system
+	return nil
+}
+
+func (d *descriptor) OpenAt(flags backend.PathFlags, path string, openFlags backend.OpenFlags, descFlags backend.DescriptorFlags) (backend.Descriptor, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("open-at"); err != nil {
+		return nil, err
+	}
+	writes := descFlags&(backend.DescriptorFlagsWrite|backend.DescriptorFlagsMutateDirectory) != 0 ||
+		openFlags&(backend.OpenFlagsCreate|backend.OpenFlagsTruncate) != 0
+	if writes && d.flags&backend.DescriptorFlagsMutateDirectory == 0 {
+		return nil, fsError(backend.FsErrorCodeReadOnly)
+	}
+
+	r, err := d.walk(path, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	n := r.node
+	switch {
+	case n == nil && openFlags&backend.OpenFlagsCreate == 0:
+		return nil, fsError(backend.FsErrorCodeNoEntry)
+	case n == nil && openFlags&backend.OpenFlagsDirectory != 0:
+		return nil, fsError(backend.FsErrorCodeInvalid)
+	case n == nil:
+		n = h.fs.newNode(backend.DescriptorTypeRegularFile)
+		r.dir.entries[r.name] = n
+		r.dir.mtime = h.clock.system
+	case openFlags&backend.OpenFlagsCreate != 0 && openFlags&backend.OpenFlagsExclusive != 0:
+		return nil, fsError(backend.FsErrorCodeExist)
+	case n.typ == backend.DescriptorTypeSymbolicLink:
+		return nil, fsError(backend.FsErrorCodeLoop)
+	case openFlags&backend.OpenFlagsDirectory != 0 && n.typ != backend.DescriptorTypeDirectory:
+		return nil, fsError(backend.FsErrorCodeNotDirectory)
+	case n.typ == backend.DescriptorTypeDirectory && descFlags&backend.DescriptorFlagsWrite != 0:
+		return nil, fsError(backend.FsErrorCodeIsDirectory)
+	}
+
+	if openFlags&backend.OpenFlagsTruncate != 0 {
+		if n.typ == backend.DescriptorTypeDirectory {
+			return nil, fsError(backend.FsErrorCodeIsDirectory)
+		}
+		n.data = nil
+		n.mtime = h.clock.system
+	}
+	return &descriptor{h: h, node: n, flags: descFlags}, nil
+}
+
+func (d *descriptor) ReadlinkAt(path string) (string, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("readlink-at"); err != nil {
+		return "", err
+	}
+	n, err := d.find(path, 0)
+	if err != nil {
+		return "", err
+	}
+	if n.typ != backend.DescriptorTypeSymbolicLink {
+		return "", fsError(backend.FsErrorCodeInvalid)
+	}
+	return n.target, nil
+}
+
+func (d *descriptor) RemoveDirectoryAt(path string) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("remove-directory-at"); err != nil {
+		return err
+	}
+	r, err := d.walk(path, 0)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.dir == nil:
+		return fsError(backend.FsErrorCodeInvalid)
+	case r.node == nil:
+		return fsError(backend.FsErrorCodeNoEntry)
+	case r.node.typ != backend.DescriptorTypeDirectory:
+		return fsError(backend.FsErrorCodeNotDirectory)
+	case len(r.node.entries) > 0:
+		return fsError(backend.FsErrorCodeNotEmpty)
+	}
+	delete(r.dir.entries, r.name)
+	r.dir.mtime = h.clock.system
+	return nil
+}
+
+func (d *descriptor) RenameAt(oldPath string, newDir backend.Descriptor, newPath string) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("rename-at"); err != nil {
+		return err
+	}
+	other, ok := newDir.(*descriptor)
+	if !ok || other.h != h || other.dropped {
+		return fsError(backend.FsErrorCodeBadDescriptor)
+	}
+	if other.flags&backend.DescriptorFlagsMutateDirectory == 0 {
+		return fsError(backend.FsErrorCodeReadOnly)
+	}
+
+	from, err := d.walk(oldPath, 0)
+	if err != nil {
+		return err
+	}
+	to, err := other.walk(newPath, 0)
+	if err != nil {
+		return err
+	}
+	switch {
+	case from.dir == nil || to.dir == nil:
+		return fsError(backend.FsErrorCodeInvalid)
+	case from.node == nil:
+		return fsError(backend.FsErrorCodeNoEntry)
+	case from.node == to.node:
+		return nil
+	case slices.Contains(to.stack, from.node) || to.dir == from.node:
+		// A directory cannot be moved inside itself.
+		return fsError(backend.FsErrorCodeInvalid)
+	}
+
+	isDir := from.node.typ == backend.DescriptorTypeDirectory
+	if to.node != nil {
+		switch {
+		case isDir && to.node.typ != backend.DescriptorTypeDirectory:
+			return fsError(backend.FsErrorCodeNotDirectory)
+		case !isDir && to.node.typ == backend.DescriptorTypeDirectory:
+			return fsError(backend.FsErrorCodeIsDirectory)
+		case len(to.node.entries) > 0:
+			return fsError(backend.FsErrorCodeNotEmpty)
+		}
+		to.node.links--
+	}
+
+	delete(from.dir.entries, from.name)
+	to.dir.entries[to.name] = from.node
+	from.dir.mtime = h.clock.system
+	to.dir.mtime = h.clock.system
+	from.node.ctime = h.clock.system
+	return nil
+}
+
+func (d *descriptor) SymlinkAt(oldPath, newPath string) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("symlink-at"); err != nil {
+		return err
+	}
+	if strings.HasPrefix(oldPath, "/") {
+		return fsError(backend.FsErrorCodeNotPermitted)
+	}
+	r, err := d.walk(newPath, 0)
+	if err != nil {
+		return err
+	}
+	if r.dir == nil || r.node != nil {
+		return fsError(backend.FsErrorCodeExist)
+	}
+
+	n := h.fs.newNode(backend.DescriptorTypeSymbolicLink)
+	n.target = oldPath
+	r.dir.entries[r.name] = n
+	r.dir.mtime = h.clock.system
+	return nil
+}
+
+func (d *descriptor) UnlinkFileAt(path string) error {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.checkMutate("unlink-file-at"); err != nil {
+		return err
+	}
+	r, err := d.walk(path, 0)
+	if err != nil {
+		return err
+	}
+	switch {
+	case r.dir == nil:
+		return fsError(backend.FsErrorCodeIsDirectory)
+	case r.node == nil:
+		return fsError(backend.FsErrorCodeNoEntry)
+	case r.node.typ == backend.DescriptorTypeDirectory:
+		return fsError(backend.FsErrorCodeIsDirectory)
+	}
+	delete(r.dir.entries, r.name)
+	r.node.links--
+	r.node.ctime = h.clock.system
+	r.dir.mtime = h.clock.system
+	return nil
+}
+
+func (d *descriptor) IsSameObject(other backend.Descriptor) bool {
+	o, ok := other.(*descriptor)
+	return ok && o.h == d.h && o.node == d.node
+}
+
+func (d *descriptor) MetadataHash() (backend.MetadataHashValue, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("metadata-hash"); err != nil {
+		return backend.MetadataHashValue{}, err
+	}
+	return d.node.hash(), nil
+}
+
+func (d *descriptor) MetadataHashAt(flags backend.PathFlags, path string) (backend.MetadataHashValue, error) {
+	h := d.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := d.check("metadata-hash-at"); err != nil {
+		return backend.MetadataHashValue{}, err
+	}
+	n, err := d.find(path, flags)
+	if err != nil {
+		return backend.MetadataHashValue{}, err
+	}
+	return n.hash(), nil
+}
+
+func (n *node) hash() backend.MetadataHashValue {
+	return backend.MetadataHashValue{Lower: n.ino, Upper: uint64(n.mtime.UnixNano())}
+}
+
+func (d *descriptor) Drop() {
+	d.h.mu.Lock()
+	defer d.h.mu.Unlock()
+	d.dropped = true
+}
+
+// Reads a file from an offset. Later writes to the file are visible to it.
+type fileReader struct {
+	h      *Host
+	node   *node
+	offset uint64
+	err    error
+}
+
+func (s *fileReader) Read(b []byte) (int, error) {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+	if err := h.takeError("descriptor.read-via-stream"); err != nil {
+		return 0, err
+	}
+	if s.offset >= uint64(len(s.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, s.node.data[s.offset:])
+	s.offset += uint64(n)
+	s.node.atime = h.clock.system
+	return n, nil
+}
+
+func (s *fileReader) Drop() {}
+
+// Writes a file from an offset, or at its end when appending.
+type fileWriter struct {
+	h      *Host
+	node   *node
+	offset uint64
+	append bool
+	err    error
+}
+
+func (s *fileWriter) Write(b []byte) (int, error) {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+	if err := h.takeError("descriptor.write-via-stream"); err != nil {
+		return 0, err
+	}
+
+	n := s.node
+	offset := s.offset
+	if s.append {
+		offset = uint64(len(n.data))
+	}
+	if end := offset + uint64(len(b)); end > uint64(len(n.data)) {
+		n.resize(end)
+	}
+	copy(n.data[offset:], b)
+	s.offset = offset + uint64(len(b))
+	n.mtime = h.clock.system
+	return len(b), nil
+}
+
+func (s *fileWriter) Close() error {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	return s.err
+}
+
+func (s *fileWriter) Drop() {}
+
+type directoryEntryStream struct {
+	entries []backend.DirectoryEntry
+	err     error
+}
+
+func (s *directoryEntryStream) Next() (backend.DirectoryEntry, error) {
+	if s.err != nil {
+		return backend.DirectoryEntry{}, s.err
+	}
+	if len(s.entries) == 0 {
+		return backend.DirectoryEntry{}, io.EOF
+	}
+	e := s.entries[0]
+	s.entries = s.entries[1:]
+	return e, nil
+}
+
+func (s *directoryEntryStream) Drop() {}
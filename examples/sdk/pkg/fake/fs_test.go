@@ -0,0 +1,114 @@
+package fake
+
+import (
+	"errors"
+	"io"
+	"pkg/internal/backend"
+	"testing"
+)
+
+// The descriptor of the only preopened directory.
+func preopened(t *testing.T, h *Host) backend.Descriptor {
+	t.Helper()
+
+	dirs := h.GetDirectories()
+	if len(dirs) != 1 {
+		t.Fatalf("GetDirectories() returned %d preopens, expected 1", len(dirs))
+	}
+	return dirs[0].Descriptor
+}
+
+func expectFsError(t *testing.T, err error, code backend.FsErrorCode) {
+	t.Helper()
+
+	var fsErr *backend.FsError
+	if !errors.As(err, &fsErr) || fsErr.Code != code {
+		t.Errorf("error = %v, expected filesystem error %d", err, code)
+	}
+}
+
+func TestOpenReadWrite(t *testing.T) {
+	h := New()
+	h.Preopen("/data")
+	h.WriteFile("/data/dir/hello.txt", []byte("hello"))
+	dir := preopened(t, h)
+
+	f, err := dir.OpenAt(0, "dir/hello.txt", 0, backend.DescriptorFlagsRead|backend.DescriptorFlagsWrite)
+	if err != nil {
+		t.Fatalf("OpenAt: %v", err)
+	}
+	w := f.AppendViaStream()
+	if _, err := w.Write([]byte(", world")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	got, err := io.ReadAll(f.ReadViaStream(0))
+	if err != nil || string(got) != "hello, world" {
+		t.Errorf("read %q, %v, expected \"hello, world\"", got, err)
+	}
+	if data, _ := h.ReadFile("/data/dir/hello.txt"); string(data) != "hello, world" {
+		t.Errorf("host sees %q", data)
+	}
+
+	f.Drop()
+	_, err = f.Stat()
+	expectFsError(t, err, backend.FsErrorCodeBadDescriptor)
+}
+
+func TestPathsCannotEscape(t *testing.T) {
+	h := New()
+	h.Preopen("/data")
+	h.MkdirAll("/data/dir")
+	h.WriteFile("/secret", []byte("hunter2"))
+	h.Symlink("../secret", "/data/link")
+	dir := preopened(t, h)
+
+	for _, path := range []string{"/secret", "../secret", "dir/../../secret"} {
+		_, err := dir.OpenAt(0, path, 0, backend.DescriptorFlagsRead)
+		expectFsError(t, err, backend.FsErrorCodeNotPermitted)
+	}
+
+	_, err := dir.OpenAt(backend.PathFlagsSymlinkFollow, "link", 0, backend.DescriptorFlagsRead)
+	expectFsError(t, err, backend.FsErrorCodeNotPermitted)
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	h := New()
+	h.Preopen("/data")
+	h.Symlink("b", "/data/a")
+	h.Symlink("a", "/data/b")
+	dir := preopened(t, h)
+
+	_, err := dir.StatAt(backend.PathFlagsSymlinkFollow, "a")
+	expectFsError(t, err, backend.FsErrorCodeLoop)
+
+	stat, err := dir.StatAt(0, "a")
+	if err != nil || stat.Type != backend.DescriptorTypeSymbolicLink {
+		t.Errorf("StatAt without following = %+v, %v", stat, err)
+	}
+}
+
+func TestReadOnlyPreopen(t *testing.T) {
+	h := New()
+	h.PreopenReadOnly("/data")
+	dir := preopened(t, h)
+
+	_, err := dir.OpenAt(0, "new.txt", backend.OpenFlagsCreate, backend.DescriptorFlagsWrite)
+	expectFsError(t, err, backend.FsErrorCodeReadOnly)
+	expectFsError(t, dir.CreateDirectoryAt("sub"), backend.FsErrorCodeReadOnly)
+}
+
+func TestInjectedFsError(t *testing.T) {
+	h := New()
+	h.Preopen("/data")
+	dir := preopened(t, h)
+
+	injected := errors.New("injected")
+	h.InjectError("descriptor.create-directory-at", injected)
+	if err := dir.CreateDirectoryAt("sub"); err != injected {
+		t.Errorf("CreateDirectoryAt = %v, expected the injected error", err)
+	}
+	if err := dir.CreateDirectoryAt("sub"); err != nil {
+		t.Errorf("CreateDirectoryAt = %v once the injected error was used up", err)
+	}
+}
@@ -0,0 +1,50 @@
+package fake
+
+import (
+	"net/netip"
+	"pkg/internal/backend"
+	"strings"
+)
+
+func (h *Host) initNames() {
+	h.names = map[string][]netip.Addr{
+		"localhost": {netip.MustParseAddr("127.0.0.1"), netip.IPv6Loopback()},
+	}
+}
+
+// Make name resolve to the provided addresses, replacing any it resolved to
+// before. With no addresses, name no longer resolves.
+func (h *Host) AddHost(name string, addrs ...netip.Addr) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	name = strings.ToLower(name)
+	if len(addrs) == 0 {
+		delete(h.names, name)
+		return
+	}
+	h.names[name] = append([]netip.Addr(nil), addrs...)
+}
+
+func (h *Host) ResolveAddresses(name string) ([]netip.Addr, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("resolve-addresses"); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, &backend.LookupError{Code: backend.LookupErrorCodeInvalidArgument}
+	}
+
+	// Like the real thing, IP address literals resolve to themselves.
+	if addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")); err == nil {
+		return []netip.Addr{addr.WithZone("")}, nil
+	}
+
+	addrs, ok := h.names[strings.ToLower(name)]
+	if !ok {
+		return nil, &backend.LookupError{Code: backend.LookupErrorCodeNameUnresolvable}
+	}
+	return append([]netip.Addr(nil), addrs...), nil
+}
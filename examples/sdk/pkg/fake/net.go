@@ -0,0 +1,804 @@
+package fake
+
+import (
+	"io"
+	"net/netip"
+	"pkg/internal/backend"
+	"time"
+)
+
+const (
+	// The first port handed out when a socket is bound to port 0.
+	firstEphemeralPort = 49152
+
+	// The listen queue size used when none is set.
+	defaultBacklog = 128
+
+	// How many datagrams a UDP socket queues before dropping new ones.
+	udpQueueSize = 64
+
+	// The largest UDP payload over IPv4.
+	maxDatagramSize = 65507
+)
+
+// The sockets bound on the fake network, keyed by protocol, address family
+// and port.
+type network struct {
+	bound    map[portKey][]boundSocket
+	nextPort uint16
+
+	// Network interface indices by name.
+	interfaces map[string]uint32
+}
+
+type portKey struct {
+	udp  bool
+	af   backend.IpAddressFamily
+	port uint16
+}
+
+type boundSocket struct {
+	addr netip.Addr
+	tcp  *tcpSocket
+	udp  *udpSocket
+}
+
+func (n *network) init() {
+	n.bound = make(map[portKey][]boundSocket)
+	n.nextPort = firstEphemeralPort
+	n.interfaces = map[string]uint32{"lo": 1}
+}
+
+// Add a network interface, so that name can be used as an IPv6 zone. Unlike
+// the real wasi:sockets, the fake can resolve zone names; a new host only
+// knows "lo", with index 1.
+func (h *Host) AddInterface(name string, index uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.net.interfaces[name] = index
+}
+
+func (h *Host) InterfaceIndex(name string) (uint32, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	index, ok := h.net.interfaces[name]
+	return index, ok
+}
+
+func (h *Host) InterfaceName(index uint32) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, i := range h.net.interfaces {
+		if i == index {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Reserve addr for a socket, picking a free port if its port is 0. Must be
+// called with h.mu held.
+func (h *Host) bind(udp bool, af backend.IpAddressFamily, addr backend.IpSocketAddress, s boundSocket) (backend.IpSocketAddress, error) {
+	if familyOf(addr.Addr) != af {
+		return addr, socketError(backend.SocketErrorCodeInvalidArgument)
+	}
+	if !addr.Addr.IsUnspecified() && !addr.Addr.IsLoopback() {
+		return addr, socketError(backend.SocketErrorCodeAddressNotBindable)
+	}
+
+	if addr.Port == 0 {
+		for range 1 << 14 {
+			port := h.net.nextPort
+			h.net.nextPort++
+			if h.net.nextPort == 0 {
+				h.net.nextPort = firstEphemeralPort
+			}
+			if len(h.net.bound[portKey{udp, af, port}]) == 0 {
+				addr.Port = port
+				break
+			}
+		}
+		if addr.Port == 0 {
+			return addr, socketError(backend.SocketErrorCodeAddressInUse)
+		}
+	}
+
+	key := portKey{udp, af, addr.Port}
+	for _, other := range h.net.bound[key] {
+		if other.addr == addr.Addr || other.addr.IsUnspecified() || addr.Addr.IsUnspecified() {
+			return addr, socketError(backend.SocketErrorCodeAddressInUse)
+		}
+	}
+
+	s.addr = addr.Addr
+	h.net.bound[key] = append(h.net.bound[key], s)
+	return addr, nil
+}
+
+// Release a port reserved by bind. Must be called with h.mu held.
+func (h *Host) unbind(udp bool, af backend.IpAddressFamily, addr backend.IpSocketAddress) {
+	key := portKey{udp, af, addr.Port}
+	sockets := h.net.bound[key]
+	for i, s := range sockets {
+		if s.addr == addr.Addr {
+			h.net.bound[key] = append(sockets[:i:i], sockets[i+1:]...)
+			break
+		}
+	}
+	if len(h.net.bound[key]) == 0 {
+		delete(h.net.bound, key)
+	}
+}
+
+// Find the socket that receives traffic sent to addr. Must be called with
+// h.mu held.
+func (h *Host) lookupBound(udp bool, addr backend.IpSocketAddress) (boundSocket, bool) {
+	var wildcard boundSocket
+	found := false
+	for _, s := range h.net.bound[portKey{udp, familyOf(addr.Addr), addr.Port}] {
+		if s.addr == addr.Addr {
+			return s, true
+		}
+		if s.addr.IsUnspecified() {
+			wildcard, found = s, true
+		}
+	}
+	return wildcard, found
+}
+
+func (h *Host) CreateTcpSocket(af backend.IpAddressFamily) (backend.TcpSocket, error) {
+	if err := h.injectedError("create-tcp-socket"); err != nil {
+		return nil, err
+	}
+	return &tcpSocket{
+		h:                 h,
+		af:                af,
+		keepAliveIdleTime: 2 * time.Hour,
+		keepAliveInterval: 75 * time.Second,
+		keepAliveCount:    9,
+		hopLimit:          64,
+		receiveBufferSize: pipeCapacity,
+		sendBufferSize:    pipeCapacity,
+	}, nil
+}
+
+type tcpState uint8
+
+const (
+	tcpUnbound tcpState = iota
+	tcpBound
+	tcpListening
+	tcpConnected
+	tcpClosed
+)
+
+// A fake TCP socket. Its state is guarded by h.mu.
+type tcpSocket struct {
+	h     *Host
+	af    backend.IpAddressFamily
+	state tcpState
+
+	local  backend.IpSocketAddress
+	remote backend.IpSocketAddress
+
+	// For listening sockets, the connections waiting to be accepted, and a
+	// channel that is closed once the socket stops listening.
+	backlog uint64
+	queue   chan *tcpSocket
+	stopped chan struct{}
+
+	// For connected sockets, the data flowing in each direction. Accepted
+	// sockets share their listener's port rather than binding their own.
+	rx, tx   *pipe
+	accepted bool
+
+	keepAliveEnabled  bool
+	keepAliveIdleTime time.Duration
+	keepAliveInterval time.Duration
+	keepAliveCount    uint32
+	hopLimit          uint8
+	receiveBufferSize uint64
+	sendBufferSize    uint64
+}
+
+func (s *tcpSocket) Bind(local backend.IpSocketAddress) error {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("tcp-socket.bind"); err != nil {
+		return err
+	}
+	if s.state != tcpUnbound {
+		return socketError(backend.SocketErrorCodeInvalidState)
+	}
+
+	addr, err := h.bind(false, s.af, local, boundSocket{tcp: s})
+	if err != nil {
+		return err
+	}
+	s.local = addr
+	s.state = tcpBound
+	return nil
+}
+
+func (s *tcpSocket) Connect(remote backend.IpSocketAddress) error {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("tcp-socket.connect"); err != nil {
+		return err
+	}
+	if s.state != tcpUnbound && s.state != tcpBound {
+		return socketError(backend.SocketErrorCodeInvalidState)
+	}
+	if familyOf(remote.Addr) != s.af || remote.Addr.IsUnspecified() || remote.Port == 0 {
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	}
+	if !remote.Addr.IsLoopback() {
+		return socketError(backend.SocketErrorCodeRemoteUnreachable)
+	}
+
+	target, ok := h.lookupBound(false, remote)
+	if !ok || target.tcp.state != tcpListening {
+		return socketError(backend.SocketErrorCodeConnectionRefused)
+	}
+	listener := target.tcp
+
+	if s.state == tcpUnbound {
+		local := backend.IpSocketAddress{Addr: remote.Addr}
+		addr, err := h.bind(false, s.af, local, boundSocket{tcp: s})
+		if err != nil {
+			return err
+		}
+		s.local = addr
+	}
+
+	// Accepted sockets inherit the listener's options.
+	peer := *listener
+	peer.state = tcpConnected
+	peer.local = backend.IpSocketAddress{Addr: remote.Addr, Port: listener.local.Port}
+	peer.remote = s.local
+	peer.queue, peer.stopped = nil, nil
+	peer.rx, peer.tx = newPipe(), newPipe()
+	peer.accepted = true
+
+	select {
+	case listener.queue <- &peer:
+	default:
+		return socketError(backend.SocketErrorCodeConnectionRefused)
+	}
+
+	s.state = tcpConnected
+	s.remote = remote
+	s.rx, s.tx = peer.tx, peer.rx
+	return nil
+}
+
+func (s *tcpSocket) Listen() (backend.TcpAcceptor, error) {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("tcp-socket.listen"); err != nil {
+		return nil, err
+	}
+	if s.state != tcpUnbound && s.state != tcpBound {
+		return nil, socketError(backend.SocketErrorCodeInvalidState)
+	}
+
+	if s.state == tcpUnbound {
+		local := backend.IpSocketAddress{Addr: unspecified(s.af)}
+		addr, err := h.bind(false, s.af, local, boundSocket{tcp: s})
+		if err != nil {
+			return nil, err
+		}
+		s.local = addr
+	}
+
+	backlog := s.backlog
+	if backlog == 0 {
+		backlog = defaultBacklog
+	}
+	s.queue = make(chan *tcpSocket, backlog)
+	s.stopped = make(chan struct{})
+	s.state = tcpListening
+	return &tcpAcceptor{s}, nil
+}
+
+// Stop accepting connections, resetting those that were never accepted. Must
+// be called with h.mu held.
+func (s *tcpSocket) stopListening() {
+	if s.state != tcpListening {
+		return
+	}
+	s.state = tcpClosed
+	s.h.unbind(false, s.af, s.local)
+	close(s.stopped)
+
+	for {
+		select {
+		case conn := <-s.queue:
+			conn.reset()
+		default:
+			return
+		}
+	}
+}
+
+// Abort a connection, so that the peer sees it reset.
+func (s *tcpSocket) reset() {
+	reset := socketError(backend.SocketErrorCodeConnectionReset)
+	s.tx.closeWrite(reset)
+	s.rx.closeRead()
+}
+
+func (s *tcpSocket) Send() backend.OutputStream {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.state != tcpConnected {
+		return &outputStream{err: socketError(backend.SocketErrorCodeInvalidState)}
+	}
+	return &outputStream{h: h, op: "tcp-socket.send", pipe: s.tx}
+}
+
+func (s *tcpSocket) Receive() backend.InputStream {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.state != tcpConnected {
+		return &inputStream{err: socketError(backend.SocketErrorCodeInvalidState)}
+	}
+	return &inputStream{h: h, op: "tcp-socket.receive", pipe: s.rx}
+}
+
+func (s *tcpSocket) GetLocalAddress() (backend.IpSocketAddress, error) {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	if s.state == tcpUnbound {
+		return backend.IpSocketAddress{}, socketError(backend.SocketErrorCodeInvalidState)
+	}
+	return s.local, nil
+}
+
+func (s *tcpSocket) GetRemoteAddress() (backend.IpSocketAddress, error) {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	if s.state != tcpConnected {
+		return backend.IpSocketAddress{}, socketError(backend.SocketErrorCodeInvalidState)
+	}
+	return s.remote, nil
+}
+
+func (s *tcpSocket) GetIsListening() bool {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	return s.state == tcpListening
+}
+
+func (s *tcpSocket) GetAddressFamily() backend.IpAddressFamily {
+	return s.af
+}
+
+func (s *tcpSocket) SetListenBacklogSize(size uint64) error {
+	return s.set("tcp-socket.set-listen-backlog-size", size == 0, func() {
+		s.backlog = size
+	})
+}
+
+func (s *tcpSocket) GetKeepAliveEnabled() (bool, error) {
+	return get(s.h, &s.keepAliveEnabled)
+}
+
+func (s *tcpSocket) SetKeepAliveEnabled(v bool) error {
+	return s.set("tcp-socket.set-keep-alive-enabled", false, func() {
+		s.keepAliveEnabled = v
+	})
+}
+
+func (s *tcpSocket) GetKeepAliveIdleTime() (time.Duration, error) {
+	return get(s.h, &s.keepAliveIdleTime)
+}
+
+func (s *tcpSocket) SetKeepAliveIdleTime(d time.Duration) error {
+	return s.set("tcp-socket.set-keep-alive-idle-time", d <= 0, func() {
+		s.keepAliveIdleTime = d
+	})
+}
+
+func (s *tcpSocket) GetKeepAliveInterval() (time.Duration, error) {
+	return get(s.h, &s.keepAliveInterval)
+}
+
+func (s *tcpSocket) SetKeepAliveInterval(d time.Duration) error {
+	return s.set("tcp-socket.set-keep-alive-interval", d <= 0, func() {
+		s.keepAliveInterval = d
+	})
+}
+
+func (s *tcpSocket) GetKeepAliveCount() (uint32, error) {
+	return get(s.h, &s.keepAliveCount)
+}
+
+func (s *tcpSocket) SetKeepAliveCount(v uint32) error {
+	return s.set("tcp-socket.set-keep-alive-count", v == 0, func() {
+		s.keepAliveCount = v
+	})
+}
+
+func (s *tcpSocket) GetHopLimit() (uint8, error) {
+	return get(s.h, &s.hopLimit)
+}
+
+func (s *tcpSocket) SetHopLimit(v uint8) error {
+	return s.set("tcp-socket.set-hop-limit", v == 0, func() {
+		s.hopLimit = v
+	})
+}
+
+func (s *tcpSocket) GetReceiveBufferSize() (uint64, error) {
+	return get(s.h, &s.receiveBufferSize)
+}
+
+func (s *tcpSocket) SetReceiveBufferSize(size uint64) error {
+	return s.set("tcp-socket.set-receive-buffer-size", size == 0, func() {
+		s.receiveBufferSize = size
+	})
+}
+
+func (s *tcpSocket) GetSendBufferSize() (uint64, error) {
+	return get(s.h, &s.sendBufferSize)
+}
+
+func (s *tcpSocket) SetSendBufferSize(size uint64) error {
+	return s.set("tcp-socket.set-send-buffer-size", size == 0, func() {
+		s.sendBufferSize = size
+	})
+}
+
+// Apply an option setter, failing with an injected error, or with
+// invalid-argument if the value is invalid.
+func (s *tcpSocket) set(op string, invalid bool, apply func()) error {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+
+	if err := s.h.takeError(op); err != nil {
+		return err
+	}
+	if invalid {
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	}
+	apply()
+	return nil
+}
+
+func get[T any](h *Host, v *T) (T, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return *v, nil
+}
+
+func (s *tcpSocket) Drop() {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch s.state {
+	case tcpBound:
+		h.unbind(false, s.af, s.local)
+	case tcpListening:
+		s.stopListening()
+	case tcpConnected:
+		if !s.accepted {
+			h.unbind(false, s.af, s.local)
+		}
+		s.tx.closeWrite(nil)
+		s.rx.closeRead()
+	}
+	s.state = tcpClosed
+}
+
+type tcpAcceptor struct {
+	s *tcpSocket
+}
+
+func (a *tcpAcceptor) Accept() (backend.TcpSocket, error) {
+	select {
+	case conn := <-a.s.queue:
+		return conn, nil
+	case <-a.s.stopped:
+		return nil, io.EOF
+	}
+}
+
+func (a *tcpAcceptor) Drop() {
+	a.s.h.mu.Lock()
+	defer a.s.h.mu.Unlock()
+	a.s.stopListening()
+}
+
+func (h *Host) CreateUdpSocket(af backend.IpAddressFamily) (backend.UdpSocket, error) {
+	if err := h.injectedError("create-udp-socket"); err != nil {
+		return nil, err
+	}
+	return &udpSocket{
+		h:                 h,
+		af:                af,
+		queue:             make(chan datagram, udpQueueSize),
+		closed:            make(chan struct{}),
+		unicastHopLimit:   64,
+		receiveBufferSize: pipeCapacity,
+		sendBufferSize:    pipeCapacity,
+	}, nil
+}
+
+type datagram struct {
+	data []byte
+	from backend.IpSocketAddress
+}
+
+// A fake UDP socket. Its state is guarded by h.mu.
+type udpSocket struct {
+	h  *Host
+	af backend.IpAddressFamily
+
+	bound     bool
+	connected bool
+	dropped   bool
+	local     backend.IpSocketAddress
+	remote    backend.IpSocketAddress
+
+	queue  chan datagram
+	closed chan struct{}
+
+	unicastHopLimit   uint8
+	receiveBufferSize uint64
+	sendBufferSize    uint64
+}
+
+func (s *udpSocket) Bind(local backend.IpSocketAddress) error {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("udp-socket.bind"); err != nil {
+		return err
+	}
+	if s.bound {
+		return socketError(backend.SocketErrorCodeInvalidState)
+	}
+	return s.bindLocked(local)
+}
+
+// Must be called with h.mu held.
+func (s *udpSocket) bindLocked(local backend.IpSocketAddress) error {
+	addr, err := s.h.bind(true, s.af, local, boundSocket{udp: s})
+	if err != nil {
+		return err
+	}
+	s.local = addr
+	s.bound = true
+	return nil
+}
+
+func (s *udpSocket) Connect(remote backend.IpSocketAddress) error {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("udp-socket.connect"); err != nil {
+		return err
+	}
+	if familyOf(remote.Addr) != s.af || remote.Addr.IsUnspecified() || remote.Port == 0 {
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	}
+	if !s.bound {
+		if err := s.bindLocked(backend.IpSocketAddress{Addr: loopback(s.af)}); err != nil {
+			return err
+		}
+	}
+	s.connected = true
+	s.remote = remote
+	return nil
+}
+
+func (s *udpSocket) Disconnect() error {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("udp-socket.disconnect"); err != nil {
+		return err
+	}
+	if !s.connected {
+		return socketError(backend.SocketErrorCodeInvalidState)
+	}
+	s.connected = false
+	s.remote = backend.IpSocketAddress{}
+	return nil
+}
+
+func (s *udpSocket) Send(data []byte, remote *backend.IpSocketAddress) error {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.takeError("udp-socket.send"); err != nil {
+		return err
+	}
+
+	var to backend.IpSocketAddress
+	switch {
+	case remote == nil && !s.connected:
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	case remote == nil:
+		to = s.remote
+	case s.connected && *remote != s.remote:
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	default:
+		to = *remote
+	}
+	if familyOf(to.Addr) != s.af || to.Addr.IsUnspecified() || to.Port == 0 {
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	}
+	if len(data) > maxDatagramSize {
+		return socketError(backend.SocketErrorCodeDatagramTooLarge)
+	}
+	if !s.bound {
+		if err := s.bindLocked(backend.IpSocketAddress{Addr: loopback(s.af)}); err != nil {
+			return err
+		}
+	}
+
+	// Like real UDP, datagrams nobody is listening for are silently lost.
+	target, ok := h.lookupBound(true, to)
+	if !ok || !to.Addr.IsLoopback() {
+		return nil
+	}
+	peer := target.udp
+	from := s.local
+	if from.Addr.IsUnspecified() {
+		from.Addr = to.Addr
+	}
+	if peer.connected && peer.remote != from {
+		return nil
+	}
+
+	select {
+	case peer.queue <- datagram{data: append([]byte(nil), data...), from: from}:
+	default:
+	}
+	return nil
+}
+
+func (s *udpSocket) Receive() ([]byte, backend.IpSocketAddress, error) {
+	h := s.h
+	h.mu.Lock()
+	err := h.takeError("udp-socket.receive")
+	bound := s.bound
+	h.mu.Unlock()
+
+	if err != nil {
+		return nil, backend.IpSocketAddress{}, err
+	}
+	if !bound {
+		return nil, backend.IpSocketAddress{}, socketError(backend.SocketErrorCodeInvalidState)
+	}
+
+	select {
+	case d := <-s.queue:
+		return d.data, d.from, nil
+	case <-s.closed:
+		return nil, backend.IpSocketAddress{}, socketError(backend.SocketErrorCodeInvalidState)
+	}
+}
+
+func (s *udpSocket) GetLocalAddress() (backend.IpSocketAddress, error) {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	if !s.bound {
+		return backend.IpSocketAddress{}, socketError(backend.SocketErrorCodeInvalidState)
+	}
+	return s.local, nil
+}
+
+func (s *udpSocket) GetRemoteAddress() (backend.IpSocketAddress, error) {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	if !s.connected {
+		return backend.IpSocketAddress{}, socketError(backend.SocketErrorCodeInvalidState)
+	}
+	return s.remote, nil
+}
+
+func (s *udpSocket) GetAddressFamily() backend.IpAddressFamily {
+	return s.af
+}
+
+func (s *udpSocket) GetUnicastHopLimit() (uint8, error) {
+	return get(s.h, &s.unicastHopLimit)
+}
+
+func (s *udpSocket) SetUnicastHopLimit(v uint8) error {
+	return s.set("udp-socket.set-unicast-hop-limit", v == 0, func() {
+		s.unicastHopLimit = v
+	})
+}
+
+func (s *udpSocket) GetReceiveBufferSize() (uint64, error) {
+	return get(s.h, &s.receiveBufferSize)
+}
+
+func (s *udpSocket) SetReceiveBufferSize(size uint64) error {
+	return s.set("udp-socket.set-receive-buffer-size", size == 0, func() {
+		s.receiveBufferSize = size
+	})
+}
+
+func (s *udpSocket) GetSendBufferSize() (uint64, error) {
+	return get(s.h, &s.sendBufferSize)
+}
+
+func (s *udpSocket) SetSendBufferSize(size uint64) error {
+	return s.set("udp-socket.set-send-buffer-size", size == 0, func() {
+		s.sendBufferSize = size
+	})
+}
+
+func (s *udpSocket) set(op string, invalid bool, apply func()) error {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+
+	if err := s.h.takeError(op); err != nil {
+		return err
+	}
+	if invalid {
+		return socketError(backend.SocketErrorCodeInvalidArgument)
+	}
+	apply()
+	return nil
+}
+
+func (s *udpSocket) Drop() {
+	h := s.h
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if s.dropped {
+		return
+	}
+	s.dropped = true
+	if s.bound {
+		h.unbind(true, s.af, s.local)
+	}
+	close(s.closed)
+}
+
+func socketError(code backend.SocketErrorCode) error {
+	return &backend.SocketError{Code: code}
+}
+
+func familyOf(addr netip.Addr) backend.IpAddressFamily {
+	if addr.Is4() {
+		return backend.IpAddressFamilyIpv4
+	}
+	return backend.IpAddressFamilyIpv6
+}
+
+func unspecified(af backend.IpAddressFamily) netip.Addr {
+	if af == backend.IpAddressFamilyIpv4 {
+		return netip.IPv4Unspecified()
+	}
+	return netip.IPv6Unspecified()
+}
+
+func loopback(af backend.IpAddressFamily) netip.Addr {
+	if af == backend.IpAddressFamilyIpv4 {
+		return netip.AddrFrom4([4]byte{127, 0, 0, 1})
+	}
+	return netip.IPv6Loopback()
+}
@@ -0,0 +1,162 @@
+package fake
+
+import (
+	"io"
+	"pkg/internal/backend"
+	"sync"
+)
+
+// How many bytes a pipe buffers before writes block, so that a reader that
+// falls behind applies backpressure to the writer.
+const pipeCapacity = 64 * 1024
+
+// One direction of a fake TCP connection.
+type pipe struct {
+	mu   sync.Mutex
+	cond sync.Cond
+	buf  []byte
+
+	// Set once the writer has finished. Once the buffer is drained, reads
+	// return err, or io.EOF if it is nil.
+	closed bool
+	err    error
+
+	// Set once the reader has gone away, after which writes fail.
+	readerGone bool
+}
+
+func newPipe() *pipe {
+	p := &pipe{}
+	p.cond.L = &p.mu
+	return p
+}
+
+func (p *pipe) read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.buf) == 0 && !p.closed && !p.readerGone {
+		p.cond.Wait()
+	}
+
+	if p.readerGone {
+		return 0, &backend.SocketError{Code: backend.SocketErrorCodeInvalidState}
+	}
+	if len(p.buf) == 0 {
+		if p.err != nil {
+			return 0, p.err
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	p.cond.Broadcast()
+	return n, nil
+}
+
+func (p *pipe) write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	written := 0
+	for written < len(b) {
+		for len(p.buf) >= pipeCapacity && !p.readerGone && !p.closed {
+			p.cond.Wait()
+		}
+		if p.readerGone {
+			return written, &backend.SocketError{Code: backend.SocketErrorCodeConnectionBroken}
+		}
+		if p.closed {
+			return written, &backend.SocketError{Code: backend.SocketErrorCodeInvalidState}
+		}
+
+		n := min(len(b)-written, pipeCapacity-len(p.buf))
+		p.buf = append(p.buf, b[written:written+n]...)
+		written += n
+		p.cond.Broadcast()
+	}
+	return written, nil
+}
+
+// Finish the writing side. The reader sees err, or io.EOF if it is nil,
+// once it has drained the buffer.
+func (p *pipe) closeWrite(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.err = err
+		p.cond.Broadcast()
+	}
+}
+
+// Finish the reading side, discarding anything still buffered.
+func (p *pipe) closeRead() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readerGone = true
+	p.buf = nil
+	p.cond.Broadcast()
+}
+
+// The receiving end of a TCP connection, as seen by the SDK.
+type inputStream struct {
+	h    *Host
+	op   string
+	pipe *pipe
+
+	// Reported by every read, when the stream could not be opened at all.
+	err error
+}
+
+func (s *inputStream) Read(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if err := s.h.injectedError(s.op); err != nil {
+		return 0, err
+	}
+	return s.pipe.read(b)
+}
+
+func (s *inputStream) Drop() {
+	if s.pipe != nil {
+		s.pipe.closeRead()
+	}
+}
+
+// The sending end of a TCP connection, as seen by the SDK.
+type outputStream struct {
+	h    *Host
+	op   string
+	pipe *pipe
+	err  error
+}
+
+func (s *outputStream) Write(b []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if err := s.h.injectedError(s.op); err != nil {
+		return 0, err
+	}
+	return s.pipe.write(b)
+}
+
+func (s *outputStream) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	s.pipe.closeWrite(nil)
+	return nil
+}
+
+func (s *outputStream) Drop() {
+	if s.pipe != nil {
+		s.pipe.closeWrite(nil)
+	}
+}
@@ -0,0 +1,43 @@
+package fake
+
+import "math/rand/v2"
+
+// Randomness from a seeded generator, so that tests are reproducible. Both
+// the secure and insecure interfaces draw from it.
+type random struct {
+	source *rand.ChaCha8
+}
+
+func (r *random) init() {
+	r.source = rand.NewChaCha8([32]byte{})
+}
+
+// Restart the random number generator from seed. A new host starts from an
+// all-zero seed.
+func (h *Host) SeedRandom(seed [32]byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.rand.source = rand.NewChaCha8(seed)
+}
+
+func (h *Host) GetRandomBytes(n uint64) []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := make([]byte, n)
+	h.rand.source.Read(b)
+	return b
+}
+
+func (h *Host) GetRandomU64() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.rand.source.Uint64()
+}
+
+func (h *Host) GetInsecureRandomBytes(n uint64) []byte {
+	return h.GetRandomBytes(n)
+}
+
+func (h *Host) GetInsecureRandomU64() uint64 {
+	return h.GetRandomU64()
+}
@@ -0,0 +1,163 @@
+package fake
+
+import (
+	"io"
+	"pkg/internal/backend"
+)
+
+type stdio struct {
+	stdin  []byte
+	stdout []byte
+	stderr []byte
+
+	terminalStdin  bool
+	terminalStdout bool
+	terminalStderr bool
+
+	exited bool
+	code   int
+}
+
+// Set what the component reads from stdin. Once it has all been read, stdin
+// reports the end of the stream.
+func (h *Host) SetStdin(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stdio.stdin = append([]byte(nil), data...)
+}
+
+// Set which of the standard streams are attached to a terminal. None are
+// by default.
+func (h *Host) SetTerminals(stdin, stdout, stderr bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stdio.terminalStdin = stdin
+	h.stdio.terminalStdout = stdout
+	h.stdio.terminalStderr = stderr
+}
+
+// Everything written to stdout so far.
+func (h *Host) Stdout() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]byte(nil), h.stdio.stdout...)
+}
+
+// Everything written to stderr so far.
+func (h *Host) Stderr() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]byte(nil), h.stdio.stderr...)
+}
+
+// The status the component last asked to exit with, if it has done so.
+// Exiting with an error reports code 1.
+func (h *Host) ExitCode() (int, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stdio.code, h.stdio.exited
+}
+
+func (h *Host) GetStdin() backend.InputStream {
+	return &stdinStream{h: h}
+}
+
+func (h *Host) GetStdout() backend.OutputStream {
+	return &stdioStream{h: h, op: "stdout.write-via-stream", buf: &h.stdio.stdout}
+}
+
+func (h *Host) GetStderr() backend.OutputStream {
+	return &stdioStream{h: h, op: "stderr.write-via-stream", buf: &h.stdio.stderr}
+}
+
+func (h *Host) GetTerminalStdin() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stdio.terminalStdin
+}
+
+func (h *Host) GetTerminalStdout() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stdio.terminalStdout
+}
+
+func (h *Host) GetTerminalStderr() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stdio.terminalStderr
+}
+
+func (h *Host) Exit(ok bool) {
+	if ok {
+		h.ExitWithCode(0)
+	} else {
+		h.ExitWithCode(1)
+	}
+}
+
+func (h *Host) ExitWithCode(code uint8) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stdio.exited = true
+	h.stdio.code = int(code)
+}
+
+// Reads from the data given to SetStdin. Injected "stdin.read-via-stream"
+// errors fail the next read.
+type stdinStream struct {
+	h       *Host
+	dropped bool
+}
+
+func (s *stdinStream) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	if err := s.h.takeError("stdin.read-via-stream"); err != nil {
+		return 0, err
+	}
+	if s.dropped || len(s.h.stdio.stdin) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(b, s.h.stdio.stdin)
+	s.h.stdio.stdin = s.h.stdio.stdin[n:]
+	return n, nil
+}
+
+func (s *stdinStream) Drop() {
+	s.dropped = true
+}
+
+// Appends to the captured stdout or stderr. Injected errors for op fail the
+// next write, and the stream reports them again when it is closed.
+type stdioStream struct {
+	h   *Host
+	op  string
+	buf *[]byte
+	err error
+}
+
+func (s *stdioStream) Write(b []byte) (int, error) {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	if s.err == nil {
+		s.err = s.h.takeError(s.op)
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	*s.buf = append(*s.buf, b...)
+	return len(b), nil
+}
+
+func (s *stdioStream) Close() error {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	return s.err
+}
+
+func (s *stdioStream) Drop() {}
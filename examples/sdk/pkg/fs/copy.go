@@ -0,0 +1,212 @@
+package fs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"pkg/internal/backend"
+)
+
+// The size of the chunks Copy moves data in when it passes through the
+// guest, unless CopyConfig.BufferSize says otherwise.
+const defaultCopyBufferSize = 256 << 10
+
+// Options for Copy. The zero value is ready to use.
+type CopyConfig struct {
+	// The size of the chunks data is copied in when it passes through the
+	// guest. Zero means 256 KiB.
+	BufferSize int
+
+	// Called with the number of bytes copied so far, after each chunk when
+	// data passes through the guest, or once at the end when the host copies
+	// it by itself, since the host does not report how far it has got.
+	Progress func(copied int64)
+}
+
+// Copy the rest of src, from its offset, to dst, using the default
+// CopyConfig.
+func Copy(dst io.Writer, src *File) (int64, error) {
+	var c CopyConfig
+	return c.Copy(dst, src)
+}
+
+// Copy the rest of src, from its offset, to dst, and return the number of
+// bytes copied. src is advised that it will be read sequentially.
+//
+// If dst is a *File of the same host, the host is handed src's stream and
+// copies the data by itself, so that it never passes through the guest. The
+// host does not report how much it copied, so the count returned, the one
+// passed to Progress and the offsets the files move on by are all taken from
+// src's size when the copy starts. src must not grow or shrink until Copy
+// returns. If the host fails, the number of bytes copied is not known, and 0
+// is returned.
+//
+// Otherwise, the data is read into a buffer and written to dst. That includes
+// http.ResponseWriter, which only takes byte slices, and connections from
+// package sockets: copying a file to a socket without passing through the
+// guest is not supported, since wasi:sockets takes a single send stream per
+// connection, and package sockets opens it as soon as the socket connects.
+func (c *CopyConfig) Copy(dst io.Writer, src *File) (int64, error) {
+	src.advise(backend.AdviceSequential)
+	if f, ok := dst.(*File); ok && f != src {
+		if n, ok, err := splice(f, src); ok {
+			if err == nil {
+				c.progress(n)
+			}
+			return n, err
+		}
+		f.advise(backend.AdviceSequential)
+	}
+
+	size := c.BufferSize
+	if size <= 0 {
+		size = defaultCopyBufferSize
+	}
+	buf := make([]byte, size)
+
+	var copied int64
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			written, writeErr := dst.Write(buf[:n])
+			copied += int64(written)
+			c.progress(copied)
+			if writeErr != nil {
+				return copied, writeErr
+			}
+			if written < n {
+				return copied, io.ErrShortWrite
+			}
+		}
+		if err == io.EOF {
+			return copied, nil
+		}
+		if err != nil {
+			return copied, err
+		}
+	}
+}
+
+func (c *CopyConfig) progress(copied int64) {
+	if c.Progress != nil {
+		c.Progress(copied)
+	}
+}
+
+// Copy the named file to dst, which is created or truncated, and return the
+// number of bytes copied.
+func (c *CopyConfig) CopyFile(dst, src string) (int64, error) {
+	in, err := Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	out, err := OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Copy the rest of the file to w, so that io.Copy uses Copy.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	return Copy(w, f)
+}
+
+// Give the host a hint about how the rest of the file will be used. Hints
+// are optional for hosts, so failures are ignored.
+func (f *File) advise(advice backend.Advice) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.closed {
+		f.desc.Advise(uint64(f.offset), 0, advice)
+	}
+}
+
+// Have the host copy the rest of src to dst by itself. Reports false if it
+// cannot, leaving both files as they were.
+func splice(dst, src *File) (int64, bool, error) {
+	splicer, ok := dst.desc.(backend.DescriptorSplicer)
+	if !ok {
+		return 0, false, nil
+	}
+	dst.mu.Lock()
+	appending := dst.append
+	dst.mu.Unlock()
+	if appending {
+		// The host can only splice to an offset.
+		return 0, false, nil
+	}
+
+	in, n, err := src.spliceSource()
+	if err != nil {
+		return 0, true, err
+	}
+	// Does nothing once the host has taken the stream.
+	defer in.Drop()
+
+	ok, err = dst.spliceFrom(splicer, in, n)
+	if !ok || err != nil {
+		return 0, ok, err
+	}
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	src.offset += n
+	if src.reader != nil {
+		src.reader.Drop()
+		src.reader = nil
+	}
+	return n, true, nil
+}
+
+// A stream over the rest of the file, and its length as of now, for splice.
+func (f *File) spliceSource() (backend.InputStream, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, 0, f.closedError("read")
+	}
+	if err := f.finishWrite("read"); err != nil {
+		return nil, 0, err
+	}
+	stat, err := f.desc.Stat()
+	if err != nil {
+		return nil, 0, pathError("read", f.name, err)
+	}
+	return f.desc.ReadViaStream(uint64(f.offset)), max(int64(stat.Size)-f.offset, 0), nil
+}
+
+// Have the host write in, which holds n bytes, at the file's offset.
+func (f *File) spliceFrom(splicer backend.DescriptorSplicer, in backend.InputStream, n int64) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return true, f.closedError("write")
+	}
+	if err := f.finishWrite("write"); err != nil {
+		return true, err
+	}
+	if f.reader != nil {
+		// It may have read ahead of what is about to change.
+		f.reader.Drop()
+		f.reader = nil
+	}
+
+	ok, err := splicer.SpliceViaStream(in, uint64(f.offset))
+	if err != nil {
+		return true, &fs.PathError{Op: "copy", Path: f.name, Err: fromHostError(err)}
+	}
+	if ok {
+		f.offset += n
+	}
+	return ok, nil
+}
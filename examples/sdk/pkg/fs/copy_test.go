@@ -0,0 +1,249 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"pkg/fake"
+	"pkg/internal/backend"
+	"pkg/sockets"
+	"testing"
+	"time"
+)
+
+// Set up a file of size bytes at /data/big, returning its content.
+func bigFile(t *testing.T, size int) (*fake.Host, []byte) {
+	t.Helper()
+
+	host := setup(t)
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	if err := host.WriteFile("/data/big", data); err != nil {
+		t.Fatal(err)
+	}
+	return host, data
+}
+
+func TestCopyFileToFile(t *testing.T) {
+	host, data := bigFile(t, 1<<20)
+
+	src, err := Open("/data/big")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+	if _, err := src.Seek(100, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	dst, err := Create("/data/copy")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := dst.WriteString("head:"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	var progress []int64
+	c := CopyConfig{Progress: func(n int64) { progress = append(progress, n) }}
+	n, err := c.Copy(dst, src)
+	if err != nil || n != int64(len(data)-100) {
+		t.Fatalf("Copy() = %d, %v", n, err)
+	}
+	// The host copies by itself, so progress is only known at the end.
+	if len(progress) != 1 || progress[0] != n {
+		t.Errorf("progress = %v", progress)
+	}
+	if off, _ := src.Seek(0, io.SeekCurrent); off != int64(len(data)) {
+		t.Errorf("source offset = %d", off)
+	}
+	if _, err := dst.WriteString(":tail"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	got, _ := host.ReadFile("/data/copy")
+	want := append(append([]byte("head:"), data[100:]...), ":tail"...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("copy has %d bytes, expected %d", len(got), len(want))
+	}
+	if advice, err := host.Advice("/data/big"); err != nil || advice != backend.AdviceSequential {
+		t.Errorf("Advice() = %v, %v", advice, err)
+	}
+}
+
+func TestCopyThroughBuffer(t *testing.T) {
+	_, data := bigFile(t, 1<<20)
+
+	src, err := Open("/data/big")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+
+	var buf bytes.Buffer
+	var progress []int64
+	c := CopyConfig{BufferSize: 64 << 10, Progress: func(n int64) { progress = append(progress, n) }}
+	n, err := c.Copy(&buf, src)
+	if err != nil || n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("Copy() = %d, %v", n, err)
+	}
+	if len(progress) < len(data)/(64<<10) || progress[len(progress)-1] != n {
+		t.Errorf("progress = %v", progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Fatalf("progress went backwards: %v", progress)
+		}
+	}
+}
+
+func TestCopyToSocket(t *testing.T) {
+	_, data := bigFile(t, 1<<20)
+
+	ctx := context.Background()
+	l, err := (&sockets.ListenConfig{}).Listen(ctx, "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	client, err := (&sockets.DialConfig{}).Dial(ctx, "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	defer server.Close()
+
+	received := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(server)
+		received <- b
+	}()
+
+	src, err := Open("/data/big")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+	if n, err := io.Copy(client, src); err != nil || n != int64(len(data)) {
+		t.Errorf("io.Copy() = %d, %v", n, err)
+	}
+	client.Close()
+
+	select {
+	case b := <-received:
+		if !bytes.Equal(b, data) {
+			t.Errorf("received %d bytes, expected %d", len(b), len(data))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the data")
+	}
+}
+
+func TestCopyToHTTP(t *testing.T) {
+	_, data := bigFile(t, 1<<20)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := Open("/data/big")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+		if r.URL.Path == "/ranged" {
+			info, _ := f.Stat()
+			http.ServeContent(w, r, "big", info.ModTime(), f)
+			return
+		}
+		Copy(w, f)
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !bytes.Equal(rec.Body.Bytes(), data) {
+		t.Errorf("body has %d bytes, expected %d", rec.Body.Len(), len(data))
+	}
+
+	req := httptest.NewRequest("GET", "/ranged", nil)
+	req.Header.Set("Range", "bytes=1000-1999")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), data[1000:2000]) {
+		t.Errorf("ranged response = %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+}
+
+func TestCopyFile(t *testing.T) {
+	host := setup(t)
+
+	var c CopyConfig
+	if n, err := c.CopyFile("/data/sub/copy.txt", "/data/hello.txt"); err != nil || n != 12 {
+		t.Fatalf("CopyFile() = %d, %v", n, err)
+	}
+	if data, _ := host.ReadFile("/data/sub/copy.txt"); string(data) != "hello, world" {
+		t.Errorf("copy = %q", data)
+	}
+	if _, err := c.CopyFile("/data/x", "/data/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("CopyFile of a missing file error = %v", err)
+	}
+	if _, err := c.CopyFile("/srv/www/copy", "/data/hello.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("CopyFile to a read-only preopen error = %v", err)
+	}
+
+	// Appending cannot be handed to the host, so the data passes through.
+	dst, err := OpenFile("/data/sub/a.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	src, err := Open("/data/hello.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+	if n, err := Copy(dst, src); err != nil || n != 12 {
+		t.Errorf("Copy() = %d, %v", n, err)
+	}
+	dst.Close()
+	if data, _ := host.ReadFile("/data/sub/a.txt"); string(data) != "ahello, world" {
+		t.Errorf("appended = %q", data)
+	}
+}
+
+func TestCopyErrors(t *testing.T) {
+	host := setup(t)
+
+	src, err := Open("/data/hello.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer src.Close()
+	dst, err := Create("/data/copy")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer dst.Close()
+
+	injected := errors.New("injected")
+	host.InjectError("descriptor.read-via-stream", injected)
+	n, err := Copy(dst, src)
+	var pathErr *fs.PathError
+	if !errors.Is(err, injected) || !errors.As(err, &pathErr) || pathErr.Op != "copy" || n != 0 {
+		t.Errorf("Copy() = %d, %v", n, err)
+	}
+
+	dst.Close()
+	if _, err := Copy(dst, src); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Copy to a closed file error = %v", err)
+	}
+}
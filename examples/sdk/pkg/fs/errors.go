@@ -0,0 +1,251 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"pkg/internal/backend"
+	"syscall"
+)
+
+// An error code reported by the host. Each code is a sentinel error that can
+// be matched with errors.Is. Codes also match their POSIX equivalent
+// syscall.Errno, and through it fs.ErrNotExist, fs.ErrExist and
+// fs.ErrPermission where package os would.
+type ErrorCode uint8
+
+const (
+	_ ErrorCode = iota
+	ErrAccess
+	ErrAlready
+	ErrBadDescriptor
+	ErrBusy
+	ErrDeadlock
+	ErrQuota
+	ErrExist
+	ErrFileTooLarge
+	ErrIllegalByteSequence
+	ErrInProgress
+	ErrInterrupted
+	ErrInvalid
+	ErrIo
+	ErrIsDirectory
+	ErrLoop
+	ErrTooManyLinks
+	ErrMessageSize
+	ErrNameTooLong
+	ErrNoDevice
+	ErrNoEntry
+	ErrNoLock
+	ErrInsufficientMemory
+	ErrInsufficientSpace
+	ErrNotDirectory
+	ErrNotEmpty
+	ErrNotRecoverable
+	ErrUnsupported
+	ErrNoTty
+	ErrNoSuchDevice
+	ErrOverflow
+	ErrNotPermitted
+	ErrPipe
+	ErrReadOnly
+	ErrInvalidSeek
+	ErrTextFileBusy
+	ErrCrossDevice
+	ErrOther
+)
+
+var errorCodeMessages = [...]string{
+	ErrAccess:              "permission denied",
+	ErrAlready:             "operation already in progress",
+	ErrBadDescriptor:       "bad descriptor",
+	ErrBusy:                "device or resource busy",
+	ErrDeadlock:            "resource deadlock would occur",
+	ErrQuota:               "storage quota exceeded",
+	ErrExist:               "file exists",
+	ErrFileTooLarge:        "file too large",
+	ErrIllegalByteSequence: "illegal byte sequence",
+	ErrInProgress:          "operation in progress",
+	ErrInterrupted:         "interrupted",
+	ErrInvalid:             "invalid argument",
+	ErrIo:                  "input/output error",
+	ErrIsDirectory:         "is a directory",
+	ErrLoop:                "too many levels of symbolic links",
+	ErrTooManyLinks:        "too many links",
+	ErrMessageSize:         "message too large",
+	ErrNameTooLong:         "file name too long",
+	ErrNoDevice:            "no such device",
+	ErrNoEntry:             "no such file or directory",
+	ErrNoLock:              "no locks available",
+	ErrInsufficientMemory:  "not enough memory",
+	ErrInsufficientSpace:   "no space left on device",
+	ErrNotDirectory:        "not a directory",
+	ErrNotEmpty:            "directory not empty",
+	ErrNotRecoverable:      "state not recoverable",
+	ErrUnsupported:         "operation not supported",
+	ErrNoTty:               "inappropriate ioctl for device",
+	ErrNoSuchDevice:        "no such device or address",
+	ErrOverflow:            "value too large",
+	ErrNotPermitted:        "operation not permitted",
+	ErrPipe:                "broken pipe",
+	ErrReadOnly:            "read-only file system",
+	ErrInvalidSeek:         "invalid seek",
+	ErrTextFileBusy:        "text file busy",
+	ErrCrossDevice:         "invalid cross-device link",
+	ErrOther:               "other error",
+}
+
+// The POSIX equivalents, as documented by wasi:filesystem.
+var errorCodeErrnos = [...]syscall.Errno{
+	ErrAccess:              syscall.EACCES,
+	ErrAlready:             syscall.EALREADY,
+	ErrBadDescriptor:       syscall.EBADF,
+	ErrBusy:                syscall.EBUSY,
+	ErrDeadlock:            syscall.EDEADLK,
+	ErrQuota:               syscall.EDQUOT,
+	ErrExist:               syscall.EEXIST,
+	ErrFileTooLarge:        syscall.EFBIG,
+	ErrIllegalByteSequence: syscall.EILSEQ,
+	ErrInProgress:          syscall.EINPROGRESS,
+	ErrInterrupted:         syscall.EINTR,
+	ErrInvalid:             syscall.EINVAL,
+	ErrIo:                  syscall.EIO,
+	ErrIsDirectory:         syscall.EISDIR,
+	ErrLoop:                syscall.ELOOP,
+	ErrTooManyLinks:        syscall.EMLINK,
+	ErrMessageSize:         syscall.EMSGSIZE,
+	ErrNameTooLong:         syscall.ENAMETOOLONG,
+	ErrNoDevice:            syscall.ENODEV,
+	ErrNoEntry:             syscall.ENOENT,
+	ErrNoLock:              syscall.ENOLCK,
+	ErrInsufficientMemory:  syscall.ENOMEM,
+	ErrInsufficientSpace:   syscall.ENOSPC,
+	ErrNotDirectory:        syscall.ENOTDIR,
+	ErrNotEmpty:            syscall.ENOTEMPTY,
+	ErrNotRecoverable:      syscall.ENOTRECOVERABLE,
+	ErrUnsupported:         syscall.ENOTSUP,
+	ErrNoTty:               syscall.ENOTTY,
+	ErrNoSuchDevice:        syscall.ENXIO,
+	ErrOverflow:            syscall.EOVERFLOW,
+	ErrNotPermitted:        syscall.EPERM,
+	ErrPipe:                syscall.EPIPE,
+	ErrReadOnly:            syscall.EROFS,
+	ErrInvalidSeek:         syscall.ESPIPE,
+	ErrTextFileBusy:        syscall.ETXTBSY,
+	ErrCrossDevice:         syscall.EXDEV,
+}
+
+func (c ErrorCode) Error() string {
+	if int(c) < len(errorCodeMessages) && errorCodeMessages[c] != "" {
+		return errorCodeMessages[c]
+	}
+	return fmt.Sprintf("unknown error code %d", uint8(c))
+}
+
+// Reports whether the code matches target. Besides the code itself, this
+// matches the equivalent syscall.Errno and whatever it matches, as well as
+// errors.ErrUnsupported for ErrUnsupported, fs.ErrInvalid for ErrInvalid,
+// and fs.ErrPermission for ErrReadOnly, which is how hosts report writes to
+// read-only preopens.
+func (c ErrorCode) Is(target error) bool {
+	switch {
+	case c == ErrUnsupported && target == errors.ErrUnsupported,
+		c == ErrInvalid && target == fs.ErrInvalid,
+		c == ErrReadOnly && target == fs.ErrPermission:
+		return true
+	}
+	errno := c.Errno()
+	return errno != 0 && (target == errno || errno.Is(target))
+}
+
+// The POSIX equivalent of the code, or 0 if there is none.
+func (c ErrorCode) Errno() syscall.Errno {
+	if int(c) < len(errorCodeErrnos) {
+		return errorCodeErrnos[c]
+	}
+	return 0
+}
+
+// ErrOther along with the message the host provided for it.
+type otherError struct {
+	message string
+}
+
+func (e *otherError) Error() string {
+	return ErrOther.Error() + ": " + e.message
+}
+
+func (e *otherError) Unwrap() error {
+	return ErrOther
+}
+
+var (
+	errNegativeOffset      = errors.New("negative offset")
+	errWriteAtInAppendMode = errors.New("fs: invalid use of WriteAt on file opened with O_APPEND")
+)
+
+// Wrap an error returned by the host as a *fs.PathError, translating the
+// host's error code.
+func pathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: fromHostError(err)}
+}
+
+// The ErrorCode for each backend.FsErrorCode.
+var hostErrorCodes = [...]ErrorCode{
+	backend.FsErrorCodeAccess:              ErrAccess,
+	backend.FsErrorCodeAlready:             ErrAlready,
+	backend.FsErrorCodeBadDescriptor:       ErrBadDescriptor,
+	backend.FsErrorCodeBusy:                ErrBusy,
+	backend.FsErrorCodeDeadlock:            ErrDeadlock,
+	backend.FsErrorCodeQuota:               ErrQuota,
+	backend.FsErrorCodeExist:               ErrExist,
+	backend.FsErrorCodeFileTooLarge:        ErrFileTooLarge,
+	backend.FsErrorCodeIllegalByteSequence: ErrIllegalByteSequence,
+	backend.FsErrorCodeInProgress:          ErrInProgress,
+	backend.FsErrorCodeInterrupted:         ErrInterrupted,
+	backend.FsErrorCodeInvalid:             ErrInvalid,
+	backend.FsErrorCodeIo:                  ErrIo,
+	backend.FsErrorCodeIsDirectory:         ErrIsDirectory,
+	backend.FsErrorCodeLoop:                ErrLoop,
+	backend.FsErrorCodeTooManyLinks:        ErrTooManyLinks,
+	backend.FsErrorCodeMessageSize:         ErrMessageSize,
+	backend.FsErrorCodeNameTooLong:         ErrNameTooLong,
+	backend.FsErrorCodeNoDevice:            ErrNoDevice,
+	backend.FsErrorCodeNoEntry:             ErrNoEntry,
+	backend.FsErrorCodeNoLock:              ErrNoLock,
+	backend.FsErrorCodeInsufficientMemory:  ErrInsufficientMemory,
+	backend.FsErrorCodeInsufficientSpace:   ErrInsufficientSpace,
+	backend.FsErrorCodeNotDirectory:        ErrNotDirectory,
+	backend.FsErrorCodeNotEmpty:            ErrNotEmpty,
+	backend.FsErrorCodeNotRecoverable:      ErrNotRecoverable,
+	backend.FsErrorCodeUnsupported:         ErrUnsupported,
+	backend.FsErrorCodeNoTty:               ErrNoTty,
+	backend.FsErrorCodeNoSuchDevice:        ErrNoSuchDevice,
+	backend.FsErrorCodeOverflow:            ErrOverflow,
+	backend.FsErrorCodeNotPermitted:        ErrNotPermitted,
+	backend.FsErrorCodePipe:                ErrPipe,
+	backend.FsErrorCodeReadOnly:            ErrReadOnly,
+	backend.FsErrorCodeInvalidSeek:         ErrInvalidSeek,
+	backend.FsErrorCodeTextFileBusy:        ErrTextFileBusy,
+	backend.FsErrorCodeCrossDevice:         ErrCrossDevice,
+	backend.FsErrorCodeOther:               ErrOther,
+}
+
+// Convert an error returned by the host to an ErrorCode, keeping the message
+// attached to FsErrorCodeOther. Errors that do not carry a host error code,
+// such as those injected by a fake host, are passed through as is.
+func fromHostError(err error) error {
+	var hostErr *backend.FsError
+	if !errors.As(err, &hostErr) {
+		return err
+	}
+
+	if int(hostErr.Code) >= len(hostErrorCodes) {
+		return &otherError{message: fmt.Sprintf("unrecognized error code %d", hostErr.Code)}
+	}
+	code := hostErrorCodes[hostErr.Code]
+	if code == ErrOther && hostErr.Message != "" {
+		return &otherError{message: hostErr.Message}
+	}
+	return code
+}
@@ -0,0 +1,134 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"pkg/internal/backend"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestFromHostError(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  backend.FsErrorCode
+		want  ErrorCode
+		errno syscall.Errno
+		std   error
+	}{
+		{"access", backend.FsErrorCodeAccess, ErrAccess, syscall.EACCES, fs.ErrPermission},
+		{"already", backend.FsErrorCodeAlready, ErrAlready, syscall.EALREADY, nil},
+		{"bad descriptor", backend.FsErrorCodeBadDescriptor, ErrBadDescriptor, syscall.EBADF, nil},
+		{"busy", backend.FsErrorCodeBusy, ErrBusy, syscall.EBUSY, nil},
+		{"deadlock", backend.FsErrorCodeDeadlock, ErrDeadlock, syscall.EDEADLK, nil},
+		{"quota", backend.FsErrorCodeQuota, ErrQuota, syscall.EDQUOT, nil},
+		{"exist", backend.FsErrorCodeExist, ErrExist, syscall.EEXIST, fs.ErrExist},
+		{"file too large", backend.FsErrorCodeFileTooLarge, ErrFileTooLarge, syscall.EFBIG, nil},
+		{"illegal byte sequence", backend.FsErrorCodeIllegalByteSequence, ErrIllegalByteSequence, syscall.EILSEQ, nil},
+		{"in progress", backend.FsErrorCodeInProgress, ErrInProgress, syscall.EINPROGRESS, nil},
+		{"interrupted", backend.FsErrorCodeInterrupted, ErrInterrupted, syscall.EINTR, nil},
+		{"invalid", backend.FsErrorCodeInvalid, ErrInvalid, syscall.EINVAL, fs.ErrInvalid},
+		{"io", backend.FsErrorCodeIo, ErrIo, syscall.EIO, nil},
+		{"is directory", backend.FsErrorCodeIsDirectory, ErrIsDirectory, syscall.EISDIR, nil},
+		{"loop", backend.FsErrorCodeLoop, ErrLoop, syscall.ELOOP, nil},
+		{"too many links", backend.FsErrorCodeTooManyLinks, ErrTooManyLinks, syscall.EMLINK, nil},
+		{"message size", backend.FsErrorCodeMessageSize, ErrMessageSize, syscall.EMSGSIZE, nil},
+		{"name too long", backend.FsErrorCodeNameTooLong, ErrNameTooLong, syscall.ENAMETOOLONG, nil},
+		{"no device", backend.FsErrorCodeNoDevice, ErrNoDevice, syscall.ENODEV, nil},
+		{"no entry", backend.FsErrorCodeNoEntry, ErrNoEntry, syscall.ENOENT, fs.ErrNotExist},
+		{"no lock", backend.FsErrorCodeNoLock, ErrNoLock, syscall.ENOLCK, nil},
+		{"insufficient memory", backend.FsErrorCodeInsufficientMemory, ErrInsufficientMemory, syscall.ENOMEM, nil},
+		{"insufficient space", backend.FsErrorCodeInsufficientSpace, ErrInsufficientSpace, syscall.ENOSPC, nil},
+		{"not directory", backend.FsErrorCodeNotDirectory, ErrNotDirectory, syscall.ENOTDIR, nil},
+		{"not empty", backend.FsErrorCodeNotEmpty, ErrNotEmpty, syscall.ENOTEMPTY, fs.ErrExist},
+		{"not recoverable", backend.FsErrorCodeNotRecoverable, ErrNotRecoverable, syscall.ENOTRECOVERABLE, nil},
+		{"unsupported", backend.FsErrorCodeUnsupported, ErrUnsupported, syscall.ENOTSUP, errors.ErrUnsupported},
+		{"no tty", backend.FsErrorCodeNoTty, ErrNoTty, syscall.ENOTTY, nil},
+		{"no such device", backend.FsErrorCodeNoSuchDevice, ErrNoSuchDevice, syscall.ENXIO, nil},
+		{"overflow", backend.FsErrorCodeOverflow, ErrOverflow, syscall.EOVERFLOW, nil},
+		{"not permitted", backend.FsErrorCodeNotPermitted, ErrNotPermitted, syscall.EPERM, fs.ErrPermission},
+		{"pipe", backend.FsErrorCodePipe, ErrPipe, syscall.EPIPE, nil},
+		{"read only", backend.FsErrorCodeReadOnly, ErrReadOnly, syscall.EROFS, fs.ErrPermission},
+		{"invalid seek", backend.FsErrorCodeInvalidSeek, ErrInvalidSeek, syscall.ESPIPE, nil},
+		{"text file busy", backend.FsErrorCodeTextFileBusy, ErrTextFileBusy, syscall.ETXTBSY, nil},
+		{"cross device", backend.FsErrorCodeCrossDevice, ErrCrossDevice, syscall.EXDEV, nil},
+		{"other", backend.FsErrorCodeOther, ErrOther, 0, nil},
+	}
+
+	stdErrors := []error{fs.ErrNotExist, fs.ErrExist, fs.ErrPermission, fs.ErrInvalid, errors.ErrUnsupported}
+	seen := make(map[backend.FsErrorCode]bool)
+	for _, tt := range tests {
+		seen[tt.code] = true
+
+		t.Run(tt.name, func(t *testing.T) {
+			err := pathError("open", "data/file", &backend.FsError{Code: tt.code})
+
+			var pathErr *fs.PathError
+			if !errors.As(err, &pathErr) || pathErr.Op != "open" || pathErr.Path != "data/file" || pathErr.Err != tt.want {
+				t.Fatalf("error = %#v", err)
+			}
+			for code := range ErrOther + 1 {
+				if got := errors.Is(err, code); got != (code == tt.want) {
+					t.Errorf("errors.Is(err, %v) = %v", code, got)
+				}
+			}
+			if tt.errno != 0 && !errors.Is(err, tt.errno) {
+				t.Errorf("expected error to match %v", tt.errno)
+			}
+			if tt.want.Errno() != tt.errno {
+				t.Errorf("Errno() = %v, expected %v", tt.want.Errno(), tt.errno)
+			}
+			for _, std := range stdErrors {
+				if got := errors.Is(err, std); got != (std == tt.std) {
+					t.Errorf("errors.Is(err, %v) = %v", std, got)
+				}
+			}
+
+			expected := "open data/file: " + tt.want.Error()
+			if err.Error() != expected {
+				t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+			}
+			if strings.HasPrefix(tt.want.Error(), "unknown") {
+				t.Errorf("error code %d has no message", uint8(tt.want))
+			}
+		})
+	}
+
+	for code := range backend.FsErrorCodeOther + 1 {
+		if !seen[code] {
+			t.Errorf("no test case for error code %d", code)
+		}
+	}
+}
+
+func TestOtherErrorMessage(t *testing.T) {
+	err := pathError("stat", "x", &backend.FsError{Code: backend.FsErrorCodeOther, Message: "disk on fire"})
+
+	if !errors.Is(err, ErrOther) {
+		t.Errorf("expected error to match ErrOther")
+	}
+	if expected := "stat x: other error: disk on fire"; err.Error() != expected {
+		t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestUnrecognizedHostError(t *testing.T) {
+	err := pathError("stat", "x", &backend.FsError{Code: backend.FsErrorCodeOther + 1})
+
+	if !errors.Is(err, ErrOther) {
+		t.Errorf("expected error to match ErrOther")
+	}
+	if expected := "stat x: other error: unrecognized error code 37"; err.Error() != expected {
+		t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestNonHostErrorPassesThrough(t *testing.T) {
+	cause := errors.New("injected")
+	err := pathError("read", "x", cause)
+
+	if err.(*fs.PathError).Err != cause {
+		t.Errorf("Err = %v", err.(*fs.PathError).Err)
+	}
+}
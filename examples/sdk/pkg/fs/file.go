@@ -0,0 +1,407 @@
+package fs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"pkg/internal/backend"
+	"sync"
+)
+
+// An open file or directory. Reads and writes happen at an offset the File
+// keeps track of, through streams that stay open between calls. Files are
+// safe to use from multiple goroutines.
+type File struct {
+	// The name the file was opened by, used in errors.
+	name string
+
+	// The preopen the file was opened through, and its path relative to it,
+	// used to describe directory entries.
+	dir *Dir
+	rel string
+
+	desc backend.Descriptor
+
+	// Set for files opened with os.O_APPEND, whose writes go to the end.
+	append bool
+
+	mu      sync.Mutex
+	closed  bool
+	offset  int64
+	reader  backend.InputStream
+	writer  backend.OutputStream
+	entries backend.DirectoryEntryStream
+}
+
+var (
+	_ fs.ReadDirFile  = (*File)(nil)
+	_ io.ReaderAt     = (*File)(nil)
+	_ io.WriterAt     = (*File)(nil)
+	_ io.Seeker       = (*File)(nil)
+	_ io.StringWriter = (*File)(nil)
+)
+
+func newFile(dir *Dir, name, rel string, desc backend.Descriptor) *File {
+	return &File{name: name, dir: dir, rel: rel, desc: desc}
+}
+
+// The name the file was opened by.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Read from the file's current offset.
+func (f *File) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, f.closedError("read")
+	}
+	if err := f.finishWrite("read"); err != nil {
+		return 0, err
+	}
+	if f.reader == nil {
+		f.reader = f.desc.ReadViaStream(uint64(f.offset))
+	}
+	n, err := f.reader.Read(b)
+	f.offset += int64(n)
+	if err != nil && err != io.EOF {
+		err = pathError("read", f.name, err)
+	}
+	return n, err
+}
+
+// Describe the file.
+func (f *File) Stat() (fs.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, f.closedError("stat")
+	}
+	if err := f.finishWrite("stat"); err != nil {
+		return nil, err
+	}
+	stat, err := f.desc.Stat()
+	if err != nil {
+		return nil, pathError("stat", f.name, err)
+	}
+	return newFileInfo(path.Base(f.name), stat), nil
+}
+
+// Read len(b) bytes from off, without using or changing the file's offset.
+// Returns io.EOF if the file ends first.
+func (f *File) ReadAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, f.closedError("read")
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "readat", Path: f.name, Err: errNegativeOffset}
+	}
+	if err := f.finishWrite("read"); err != nil {
+		return 0, err
+	}
+
+	stream := f.desc.ReadViaStream(uint64(off))
+	defer stream.Drop()
+	n, err := io.ReadFull(stream, b)
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		err = io.EOF
+	case io.EOF:
+	default:
+		err = pathError("read", f.name, err)
+	}
+	return n, err
+}
+
+// Write at the file's offset, or at its end if it was opened with
+// os.O_APPEND. Data may reach the host after Write returns; Sync, Close and
+// any other call on the file wait for it, and report if it failed.
+func (f *File) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, f.closedError("write")
+	}
+	if f.reader != nil {
+		// The stream may have read ahead of the offset, so it cannot be
+		// reused once the file changes.
+		f.reader.Drop()
+		f.reader = nil
+	}
+	if f.writer == nil {
+		if f.append {
+			f.writer = f.desc.AppendViaStream()
+		} else {
+			f.writer = f.desc.WriteViaStream(uint64(f.offset))
+		}
+	}
+
+	n, err := f.writer.Write(b)
+	f.offset += int64(n)
+	if err != nil {
+		f.writer.Drop()
+		f.writer = nil
+		return n, pathError("write", f.name, err)
+	}
+	return n, nil
+}
+
+func (f *File) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+// Write b at off, without using or changing the file's offset. Not allowed
+// for files opened with os.O_APPEND.
+func (f *File) WriteAt(b []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, f.closedError("write")
+	}
+	if f.append {
+		return 0, errWriteAtInAppendMode
+	}
+	if off < 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: f.name, Err: errNegativeOffset}
+	}
+	if err := f.finishWrite("write"); err != nil {
+		return 0, err
+	}
+	if f.reader != nil {
+		f.reader.Drop()
+		f.reader = nil
+	}
+
+	stream := f.desc.WriteViaStream(uint64(off))
+	n, err := stream.Write(b)
+	if err != nil {
+		stream.Drop()
+		return n, pathError("write", f.name, err)
+	}
+	if err := stream.Close(); err != nil {
+		return n, pathError("write", f.name, err)
+	}
+	return n, nil
+}
+
+// Set the offset for the next Read or Write, interpreted according to
+// whence, and return the new offset.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, f.closedError("seek")
+	}
+	if err := f.finishWrite("seek"); err != nil {
+		return 0, err
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		stat, err := f.desc.Stat()
+		if err != nil {
+			return 0, pathError("seek", f.name, err)
+		}
+		offset += int64(stat.Size)
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if offset != f.offset && f.reader != nil {
+		f.reader.Drop()
+		f.reader = nil
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Wait for pending writes, then ask the host to commit the file's data and
+// metadata to storage.
+func (f *File) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.closedError("sync")
+	}
+	if err := f.finishWrite("sync"); err != nil {
+		return err
+	}
+	if err := f.desc.Sync(); err != nil {
+		return pathError("sync", f.name, err)
+	}
+	return nil
+}
+
+// Change the size of the file. The offset is left as is.
+func (f *File) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.closedError("truncate")
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if err := f.finishWrite("truncate"); err != nil {
+		return err
+	}
+	if f.reader != nil {
+		f.reader.Drop()
+		f.reader = nil
+	}
+	if err := f.desc.SetSize(uint64(size)); err != nil {
+		return pathError("truncate", f.name, err)
+	}
+	return nil
+}
+
+// Finish the stream left open by earlier writes and wait for the host to
+// report how it went. For files opened with os.O_APPEND, the offset moves to
+// the end of the file, where the writes went. Must be called with f.mu held.
+func (f *File) finishWrite(op string) error {
+	if f.writer == nil {
+		return nil
+	}
+	err := f.writer.Close()
+	f.writer = nil
+	if err != nil {
+		return pathError(op, f.name, err)
+	}
+
+	if f.append {
+		stat, err := f.desc.Stat()
+		if err != nil {
+			return pathError(op, f.name, err)
+		}
+		f.offset = int64(stat.Size)
+	}
+	return nil
+}
+
+// List the directory's entries, in the order the host provides them. Like
+// os.File.ReadDir, n > 0 returns at most n entries and io.EOF at the end of
+// the directory, while n <= 0 returns all the remaining entries.
+func (f *File) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil, f.closedError("readdir")
+	}
+	if f.entries == nil {
+		f.entries = f.desc.ReadDirectory()
+	}
+
+	var entries []fs.DirEntry
+	for n <= 0 || len(entries) < n {
+		entry, err := f.entries.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return entries, pathError("readdir", f.name, err)
+		}
+		entries = append(entries, &dirEntry{dir: f.dir, rel: path.Join(f.rel, entry.Name), entry: entry})
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+// Wait for pending writes, then release the file. Returns the error that
+// stopped pending writes, if any. Closing it again returns an error.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return f.closedError("close")
+	}
+	f.closed = true
+	err := f.finishWrite("close")
+	f.dropStreams()
+	f.desc.Drop()
+	return err
+}
+
+// Release the streams opened by earlier calls. Must be called with f.mu held.
+func (f *File) dropStreams() {
+	if f.reader != nil {
+		f.reader.Drop()
+		f.reader = nil
+	}
+	if f.writer != nil {
+		f.writer.Drop()
+		f.writer = nil
+	}
+	if f.entries != nil {
+		f.entries.Drop()
+		f.entries = nil
+	}
+}
+
+func (f *File) closedError(op string) error {
+	return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+}
+
+// A directory above the preopens in a Root, which lists the preopens below
+// it and has no other content.
+type mountDir struct {
+	root   *Root
+	name   string
+	mounts []string
+	read   int
+	closed bool
+}
+
+func (m *mountDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: m.name, Err: ErrIsDirectory}
+}
+
+func (m *mountDir) Stat() (fs.FileInfo, error) {
+	return mountInfo(path.Base(m.name)), nil
+}
+
+func (m *mountDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := m.mounts[m.read:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	m.read += len(rest)
+
+	entries := make([]fs.DirEntry, len(rest))
+	for i, name := range rest {
+		entries[i] = m.root.mountEntry(m.name, name)
+	}
+	return entries, nil
+}
+
+func (m *mountDir) Close() error {
+	if m.closed {
+		return &fs.PathError{Op: "close", Path: m.name, Err: fs.ErrClosed}
+	}
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,366 @@
+// Package fs provides the directories the host has preopened through
+// wasi:filesystem as io/fs file systems, so that fs.WalkDir,
+// template.ParseFS, http.FileServerFS and the like work with them.
+//
+// Each preopen is available on its own as a Dir, and all of them together as
+// a Root, which merges them into one tree rooted at "/".
+//
+// For writing, functions such as Create, OpenFile, Mkdir and Rename work like
+// their namesakes in package os, on paths within the preopened directories.
+// A path is looked up in the preopen with the longest path containing it, as
+// described for Resolver; relative paths are taken to be relative to the
+// host's initial working directory. wasi:filesystem has no permissions, so
+// perm arguments are ignored.
+//
+// Copy streams large files to other files, connections and HTTP responses,
+// letting the host copy between files by itself.
+//
+// Errors are reported as *fs.PathError or *os.LinkError wrapping the host's
+// ErrorCode, which matches fs.ErrNotExist, fs.ErrExist and the like.
+package fs
+
+import (
+	"cmp"
+	"io"
+	"io/fs"
+	"path"
+	"pkg/internal/backend"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// A directory the host has preopened. Names passed to its methods are
+// relative to the directory, and may not leave it.
+type Dir struct {
+	path   string
+	desc   backend.Descriptor
+	closed atomic.Bool
+}
+
+var (
+	_ fs.ReadDirFS  = (*Dir)(nil)
+	_ fs.StatFS     = (*Dir)(nil)
+	_ fs.ReadFileFS = (*Dir)(nil)
+	_ fs.ReadLinkFS = (*Dir)(nil)
+)
+
+// Open a Dir for each directory the host has preopened, in the order the
+// host lists them. The caller should Close them once done.
+func Preopens() []*Dir {
+	preopens := backend.Current().GetDirectories()
+	dirs := make([]*Dir, len(preopens))
+	for i, p := range preopens {
+		dirs[i] = &Dir{path: backend.CleanPreopenPath(p.Path), desc: p.Descriptor}
+	}
+	return dirs
+}
+
+// The absolute path the host gave the directory.
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// Release the directory. Files opened from it stay usable, but the entries
+// they list can no longer report their Info, which fails with fs.ErrClosed
+// like the Dir's own methods.
+func (d *Dir) Close() error {
+	if d.closed.Swap(true) {
+		return &fs.PathError{Op: "close", Path: d.path, Err: fs.ErrClosed}
+	}
+	d.desc.Drop()
+	return nil
+}
+
+// Open the named file or directory for reading.
+func (d *Dir) Open(name string) (fs.File, error) {
+	return d.open("open", name)
+}
+
+func (d *Dir) open(op, name string) (*File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if d.closed.Load() {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrClosed}
+	}
+	desc, err := d.desc.OpenAt(backend.PathFlagsSymlinkFollow, name, 0, backend.DescriptorFlagsRead)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	return newFile(d, name, name, desc), nil
+}
+
+// Describe the named file, following symbolic links.
+func (d *Dir) Stat(name string) (fs.FileInfo, error) {
+	return d.stat("stat", name, backend.PathFlagsSymlinkFollow)
+}
+
+// Describe the named file without following a final symbolic link.
+func (d *Dir) Lstat(name string) (fs.FileInfo, error) {
+	return d.stat("lstat", name, 0)
+}
+
+func (d *Dir) stat(op, name string, flags backend.PathFlags) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if d.closed.Load() {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrClosed}
+	}
+
+	var stat backend.DescriptorStat
+	var err error
+	if name == "." {
+		stat, err = d.desc.Stat()
+	} else {
+		stat, err = d.desc.StatAt(flags, name)
+	}
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+	return newFileInfo(path.Base(name), stat), nil
+}
+
+// The target of the named symbolic link.
+func (d *Dir) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	if d.closed.Load() {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrClosed}
+	}
+	target, err := d.desc.ReadlinkAt(name)
+	if err != nil {
+		return "", pathError("readlink", name, err)
+	}
+	return target, nil
+}
+
+// List the named directory, sorted by name.
+func (d *Dir) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := d.open("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries, err := f.ReadDir(-1)
+	slices.SortFunc(entries, compareEntries)
+	return entries, err
+}
+
+// Read the whole of the named file.
+func (d *Dir) ReadFile(name string) ([]byte, error) {
+	f, err := d.open("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func compareEntries(a, b fs.DirEntry) int {
+	return strings.Compare(a.Name(), b.Name())
+}
+
+// All the host's preopened directories, merged into one tree rooted at "/".
+// A name is looked up in the preopen with the longest path containing it.
+// Directories above the preopens, such as "/" itself when only "/data" is
+// preopened, appear as empty directories that list the preopens below them.
+type Root struct {
+	// Sorted by path, longest first.
+	dirs []*Dir
+}
+
+var (
+	_ fs.ReadDirFS  = (*Root)(nil)
+	_ fs.StatFS     = (*Root)(nil)
+	_ fs.ReadFileFS = (*Root)(nil)
+	_ fs.ReadLinkFS = (*Root)(nil)
+)
+
+// Open the host's preopened directories as a Root. The caller should Close
+// it once done.
+func NewRoot() *Root {
+	dirs := Preopens()
+	slices.SortStableFunc(dirs, func(a, b *Dir) int {
+		return cmp.Compare(len(b.path), len(a.path))
+	})
+	return &Root{dirs: dirs}
+}
+
+// The preopened directories making up the root.
+func (r *Root) Dirs() []*Dir {
+	return slices.Clone(r.dirs)
+}
+
+// Release the preopened directories.
+func (r *Root) Close() error {
+	for _, d := range r.dirs {
+		d.Close()
+	}
+	return nil
+}
+
+// Find the preopen containing name, and name's path relative to it. Returns
+// a nil Dir if no preopen contains name.
+func (r *Root) resolve(name string) (*Dir, string) {
+	paths := make([]string, len(r.dirs))
+	for i, d := range r.dirs {
+		paths[i] = d.path
+	}
+	i, rel := backend.MatchPreopen(paths, name)
+	if i < 0 {
+		return nil, ""
+	}
+	return r.dirs[i], rel
+}
+
+// The names of the preopens directly below the directory name, if name is
+// one of their ancestors.
+func (r *Root) mounts(name string) []string {
+	dir := path.Join("/", name)
+	var names []string
+	for _, d := range r.dirs {
+		if d.path == dir || !strings.HasPrefix(d.path, strings.TrimSuffix(dir, "/")+"/") {
+			continue
+		}
+		child, _, _ := strings.Cut(strings.TrimPrefix(d.path, strings.TrimSuffix(dir, "/")+"/"), "/")
+		if !slices.Contains(names, child) {
+			names = append(names, child)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open the named file or directory for reading. Names are relative to "/".
+func (r *Root) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	d, rel := r.resolve(name)
+	if d == nil {
+		if mounts := r.mounts(name); len(mounts) > 0 {
+			return &mountDir{root: r, name: name, mounts: mounts}, nil
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	f, err := d.open("open", rel)
+	if err != nil {
+		return nil, renamePathError(err, name)
+	}
+	f.name = name
+	return f, nil
+}
+
+// Describe the named file, following symbolic links.
+func (r *Root) Stat(name string) (fs.FileInfo, error) {
+	return r.stat("stat", name, (*Dir).Stat)
+}
+
+// Describe the named file without following a final symbolic link.
+func (r *Root) Lstat(name string) (fs.FileInfo, error) {
+	return r.stat("lstat", name, (*Dir).Lstat)
+}
+
+func (r *Root) stat(op, name string, stat func(*Dir, string) (fs.FileInfo, error)) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	d, rel := r.resolve(name)
+	if d == nil {
+		if len(r.mounts(name)) > 0 {
+			return mountInfo(path.Base(name)), nil
+		}
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	info, err := stat(d, rel)
+	if err != nil {
+		return nil, renamePathError(err, name)
+	}
+	if rel == "." {
+		info = renamedInfo{info, path.Base(name)}
+	}
+	return info, nil
+}
+
+// List the named directory, sorted by name. Preopens mounted directly below
+// the directory are listed too, even if the directory holding them does not
+// have entries for them.
+func (r *Root) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	var entries []fs.DirEntry
+	d, rel := r.resolve(name)
+	if d != nil {
+		var err error
+		entries, err = d.ReadDir(rel)
+		if err != nil {
+			return nil, renamePathError(err, name)
+		}
+	}
+
+	mounts := r.mounts(name)
+	if d == nil && len(mounts) == 0 {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	for _, m := range mounts {
+		if !slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return e.Name() == m }) {
+			entries = append(entries, r.mountEntry(name, m))
+		}
+	}
+	slices.SortFunc(entries, compareEntries)
+	return entries, nil
+}
+
+// The entry for the directory m below dir, which leads to a preopen.
+func (r *Root) mountEntry(dir, m string) fs.DirEntry {
+	info, err := r.Stat(path.Join(dir, m))
+	if err != nil {
+		info = mountInfo(m)
+	}
+	return fs.FileInfoToDirEntry(info)
+}
+
+// The target of the named symbolic link.
+func (r *Root) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	d, rel := r.resolve(name)
+	if d == nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	target, err := d.ReadLink(rel)
+	return target, renamePathError(err, name)
+}
+
+// Read the whole of the named file.
+func (r *Root) ReadFile(name string) ([]byte, error) {
+	f, err := r.Open(name)
+	if err != nil {
+		return nil, renamePathOp(err, "readfile")
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Report err, a *fs.PathError for a path within a preopen, under the name
+// the caller used.
+func renamePathError(err error, name string) error {
+	if pathErr, ok := err.(*fs.PathError); ok {
+		pathErr.Path = name
+	}
+	return err
+}
+
+func renamePathOp(err error, op string) error {
+	if pathErr, ok := err.(*fs.PathError); ok {
+		pathErr.Op = op
+	}
+	return err
+}
@@ -0,0 +1,250 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"pkg/fake"
+	"pkg/internal/backend"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"text/template"
+	"time"
+)
+
+// Install a fake host with a few preopened directories:
+//
+//	/data             preopen
+//	/data/hello.txt
+//	/data/sub/a.txt
+//	/data/sub/link -> a.txt
+//	/srv/www          preopen (read-only)
+//	/srv/www/index.html
+func setup(t *testing.T) *fake.Host {
+	t.Helper()
+
+	host := fake.Install(t)
+	host.Preopen("/data")
+	host.PreopenReadOnly("/srv/www")
+	host.WriteFile("/data/hello.txt", []byte("hello, world"))
+	host.WriteFile("/data/sub/a.txt", []byte("a"))
+	host.Symlink("a.txt", "/data/sub/link")
+	host.WriteFile("/srv/www/index.html", []byte("<h1>{{.}}</h1>"))
+	return host
+}
+
+func TestPreopens(t *testing.T) {
+	setup(t)
+
+	dirs := Preopens()
+	defer func() {
+		for _, d := range dirs {
+			d.Close()
+		}
+	}()
+	paths := make([]string, len(dirs))
+	for i, d := range dirs {
+		paths[i] = d.Path()
+	}
+	if !slices.Equal(paths, []string{"/data", "/srv/www"}) {
+		t.Fatalf("preopens = %q", paths)
+	}
+
+	if err := fstest.TestFS(dirs[0], "hello.txt", "sub/a.txt", "sub/link"); err != nil {
+		t.Error(err)
+	}
+	if err := fstest.TestFS(dirs[1], "index.html"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRoot(t *testing.T) {
+	setup(t)
+
+	root := NewRoot()
+	defer root.Close()
+	if err := fstest.TestFS(root, "data/hello.txt", "data/sub/a.txt", "srv/www/index.html"); err != nil {
+		t.Error(err)
+	}
+
+	entries, err := root.ReadDir(".")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "data" || entries[1].Name() != "srv" || !entries[1].IsDir() {
+		t.Errorf("ReadDir(.) = %v, %v", entries, err)
+	}
+	if entries, err := fs.ReadDir(root, "srv"); err != nil || len(entries) != 1 || entries[0].Name() != "www" {
+		t.Errorf("ReadDir(srv) = %v, %v", entries, err)
+	}
+
+	tmpl, err := template.ParseFS(root, "srv/www/*.html")
+	if err != nil {
+		t.Fatalf("ParseFS: %v", err)
+	}
+	if tmpl.Name() != "index.html" {
+		t.Errorf("template name = %q", tmpl.Name())
+	}
+}
+
+func TestFileInfo(t *testing.T) {
+	host := setup(t)
+	host.Advance(time.Hour)
+	host.WriteFile("/data/sub/new.txt", []byte("new"))
+
+	root := NewRoot()
+	defer root.Close()
+
+	info, err := root.Stat("data/sub/new.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Name() != "new.txt" || info.Size() != 3 || info.Mode() != 0o644 || info.IsDir() {
+		t.Errorf("Stat() = %v %d %v %v", info.Name(), info.Size(), info.Mode(), info.IsDir())
+	}
+	stat := info.Sys().(*FileStat)
+	if stat.LinkCount != 1 || !info.ModTime().Equal(stat.ModTime) || info.ModTime().IsZero() {
+		t.Errorf("Sys() = %+v, ModTime() = %v", stat, info.ModTime())
+	}
+
+	if info, err := root.Stat("data/sub"); err != nil || info.Name() != "sub" || info.Mode() != fs.ModeDir|0o755 {
+		t.Errorf("Stat(data/sub) = %v, %v", info, err)
+	}
+	if info, err := root.Stat("data"); err != nil || info.Name() != "data" || !info.IsDir() {
+		t.Errorf("Stat(data) = %v, %v", info, err)
+	}
+
+	entries, err := root.ReadDir("data/sub")
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var link fs.DirEntry
+	for _, e := range entries {
+		if e.Name() == "link" {
+			link = e
+		}
+	}
+	if link == nil || link.Type() != fs.ModeSymlink {
+		t.Fatalf("entries = %v", entries)
+	}
+	if info, err := link.Info(); err != nil || info.Mode()&fs.ModeSymlink == 0 || info.Name() != "link" {
+		t.Errorf("link Info() = %v, %v", info, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	host := setup(t)
+
+	root := NewRoot()
+	defer root.Close()
+
+	for _, test := range []struct {
+		op, name string
+		err      error
+		call     func(string) error
+	}{
+		{"open", "data/missing", fs.ErrNotExist, func(name string) error { _, err := root.Open(name); return err }},
+		{"open", "elsewhere", fs.ErrNotExist, func(name string) error { _, err := root.Open(name); return err }},
+		{"open", "/data/hello.txt", fs.ErrInvalid, func(name string) error { _, err := root.Open(name); return err }},
+		{"stat", "data/sub/missing", fs.ErrNotExist, func(name string) error { _, err := root.Stat(name); return err }},
+		{"readdir", "data/hello.txt", nil, func(name string) error { _, err := root.ReadDir(name); return err }},
+		{"readfile", "srv/missing", fs.ErrNotExist, func(name string) error { _, err := root.ReadFile(name); return err }},
+	} {
+		err := test.call(test.name)
+		var pathErr *fs.PathError
+		if !errors.As(err, &pathErr) || pathErr.Op != test.op || pathErr.Path != test.name {
+			t.Errorf("%s %s: error = %v", test.op, test.name, err)
+			continue
+		}
+		if test.err != nil && !errors.Is(err, test.err) {
+			t.Errorf("%s %s: error = %v, expected %v", test.op, test.name, err, test.err)
+		}
+	}
+
+	host.InjectError("descriptor.open-at", &backend.FsError{Code: backend.FsErrorCodeAccess})
+	if _, err := root.Open("data/hello.txt"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Open error = %v", err)
+	}
+
+	f, err := root.Open("data/hello.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	f.Close()
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Read after Close error = %v", err)
+	}
+	if err := f.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("second Close error = %v", err)
+	}
+}
+
+func TestDirClosed(t *testing.T) {
+	setup(t)
+
+	dirs := Preopens()
+	d := dirs[0]
+	dirs[1].Close()
+
+	f, err := d.Open("sub")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	d.Close()
+
+	for _, test := range []struct {
+		op   string
+		call func() error
+	}{
+		{"open", func() error { _, err := d.Open("hello.txt"); return err }},
+		{"stat", func() error { _, err := d.Stat("hello.txt"); return err }},
+		{"lstat", func() error { _, err := d.Lstat("sub/link"); return err }},
+		{"readlink", func() error { _, err := d.ReadLink("sub/link"); return err }},
+		{"readdir", func() error { _, err := d.ReadDir("."); return err }},
+		{"readfile", func() error { _, err := d.ReadFile("hello.txt"); return err }},
+		{"close", d.Close},
+	} {
+		err := test.call()
+		var pathErr *fs.PathError
+		if !errors.Is(err, fs.ErrClosed) || !errors.As(err, &pathErr) || pathErr.Op != test.op {
+			t.Errorf("%s after Close error = %v", test.op, err)
+		}
+	}
+
+	// Files opened earlier keep working, but their entries cannot be
+	// described any more.
+	entries, err := f.(fs.ReadDirFile).ReadDir(-1)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("ReadDir() = %v, %v", entries, err)
+	}
+	if _, err := entries[0].Info(); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("Info error = %v", err)
+	}
+}
+
+func TestFileRead(t *testing.T) {
+	setup(t)
+
+	dirs := Preopens()
+	defer dirs[1].Close()
+
+	f, err := dirs[0].Open("hello.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	buf := make([]byte, 5)
+	if n, err := io.ReadFull(f, buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("ReadFull() = %q, %v", buf[:n], err)
+	}
+	rest, err := io.ReadAll(f)
+	if err != nil || string(rest) != ", world" {
+		t.Errorf("ReadAll() = %q, %v", rest, err)
+	}
+
+	// The directory is released, but the file is still usable.
+	dirs[0].Close()
+	if _, err := f.Stat(); err != nil {
+		t.Errorf("Stat after closing the Dir: %v", err)
+	}
+}
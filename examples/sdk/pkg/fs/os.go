@@ -0,0 +1,372 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"pkg/internal/backend"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The Resolver the package functions use, opened for the current host.
+var current struct {
+	mu       sync.Mutex
+	host     backend.Host
+	resolver *Resolver
+}
+
+func currentResolver() *Resolver {
+	current.mu.Lock()
+	defer current.mu.Unlock()
+
+	h := backend.Current()
+	if current.resolver == nil || current.host != h {
+		if current.resolver != nil {
+			current.resolver.root.Close()
+		}
+		current.host, current.resolver = h, NewResolver(NewRoot())
+	}
+	return current.resolver
+}
+
+// The Root the package functions use, and the name a path has within it.
+func rootName(op, name string) (*Root, string, error) {
+	r := currentResolver()
+	abs, err := r.Abs(name)
+	if err != nil {
+		return nil, "", renamePathOp(err, op)
+	}
+	if abs == "/" {
+		return r.root, ".", nil
+	}
+	return r.root, abs[1:], nil
+}
+
+// Find the preopen containing name, and name's path relative to it.
+func resolve(op, name string) (*Dir, string, error) {
+	d, rel, err := currentResolver().Resolve(name)
+	if err != nil {
+		return nil, "", renamePathOp(err, op)
+	}
+	return d, rel, nil
+}
+
+// Like resolve, for operations on directory entries, which cannot be applied
+// to a preopen itself.
+func resolveEntry(op, name string) (*Dir, string, error) {
+	d, rel, err := resolve(op, name)
+	if err == nil && rel == "." {
+		err = &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return d, rel, err
+}
+
+// Open the named file for reading.
+func Open(name string) (*File, error) {
+	return OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Create or truncate the named file, opening it for reading and writing.
+func Create(name string) (*File, error) {
+	return OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+}
+
+// Open the named file with the os.O_* flags in flag. os.O_SYNC asks the host
+// to write data and metadata through before each write completes.
+//
+// perm is ignored: wasi:filesystem has no mode bits, so a file created with
+// 0o600 is as accessible as any other, and the host decides who can read it.
+func OpenFile(name string, flag int, perm fs.FileMode) (*File, error) {
+	d, rel, err := resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	var descFlags backend.DescriptorFlags
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		descFlags = backend.DescriptorFlagsRead
+	case os.O_WRONLY:
+		descFlags = backend.DescriptorFlagsWrite
+	case os.O_RDWR:
+		descFlags = backend.DescriptorFlagsRead | backend.DescriptorFlagsWrite
+	}
+	if flag&os.O_SYNC != 0 {
+		descFlags |= backend.DescriptorFlagsFileIntegritySync
+	}
+
+	var openFlags backend.OpenFlags
+	if flag&os.O_CREATE != 0 {
+		openFlags |= backend.OpenFlagsCreate
+	}
+	if flag&os.O_EXCL != 0 {
+		openFlags |= backend.OpenFlagsExclusive
+	}
+	if flag&os.O_TRUNC != 0 {
+		openFlags |= backend.OpenFlagsTruncate
+	}
+
+	desc, err := d.desc.OpenAt(backend.PathFlagsSymlinkFollow, rel, openFlags, descFlags)
+	if err != nil {
+		return nil, pathError("open", name, err)
+	}
+	f := newFile(d, name, rel, desc)
+	f.append = flag&os.O_APPEND != 0
+	return f, nil
+}
+
+// Read the whole of the named file.
+func ReadFile(name string) ([]byte, error) {
+	f, err := Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Write data to the named file, creating it if needed and truncating it
+// otherwise. As with OpenFile, perm is ignored.
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Describe the named file, following symbolic links.
+func Stat(name string) (fs.FileInfo, error) {
+	root, rel, err := rootName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := root.Stat(rel)
+	return info, renamePathError(err, name)
+}
+
+// Describe the named file without following a final symbolic link.
+func Lstat(name string) (fs.FileInfo, error) {
+	root, rel, err := rootName("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := root.Lstat(rel)
+	return info, renamePathError(err, name)
+}
+
+// List the named directory, sorted by name.
+func ReadDir(name string) ([]fs.DirEntry, error) {
+	root, rel, err := rootName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := root.ReadDir(rel)
+	return entries, renamePathError(err, name)
+}
+
+// The target of the named symbolic link.
+func Readlink(name string) (string, error) {
+	root, rel, err := rootName("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	target, err := root.ReadLink(rel)
+	return target, renamePathError(err, name)
+}
+
+// Create the named directory. perm is ignored, since wasi:filesystem
+// directories have no mode bits.
+func Mkdir(name string, perm fs.FileMode) error {
+	d, rel, err := resolveEntry("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if err := d.desc.CreateDirectoryAt(rel); err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return nil
+}
+
+// Create the named directory along with any missing parents. Returns nil if
+// the directory already exists. Like Mkdir, it ignores perm.
+func MkdirAll(name string, perm fs.FileMode) error {
+	if info, err := Stat(name); err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: ErrNotDirectory}
+	}
+
+	if parent := path.Dir(strings.TrimSuffix(name, "/")); parent != name && parent != "." && parent != "/" {
+		if err := MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+
+	err := Mkdir(name, perm)
+	if err != nil {
+		// Someone else may have created it in the meantime.
+		if info, statErr := Lstat(name); statErr == nil && info.IsDir() {
+			return nil
+		}
+	}
+	return err
+}
+
+// Remove the named file or empty directory.
+func Remove(name string) error {
+	d, rel, err := resolveEntry("remove", name)
+	if err != nil {
+		return err
+	}
+	unlinkErr := d.desc.UnlinkFileAt(rel)
+	if unlinkErr == nil {
+		return nil
+	}
+	rmdirErr := d.desc.RemoveDirectoryAt(rel)
+	if rmdirErr == nil {
+		return nil
+	}
+
+	// Hosts disagree on what unlinking a directory reports, but removing a
+	// file as a directory always reports that it is not one, so use that to
+	// decide which error is the real one, as package os does.
+	if fromHostError(rmdirErr) == ErrNotDirectory {
+		return pathError("remove", name, unlinkErr)
+	}
+	return pathError("remove", name, rmdirErr)
+}
+
+// Remove the named file or directory, along with anything it contains.
+// Returns nil if it does not exist.
+func RemoveAll(name string) error {
+	info, err := Lstat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		entries, err := ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := RemoveAll(path.Join(name, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	err = Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Rename oldpath to newpath, replacing newpath if it exists and is not a
+// directory. Hosts may refuse to rename across preopens.
+func Rename(oldpath, newpath string) error {
+	return linkOp("rename", oldpath, newpath, func(oldDir *Dir, oldRel string, newDir *Dir, newRel string) error {
+		return oldDir.desc.RenameAt(oldRel, newDir.desc, newRel)
+	})
+}
+
+// Create newname as a hard link to oldname.
+func Link(oldname, newname string) error {
+	return linkOp("link", oldname, newname, func(oldDir *Dir, oldRel string, newDir *Dir, newRel string) error {
+		return oldDir.desc.LinkAt(0, oldRel, newDir.desc, newRel)
+	})
+}
+
+// Resolve both paths of an operation on two of them, reporting errors as
+// *os.LinkError.
+func linkOp(op, oldname, newname string, apply func(*Dir, string, *Dir, string) error) error {
+	oldDir, oldRel, err := resolveEntry(op, oldname)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrapPathError(err)}
+	}
+	newDir, newRel, err := resolveEntry(op, newname)
+	if err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: unwrapPathError(err)}
+	}
+	if err := apply(oldDir, oldRel, newDir, newRel); err != nil {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fromHostError(err)}
+	}
+	return nil
+}
+
+// The cause of an error from resolving a path, which an *os.LinkError
+// carries without the *fs.PathError around it.
+func unwrapPathError(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// Create newname as a symbolic link to oldname. The target is stored as
+// given; wasi:filesystem only allows relative targets.
+func Symlink(oldname, newname string) error {
+	d, rel, err := resolveEntry("symlink", newname)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: unwrapPathError(err)}
+	}
+	if err := d.desc.SymlinkAt(oldname, rel); err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: fromHostError(err)}
+	}
+	return nil
+}
+
+// Change the access and modification times of the named file, following
+// symbolic links. A zero time leaves the corresponding time unchanged.
+func Chtimes(name string, atime, mtime time.Time) error {
+	d, rel, err := resolve("chtimes", name)
+	if err != nil {
+		return err
+	}
+	if err := d.desc.SetTimesAt(backend.PathFlagsSymlinkFollow, rel, newTimestamp(atime), newTimestamp(mtime)); err != nil {
+		return pathError("chtimes", name, err)
+	}
+	return nil
+}
+
+func newTimestamp(t time.Time) backend.NewTimestamp {
+	if t.IsZero() {
+		return backend.NewTimestamp{Kind: backend.NewTimestampNoChange}
+	}
+	return backend.NewTimestamp{Kind: backend.NewTimestampTimestamp, Time: t}
+}
+
+// Change the size of the named file, following symbolic links.
+func Truncate(name string, size int64) error {
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: name, Err: fs.ErrInvalid}
+	}
+	d, rel, err := resolve("truncate", name)
+	if err != nil {
+		return err
+	}
+	desc, err := d.desc.OpenAt(backend.PathFlagsSymlinkFollow, rel, 0, backend.DescriptorFlagsWrite)
+	if err != nil {
+		return pathError("truncate", name, err)
+	}
+	defer desc.Drop()
+	if err := desc.SetSize(uint64(size)); err != nil {
+		return pathError("truncate", name, err)
+	}
+	return nil
+}
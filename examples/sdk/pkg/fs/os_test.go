@@ -0,0 +1,236 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileWrite(t *testing.T) {
+	host := setup(t)
+
+	f, err := Create("/data/new.txt")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString("hello, world"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if _, err := f.WriteAt([]byte("HELLO"), 0); err != nil {
+		t.Fatalf("WriteAt: %v", err)
+	}
+	buf := make([]byte, 5)
+	if n, err := f.ReadAt(buf, 7); err != nil || string(buf[:n]) != "world" {
+		t.Errorf("ReadAt(7) = %q, %v", buf[:n], err)
+	}
+	if n, err := f.ReadAt(buf, 10); err != io.EOF || string(buf[:n]) != "ld" {
+		t.Errorf("ReadAt(10) = %q, %v", buf[:n], err)
+	}
+
+	if off, err := f.Seek(-5, io.SeekEnd); err != nil || off != 7 {
+		t.Fatalf("Seek(-5, end) = %d, %v", off, err)
+	}
+	if _, err := f.WriteString("there"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if off, err := f.Seek(0, io.SeekCurrent); err != nil || off != 12 {
+		t.Errorf("Seek(0, current) = %d, %v", off, err)
+	}
+	if _, err := f.Seek(-13, io.SeekCurrent); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Seek before start error = %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatalf("Seek(0, start): %v", err)
+	}
+	if data, err := io.ReadAll(f); err != nil || string(data) != "HELLO, there" {
+		t.Errorf("ReadAll() = %q, %v", data, err)
+	}
+
+	if err := f.Truncate(5); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if data, err := host.ReadFile("/data/new.txt"); err != nil || string(data) != "HELLO" {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	host := setup(t)
+
+	f, err := OpenFile("/data/hello.txt", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.WriteString("!"); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if off, err := f.Seek(0, io.SeekCurrent); err != nil || off != 13 {
+		t.Errorf("offset after appending = %d, %v", off, err)
+	}
+	if _, err := f.WriteAt([]byte("x"), 0); err == nil {
+		t.Error("WriteAt succeeded on a file opened with O_APPEND")
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if data, _ := host.ReadFile("/data/hello.txt"); string(data) != "hello, world!" {
+		t.Errorf("file = %q", data)
+	}
+
+	if _, err := OpenFile("/data/hello.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("O_EXCL on an existing file error = %v", err)
+	}
+	if _, err := Open("data/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open(data/missing) error = %v", err)
+	}
+	var pathErr *fs.PathError
+	if err := WriteFile("/srv/www/index.html", nil, 0o644); !errors.As(err, &pathErr) || pathErr.Op != "open" || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("WriteFile on a read-only preopen error = %v", err)
+	}
+
+	if err := WriteFile("/data/sub/b.txt", []byte("b"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if data, err := ReadFile("/data/sub/b.txt"); err != nil || string(data) != "b" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+}
+
+func TestDirectories(t *testing.T) {
+	setup(t)
+
+	if err := MkdirAll("/data/x/y/z", 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := MkdirAll("/data/x/y", 0o755); err != nil {
+		t.Errorf("MkdirAll on an existing directory: %v", err)
+	}
+	if err := Mkdir("/data/x", 0o755); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Mkdir on an existing directory error = %v", err)
+	}
+	if err := MkdirAll("/data/hello.txt/y", 0o755); !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("MkdirAll below a file error = %v", err)
+	}
+	if err := WriteFile("/data/x/y/z/f.txt", []byte("f"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := Remove("/data/x"); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("Remove of a non-empty directory error = %v", err)
+	}
+	if err := Remove("/data/x/y/z/f.txt"); err != nil {
+		t.Errorf("Remove(file): %v", err)
+	}
+	if err := Remove("/data/x/y/z"); err != nil {
+		t.Errorf("Remove(directory): %v", err)
+	}
+	if err := Remove("/data/x/y/z"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove of a missing file error = %v", err)
+	}
+	if err := Remove("/data"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Remove of a preopen error = %v", err)
+	}
+
+	if err := RemoveAll("/data/sub"); err != nil {
+		t.Fatalf("RemoveAll: %v", err)
+	}
+	if _, err := Lstat("/data/sub"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Lstat after RemoveAll error = %v", err)
+	}
+	if err := RemoveAll("/data/sub"); err != nil {
+		t.Errorf("RemoveAll of a missing directory: %v", err)
+	}
+
+	entries, err := ReadDir("/data")
+	if err != nil || len(entries) != 2 || entries[0].Name() != "hello.txt" || entries[1].Name() != "x" {
+		t.Errorf("ReadDir() = %v, %v", entries, err)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	setup(t)
+
+	if err := Rename("/data/hello.txt", "/data/sub/hello.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if _, err := Stat("/data/hello.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of the old name error = %v", err)
+	}
+
+	if err := Link("/data/sub/hello.txt", "/data/hard.txt"); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	if info, err := Stat("/data/hard.txt"); err != nil || info.Sys().(*FileStat).LinkCount != 2 {
+		t.Errorf("Stat(hard link) = %v, %v", info, err)
+	}
+
+	if err := Symlink("sub/hello.txt", "/data/soft"); err != nil {
+		t.Fatalf("Symlink: %v", err)
+	}
+	if target, err := Readlink("/data/soft"); err != nil || target != "sub/hello.txt" {
+		t.Errorf("Readlink() = %q, %v", target, err)
+	}
+	if data, err := ReadFile("/data/soft"); err != nil || string(data) != "hello, world" {
+		t.Errorf("ReadFile(symlink) = %q, %v", data, err)
+	}
+
+	err := Rename("/data/missing", "/data/other")
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) || linkErr.Op != "rename" || linkErr.Old != "/data/missing" || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Rename of a missing file error = %v", err)
+	}
+	if err := Rename("/srv/www/index.html", "/srv/www/other.html"); !errors.As(err, &linkErr) || linkErr.New != "/srv/www/other.html" || !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Rename on a read-only preopen error = %v", err)
+	}
+}
+
+func TestUnwrapPathError(t *testing.T) {
+	cause := errors.New("cause")
+	if err := unwrapPathError(&fs.PathError{Op: "resolve", Path: "x", Err: cause}); err != cause {
+		t.Errorf("unwrapPathError(*fs.PathError) = %v", err)
+	}
+	if err := unwrapPathError(cause); err != cause {
+		t.Errorf("unwrapPathError(other) = %v", err)
+	}
+}
+
+func TestChtimesTruncate(t *testing.T) {
+	setup(t)
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	before, err := Stat("/data/hello.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if err := Chtimes("/data/hello.txt", time.Time{}, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	info, err := Stat("/data/hello.txt")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("ModTime() = %v", info.ModTime())
+	}
+	if atime := info.Sys().(*FileStat).AccessTime; !atime.Equal(before.Sys().(*FileStat).AccessTime) {
+		t.Errorf("AccessTime() changed to %v", atime)
+	}
+
+	if err := Truncate("/data/sub/link", 0); err != nil {
+		t.Fatalf("Truncate: %v", err)
+	}
+	if info, err := Stat("/data/sub/a.txt"); err != nil || info.Size() != 0 {
+		t.Errorf("Stat after Truncate = %v, %v", info, err)
+	}
+	if err := Truncate("/data/sub/a.txt", -1); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Truncate(-1) error = %v", err)
+	}
+}
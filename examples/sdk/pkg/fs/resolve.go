@@ -0,0 +1,162 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"pkg/internal/backend"
+	"strings"
+)
+
+// The number of symbolic links a Resolver follows before giving up with
+// ErrLoop, as on Linux.
+const maxSymlinks = 40
+
+// Returned, wrapped in a *fs.PathError, for paths that use ".." to leave "/",
+// and for symbolic links that lead out of the preopen they are in.
+var ErrPathEscapes = errors.New("path escapes from preopen")
+
+// Maps the paths an application uses, which are absolute or relative to a
+// working directory, to a preopen and a path within it.
+//
+// Paths are cleaned lexically, so "a/../b" is "b" whether or not "a" is a
+// symbolic link, and the preopen with the longest path containing the result
+// is chosen. The host follows symbolic links within that preopen as usual.
+// With FollowSymlinks set, the Resolver reads them itself instead, so that
+// links with absolute targets lead to whichever preopen contains the target.
+type Resolver struct {
+	root *Root
+
+	// The absolute path relative paths are resolved against. NewResolver
+	// sets it to the host's initial working directory, or "/" if the host
+	// does not provide one.
+	Cwd string
+
+	// Whether to follow symbolic links, including a final one, through
+	// ReadlinkAt. Relative targets may not leave the link's preopen.
+	FollowSymlinks bool
+}
+
+// Create a Resolver choosing among the preopens of root.
+func NewResolver(root *Root) *Resolver {
+	cwd, ok := backend.Current().GetInitialCwd()
+	if !ok || !path.IsAbs(cwd) {
+		cwd = "/"
+	}
+	return &Resolver{root: root, Cwd: path.Clean(cwd)}
+}
+
+// The cleaned absolute path for name.
+func (r *Resolver) Abs(name string) (string, error) {
+	if name == "" {
+		return "", &fs.PathError{Op: "resolve", Path: name, Err: fs.ErrNotExist}
+	}
+	base := r.Cwd
+	if path.IsAbs(name) {
+		base = "/"
+	}
+	rel, ok := cleanWithin(strings.TrimPrefix(path.Clean(base), "/"), name)
+	if !ok {
+		return "", &fs.PathError{Op: "resolve", Path: name, Err: ErrPathEscapes}
+	}
+	return path.Join("/", rel), nil
+}
+
+// The preopen containing name, and name's path relative to it. Fails with
+// fs.ErrNotExist if no preopen contains name.
+func (r *Resolver) Resolve(name string) (*Dir, string, error) {
+	abs, err := r.Abs(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for links := 0; ; {
+		d, rel := r.root.resolve(abs)
+		if d == nil {
+			return nil, "", &fs.PathError{Op: "resolve", Path: name, Err: fs.ErrNotExist}
+		}
+		if !r.FollowSymlinks {
+			return d, rel, nil
+		}
+
+		link, target, err := firstSymlink(d, rel)
+		if err != nil {
+			return nil, "", pathError("resolve", name, err)
+		}
+		if link == "" {
+			return d, rel, nil
+		}
+		if links++; links > maxSymlinks {
+			return nil, "", &fs.PathError{Op: "resolve", Path: name, Err: ErrLoop}
+		}
+
+		// Replace the link with its target, keeping whatever followed it.
+		target += strings.TrimPrefix(rel, link)
+		if path.IsAbs(target) {
+			rel, ok := cleanWithin("", target)
+			if !ok {
+				return nil, "", &fs.PathError{Op: "resolve", Path: name, Err: ErrPathEscapes}
+			}
+			abs = path.Join("/", rel)
+			continue
+		}
+		rel, ok := cleanWithin(path.Dir(link), target)
+		if !ok {
+			return nil, "", &fs.PathError{Op: "resolve", Path: name, Err: ErrPathEscapes}
+		}
+		abs = path.Join(d.path, rel)
+	}
+}
+
+// The first symbolic link among rel and its parents within d, and its
+// target. Returns an empty link if there is none, including when part of
+// rel does not exist, leaving it to the operation on rel to report that.
+func firstSymlink(d *Dir, rel string) (string, string, error) {
+	if rel == "." {
+		return "", "", nil
+	}
+	for i := 0; i <= len(rel); i++ {
+		if i < len(rel) && rel[i] != '/' {
+			continue
+		}
+		prefix := rel[:i]
+		target, err := d.desc.ReadlinkAt(prefix)
+		switch fromHostError(err) {
+		case nil:
+			return prefix, target, nil
+		case ErrInvalid:
+			// Not a symbolic link.
+		case ErrNoEntry, ErrNotDirectory:
+			return "", "", nil
+		default:
+			return "", "", err
+		}
+	}
+	return "", "", nil
+}
+
+// Join base, a clean relative path, and p, resolving "." and ".."
+// lexically. Reports false if ".." would leave base's root. Returns "." for
+// the root itself, and the path without a leading "/" otherwise.
+func cleanWithin(base, p string) (string, bool) {
+	var parts []string
+	if base != "" && base != "." {
+		parts = strings.Split(base, "/")
+	}
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(parts) == 0 {
+				return "", false
+			}
+			parts = parts[:len(parts)-1]
+		default:
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return ".", true
+	}
+	return strings.Join(parts, "/"), true
+}
@@ -0,0 +1,105 @@
+package fs
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	host := setup(t)
+	host.Preopen("/data/sub")
+	host.SetInitialCwd("/data/sub")
+	host.Symlink("/srv/www/index.html", "/data/abs")
+	host.Symlink("sub", "/data/dirlink")
+	host.Symlink("../outside", "/data/escape")
+	host.Symlink("loop", "/data/loop")
+	host.Symlink("missing/x", "/data/dangling")
+
+	root := NewRoot()
+	defer root.Close()
+	r := NewResolver(root)
+	if r.Cwd != "/data/sub" {
+		t.Fatalf("Cwd = %q", r.Cwd)
+	}
+
+	for _, test := range []struct {
+		name   string
+		follow bool
+		dir    string
+		rel    string
+		err    error
+	}{
+		{name: "/data/hello.txt", dir: "/data", rel: "hello.txt"},
+		{name: "/data/sub/a.txt", dir: "/data/sub", rel: "a.txt"},
+		{name: "/data/sub", dir: "/data/sub", rel: "."},
+		{name: "/data/./x/../sub//a.txt", dir: "/data/sub", rel: "a.txt"},
+		{name: "a.txt", dir: "/data/sub", rel: "a.txt"},
+		{name: ".", dir: "/data/sub", rel: "."},
+		{name: "../hello.txt", dir: "/data", rel: "hello.txt"},
+		{name: "../../srv/www/index.html", dir: "/srv/www", rel: "index.html"},
+		{name: "../../../etc", err: ErrPathEscapes},
+		{name: "/../data", err: ErrPathEscapes},
+		{name: "/etc/passwd", err: fs.ErrNotExist},
+		{name: "/srv", err: fs.ErrNotExist},
+		{name: "", err: fs.ErrNotExist},
+
+		{name: "/data/abs", dir: "/data", rel: "abs"},
+		{name: "/data/abs", follow: true, dir: "/srv/www", rel: "index.html"},
+		{name: "/data/dirlink/a.txt", dir: "/data", rel: "dirlink/a.txt"},
+		{name: "/data/dirlink/a.txt", follow: true, dir: "/data/sub", rel: "a.txt"},
+		{name: "/data/sub/link", follow: true, dir: "/data/sub", rel: "a.txt"},
+		{name: "/data/dangling", follow: true, dir: "/data", rel: "missing/x"},
+		{name: "/data/missing/x", follow: true, dir: "/data", rel: "missing/x"},
+		{name: "/data/escape", follow: true, err: ErrPathEscapes},
+		{name: "/data/loop", follow: true, err: ErrLoop},
+	} {
+		r.FollowSymlinks = test.follow
+		d, rel, err := r.Resolve(test.name)
+		if test.err != nil {
+			var pathErr *fs.PathError
+			if !errors.Is(err, test.err) || !errors.As(err, &pathErr) || pathErr.Path != test.name {
+				t.Errorf("Resolve(%q, follow %v) error = %v, expected %v", test.name, test.follow, err, test.err)
+			}
+			continue
+		}
+		if err != nil || d.Path() != test.dir || rel != test.rel {
+			t.Errorf("Resolve(%q, follow %v) = %v, %q, %v; expected %s, %q", test.name, test.follow, d, rel, err, test.dir, test.rel)
+		}
+	}
+}
+
+func TestResolveWithoutCwd(t *testing.T) {
+	setup(t)
+
+	root := NewRoot()
+	defer root.Close()
+	r := NewResolver(root)
+	if r.Cwd != "/" {
+		t.Errorf("Cwd = %q", r.Cwd)
+	}
+	if abs, err := r.Abs("data/sub/../hello.txt"); err != nil || abs != "/data/hello.txt" {
+		t.Errorf("Abs() = %q, %v", abs, err)
+	}
+}
+
+func TestRelativePaths(t *testing.T) {
+	host := setup(t)
+	host.SetInitialCwd("/data/sub")
+
+	if data, err := ReadFile("../hello.txt"); err != nil || string(data) != "hello, world" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+	if err := WriteFile("b.txt", []byte("b"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if data, _ := host.ReadFile("/data/sub/b.txt"); string(data) != "b" {
+		t.Errorf("file = %q", data)
+	}
+	if info, err := Stat("."); err != nil || info.Name() != "sub" {
+		t.Errorf("Stat(.) = %v, %v", info, err)
+	}
+	if _, err := Stat("../../../x"); !errors.Is(err, ErrPathEscapes) || err.(*fs.PathError).Op != "stat" {
+		t.Errorf("Stat(../../../x) error = %v", err)
+	}
+}
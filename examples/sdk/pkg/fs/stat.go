@@ -0,0 +1,107 @@
+package fs
+
+import (
+	"io/fs"
+	"pkg/internal/backend"
+	"time"
+)
+
+// What the host reports about a file, returned by the Sys method of the
+// fs.FileInfo values from this package. Timestamps the host does not provide
+// are zero.
+type FileStat struct {
+	LinkCount  uint64
+	AccessTime time.Time
+	ModTime    time.Time
+	ChangeTime time.Time
+}
+
+// wasi:filesystem has no permissions, so files are reported with the usual
+// defaults.
+const (
+	filePerm = 0o644
+	dirPerm  = 0o755
+)
+
+// The fs.FileMode for a descriptor type, including the default permissions.
+func modeOf(typ backend.DescriptorType) fs.FileMode {
+	switch typ {
+	case backend.DescriptorTypeDirectory:
+		return fs.ModeDir | dirPerm
+	case backend.DescriptorTypeSymbolicLink:
+		return fs.ModeSymlink | 0o777
+	case backend.DescriptorTypeBlockDevice:
+		return fs.ModeDevice | filePerm
+	case backend.DescriptorTypeCharacterDevice:
+		return fs.ModeDevice | fs.ModeCharDevice | filePerm
+	case backend.DescriptorTypeFifo:
+		return fs.ModeNamedPipe | filePerm
+	case backend.DescriptorTypeSocket:
+		return fs.ModeSocket | filePerm
+	case backend.DescriptorTypeRegularFile:
+		return filePerm
+	}
+	return fs.ModeIrregular | filePerm
+}
+
+type fileInfo struct {
+	name string
+	stat backend.DescriptorStat
+}
+
+func newFileInfo(name string, stat backend.DescriptorStat) fs.FileInfo {
+	return &fileInfo{name: name, stat: stat}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return int64(fi.stat.Size) }
+func (fi *fileInfo) Mode() fs.FileMode  { return modeOf(fi.stat.Type) }
+func (fi *fileInfo) ModTime() time.Time { return fi.stat.DataModificationTimestamp }
+func (fi *fileInfo) IsDir() bool        { return fi.stat.Type == backend.DescriptorTypeDirectory }
+
+func (fi *fileInfo) Sys() any {
+	return &FileStat{
+		LinkCount:  fi.stat.LinkCount,
+		AccessTime: fi.stat.DataAccessTimestamp,
+		ModTime:    fi.stat.DataModificationTimestamp,
+		ChangeTime: fi.stat.StatusChangeTimestamp,
+	}
+}
+
+// A directory entry, described on demand through the preopen it was listed
+// from, so that it can still be described after its directory is closed.
+type dirEntry struct {
+	dir   *Dir
+	rel   string
+	entry backend.DirectoryEntry
+}
+
+func (e *dirEntry) Name() string      { return e.entry.Name }
+func (e *dirEntry) IsDir() bool       { return e.entry.Type == backend.DescriptorTypeDirectory }
+func (e *dirEntry) Type() fs.FileMode { return modeOf(e.entry.Type).Type() }
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	return e.dir.Lstat(e.rel)
+}
+
+func (e *dirEntry) String() string {
+	return fs.FormatDirEntry(e)
+}
+
+// Describes a directory above the preopens in a Root.
+type mountInfo string
+
+func (m mountInfo) Name() string       { return string(m) }
+func (m mountInfo) Size() int64        { return 0 }
+func (m mountInfo) Mode() fs.FileMode  { return fs.ModeDir | dirPerm }
+func (m mountInfo) ModTime() time.Time { return time.Time{} }
+func (m mountInfo) IsDir() bool        { return true }
+func (m mountInfo) Sys() any           { return nil }
+
+// A FileInfo reported under a different name.
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (r renamedInfo) Name() string { return r.name }
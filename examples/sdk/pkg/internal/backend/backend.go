@@ -0,0 +1,50 @@
+// Package backend defines the host interfaces the SDK is built on.
+//
+// When compiling for wasip1, the host is provided by the generated wasi:*
+// bindings. Otherwise there is none until a test installs the in-memory fake
+// from package pkg/fake, so that the SDK, and the applications using it, can
+// be tested natively with plain `go test`. Until then, operations that need a
+// host fail with ErrNoHost. Native binaries never link the fake.
+package backend
+
+import "sync"
+
+// Everything the SDK needs from the host.
+type Host interface {
+	Sockets
+	NameLookup
+	Clocks
+	Random
+	Environment
+	Stdio
+	Terminals
+	Exit
+	Filesystem
+}
+
+var (
+	mu      sync.RWMutex
+	current Host
+)
+
+// The host the SDK is currently running against. Outside of wasip1, with no
+// host installed, this is a stand-in whose fallible operations fail with
+// ErrNoHost.
+func Current() Host {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return noHost{}
+	}
+	return current
+}
+
+// Replace the current host, returning the previous one. Resources already
+// created keep using the host they were created by.
+func Set(h Host) Host {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := current
+	current = h
+	return prev
+}
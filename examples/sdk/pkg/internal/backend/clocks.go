@@ -0,0 +1,18 @@
+package backend
+
+import "time"
+
+// Mirrors wasi:clocks/monotonic-clock and wasi:clocks/system-clock.
+type Clocks interface {
+	// The current value of the monotonic clock, in nanoseconds.
+	MonotonicNow() uint64
+	MonotonicResolution() time.Duration
+
+	// Block until the monotonic clock has advanced by d, or has reached
+	// mark.
+	WaitFor(d time.Duration)
+	WaitUntil(mark uint64)
+
+	SystemNow() time.Time
+	SystemResolution() time.Duration
+}
@@ -0,0 +1,11 @@
+package backend
+
+// Mirrors wasi:cli/environment.
+type Environment interface {
+	// Key-value pairs, in the order the host provides them.
+	GetEnvironment() [][2]string
+	GetArguments() []string
+
+	// The initial working directory, if the host provides one.
+	GetInitialCwd() (string, bool)
+}
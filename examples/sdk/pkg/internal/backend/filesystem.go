@@ -0,0 +1,253 @@
+package backend
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Mirrors wasi:filesystem/types.descriptor-type, in the same order.
+type DescriptorType uint8
+
+const (
+	DescriptorTypeBlockDevice DescriptorType = iota
+	DescriptorTypeCharacterDevice
+	DescriptorTypeDirectory
+	DescriptorTypeFifo
+	DescriptorTypeSymbolicLink
+	DescriptorTypeRegularFile
+	DescriptorTypeSocket
+	DescriptorTypeOther
+)
+
+// Mirrors wasi:filesystem/types.descriptor-flags.
+type DescriptorFlags uint8
+
+const (
+	DescriptorFlagsRead DescriptorFlags = 1 << iota
+	DescriptorFlagsWrite
+	DescriptorFlagsFileIntegritySync
+	DescriptorFlagsDataIntegritySync
+	DescriptorFlagsRequestedWriteSync
+	DescriptorFlagsMutateDirectory
+)
+
+// Mirrors wasi:filesystem/types.path-flags.
+type PathFlags uint8
+
+const (
+	PathFlagsSymlinkFollow PathFlags = 1 << iota
+)
+
+// Mirrors wasi:filesystem/types.open-flags.
+type OpenFlags uint8
+
+const (
+	OpenFlagsCreate OpenFlags = 1 << iota
+	OpenFlagsDirectory
+	OpenFlagsExclusive
+	OpenFlagsTruncate
+)
+
+// Mirrors wasi:filesystem/types.advice, in the same order.
+type Advice uint8
+
+const (
+	AdviceNormal Advice = iota
+	AdviceSequential
+	AdviceRandom
+	AdviceWillNeed
+	AdviceDontNeed
+	AdviceNoReuse
+)
+
+// Mirrors wasi:filesystem/types.descriptor-stat. Timestamps the host does not
+// provide are zero.
+type DescriptorStat struct {
+	Type                      DescriptorType
+	LinkCount                 uint64
+	Size                      uint64
+	DataAccessTimestamp       time.Time
+	DataModificationTimestamp time.Time
+	StatusChangeTimestamp     time.Time
+}
+
+// Mirrors wasi:filesystem/types.new-timestamp.
+type NewTimestamp struct {
+	Kind NewTimestampKind
+
+	// Only used with NewTimestampTimestamp.
+	Time time.Time
+}
+
+type NewTimestampKind uint8
+
+const (
+	NewTimestampNoChange NewTimestampKind = iota
+	NewTimestampNow
+	NewTimestampTimestamp
+)
+
+// Mirrors wasi:filesystem/types.directory-entry.
+type DirectoryEntry struct {
+	Type DescriptorType
+	Name string
+}
+
+// Mirrors wasi:filesystem/types.metadata-hash-value.
+type MetadataHashValue struct {
+	Lower uint64
+	Upper uint64
+}
+
+// Mirrors wasi:filesystem/types.error-code, in the same order.
+type FsErrorCode uint8
+
+const (
+	FsErrorCodeAccess FsErrorCode = iota
+	FsErrorCodeAlready
+	FsErrorCodeBadDescriptor
+	FsErrorCodeBusy
+	FsErrorCodeDeadlock
+	FsErrorCodeQuota
+	FsErrorCodeExist
+	FsErrorCodeFileTooLarge
+	FsErrorCodeIllegalByteSequence
+	FsErrorCodeInProgress
+	FsErrorCodeInterrupted
+	FsErrorCodeInvalid
+	FsErrorCodeIo
+	FsErrorCodeIsDirectory
+	FsErrorCodeLoop
+	FsErrorCodeTooManyLinks
+	FsErrorCodeMessageSize
+	FsErrorCodeNameTooLong
+	FsErrorCodeNoDevice
+	FsErrorCodeNoEntry
+	FsErrorCodeNoLock
+	FsErrorCodeInsufficientMemory
+	FsErrorCodeInsufficientSpace
+	FsErrorCodeNotDirectory
+	FsErrorCodeNotEmpty
+	FsErrorCodeNotRecoverable
+	FsErrorCodeUnsupported
+	FsErrorCodeNoTty
+	FsErrorCodeNoSuchDevice
+	FsErrorCodeOverflow
+	FsErrorCodeNotPermitted
+	FsErrorCodePipe
+	FsErrorCodeReadOnly
+	FsErrorCodeInvalidSeek
+	FsErrorCodeTextFileBusy
+	FsErrorCodeCrossDevice
+	FsErrorCodeOther
+)
+
+// An error reported by the host's filesystem implementation.
+type FsError struct {
+	Code FsErrorCode
+
+	// Only set for FsErrorCodeOther, and only if the host provided one.
+	Message string
+}
+
+func (e *FsError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("filesystem error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("filesystem error %d", e.Code)
+}
+
+// Mirrors wasi:filesystem/preopens.
+type Filesystem interface {
+	GetDirectories() []Preopen
+}
+
+// A directory the host has made available, along with the path the guest
+// should know it by.
+type Preopen struct {
+	Descriptor Descriptor
+	Path       string
+}
+
+// The absolute path for a preopen's Path. Preopens are normally absolute.
+// Some hosts name them relative to the working directory, e.g. ".", which is
+// taken to be "/".
+func CleanPreopenPath(p string) string {
+	return path.Join("/", p)
+}
+
+// Find the preopen containing name, which is taken to be relative to "/" if
+// it is not absolute, among preopens with the provided paths, which must
+// have been cleaned with CleanPreopenPath. The one with the longest path
+// wins, and the first of them if several are equally long. Returns its index
+// and name's path relative to it, or -1 if no preopen contains name.
+func MatchPreopen(paths []string, name string) (int, string) {
+	abs := path.Join("/", name)
+	match, rel := -1, ""
+	for i, p := range paths {
+		if match >= 0 && len(p) <= len(paths[match]) {
+			continue
+		}
+		switch {
+		case abs == p:
+			match, rel = i, "."
+		case p == "/":
+			match, rel = i, abs[1:]
+		case strings.HasPrefix(abs, p+"/"):
+			match, rel = i, abs[len(p)+1:]
+		}
+	}
+	return match, rel
+}
+
+// Mirrors wasi:filesystem/types.descriptor. Paths are relative to the
+// descriptor and may not escape it.
+type Descriptor interface {
+	ReadViaStream(offset uint64) InputStream
+	WriteViaStream(offset uint64) OutputStream
+	AppendViaStream() OutputStream
+	Advise(offset, length uint64, advice Advice) error
+	SyncData() error
+	GetFlags() (DescriptorFlags, error)
+	GetType() (DescriptorType, error)
+	SetSize(size uint64) error
+	SetTimes(atime, mtime NewTimestamp) error
+	ReadDirectory() DirectoryEntryStream
+	Sync() error
+	CreateDirectoryAt(path string) error
+	Stat() (DescriptorStat, error)
+	StatAt(flags PathFlags, path string) (DescriptorStat, error)
+	SetTimesAt(flags PathFlags, path string, atime, mtime NewTimestamp) error
+	LinkAt(oldFlags PathFlags, oldPath string, newDir Descriptor, newPath string) error
+	OpenAt(flags PathFlags, path string, openFlags OpenFlags, descFlags DescriptorFlags) (Descriptor, error)
+	ReadlinkAt(path string) (string, error)
+	RemoveDirectoryAt(path string) error
+	RenameAt(oldPath string, newDir Descriptor, newPath string) error
+	SymlinkAt(oldPath, newPath string) error
+	UnlinkFileAt(path string) error
+	IsSameObject(other Descriptor) bool
+	MetadataHash() (MetadataHashValue, error)
+	MetadataHashAt(flags PathFlags, path string) (MetadataHashValue, error)
+	Drop()
+}
+
+// Optionally implemented by a Descriptor that can write a stream returned by
+// ReadViaStream on a descriptor of the same host. The stream is handed to
+// the host as a whole, so the data never passes through the guest.
+type DescriptorSplicer interface {
+	// Write everything in holds to the file from offset, and wait until both
+	// streams have finished. Returns the first error reported for either.
+	// Reports false, leaving in untouched, if in cannot be handed over.
+	SpliceViaStream(in InputStream, offset uint64) (bool, error)
+}
+
+// The entries returned by Descriptor.ReadDirectory.
+type DirectoryEntryStream interface {
+	// Returns io.EOF once every entry has been read, or the error the host
+	// reported.
+	Next() (DirectoryEntry, error)
+
+	Drop()
+}
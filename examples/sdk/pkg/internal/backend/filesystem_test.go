@@ -0,0 +1,30 @@
+package backend
+
+import "testing"
+
+func TestMatchPreopen(t *testing.T) {
+	paths := []string{CleanPreopenPath("/data/"), CleanPreopenPath("."), CleanPreopenPath("/data/sub"), CleanPreopenPath("/srv"), CleanPreopenPath("/srv/")}
+
+	for _, test := range []struct {
+		name  string
+		index int
+		rel   string
+	}{
+		{"/data/sub/a.txt", 2, "a.txt"},
+		{"/data/sub", 2, "."},
+		{"/data/subway", 0, "subway"},
+		{"/data", 0, "."},
+		{"data/x/../sub//b", 2, "b"},
+		{"/srv/www", 3, "www"},
+		{"/etc/passwd", 1, "etc/passwd"},
+		{"/", 1, "."},
+	} {
+		if i, rel := MatchPreopen(paths, test.name); i != test.index || rel != test.rel {
+			t.Errorf("MatchPreopen(%q) = %d, %q; expected %d, %q", test.name, i, rel, test.index, test.rel)
+		}
+	}
+
+	if i, _ := MatchPreopen([]string{"/data"}, "/etc"); i != -1 {
+		t.Errorf("MatchPreopen outside any preopen = %d", i)
+	}
+}
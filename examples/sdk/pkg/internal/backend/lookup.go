@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Mirrors wasi:sockets/ip-name-lookup.error-code, in the same order.
+type LookupErrorCode uint8
+
+const (
+	LookupErrorCodeAccessDenied LookupErrorCode = iota
+	LookupErrorCodeInvalidArgument
+	LookupErrorCodeNameUnresolvable
+	LookupErrorCodeTemporaryResolverFailure
+	LookupErrorCodePermanentResolverFailure
+	LookupErrorCodeOther
+)
+
+// An error reported by the host's name resolver.
+type LookupError struct {
+	Code LookupErrorCode
+
+	// Only set for LookupErrorCodeOther, and only if the host provided one.
+	Message string
+}
+
+func (e *LookupError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("lookup error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("lookup error %d", e.Code)
+}
+
+// Mirrors wasi:sockets/ip-name-lookup.
+type NameLookup interface {
+	ResolveAddresses(name string) ([]netip.Addr, error)
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+)
+
+// Returned by the host operations that can fail when no host is installed.
+var ErrNoHost = errors.New("backend: no host is available outside of wasip1; tests can install one with fake.Install")
+
+// The host outside of wasip1 until one is installed. Sockets, name lookups
+// and the standard streams fail with ErrNoHost, and there are no preopens.
+// What cannot fail is served by the process itself: its clocks, its random
+// source, its arguments, environment and working directory, and os.Exit.
+type noHost struct{}
+
+var _ Host = noHost{}
+
+var noHostStart = time.Now()
+
+func (noHost) CreateTcpSocket(IpAddressFamily) (TcpSocket, error) {
+	return nil, ErrNoHost
+}
+
+func (noHost) CreateUdpSocket(IpAddressFamily) (UdpSocket, error) {
+	return nil, ErrNoHost
+}
+
+func (noHost) ResolveAddresses(string) ([]netip.Addr, error) {
+	return nil, ErrNoHost
+}
+
+func (noHost) MonotonicNow() uint64 {
+	return uint64(time.Since(noHostStart))
+}
+
+func (noHost) MonotonicResolution() time.Duration {
+	return time.Nanosecond
+}
+
+func (noHost) WaitFor(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (h noHost) WaitUntil(mark uint64) {
+	if now := h.MonotonicNow(); mark > now {
+		time.Sleep(time.Duration(mark - now))
+	}
+}
+
+func (noHost) SystemNow() time.Time {
+	return time.Now()
+}
+
+func (noHost) SystemResolution() time.Duration {
+	return time.Nanosecond
+}
+
+func (noHost) GetRandomBytes(n uint64) []byte {
+	return randomBytes(n)
+}
+
+func (noHost) GetRandomU64() uint64 {
+	return randomU64()
+}
+
+func (noHost) GetInsecureRandomBytes(n uint64) []byte {
+	return randomBytes(n)
+}
+
+func (noHost) GetInsecureRandomU64() uint64 {
+	return randomU64()
+}
+
+func randomBytes(n uint64) []byte {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b
+}
+
+func randomU64() uint64 {
+	return binary.LittleEndian.Uint64(randomBytes(8))
+}
+
+func (noHost) GetEnvironment() [][2]string {
+	var vars [][2]string
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		vars = append(vars, [2]string{k, v})
+	}
+	return vars
+}
+
+func (noHost) GetArguments() []string {
+	return os.Args
+}
+
+func (noHost) GetInitialCwd() (string, bool) {
+	wd, err := os.Getwd()
+	return wd, err == nil
+}
+
+func (noHost) GetStdin() InputStream {
+	return noHostStream{}
+}
+
+func (noHost) GetStdout() OutputStream {
+	return noHostStream{}
+}
+
+func (noHost) GetStderr() OutputStream {
+	return noHostStream{}
+}
+
+func (noHost) GetTerminalStdin() bool {
+	return false
+}
+
+func (noHost) GetTerminalStdout() bool {
+	return false
+}
+
+func (noHost) GetTerminalStderr() bool {
+	return false
+}
+
+func (noHost) Exit(ok bool) {
+	if ok {
+		os.Exit(0)
+	}
+	os.Exit(1)
+}
+
+func (noHost) ExitWithCode(code uint8) {
+	os.Exit(int(code))
+}
+
+func (noHost) GetDirectories() []Preopen {
+	return nil
+}
+
+// A standard stream of noHost, which fails every read and write.
+type noHostStream struct{}
+
+func (noHostStream) Read([]byte) (int, error) {
+	return 0, ErrNoHost
+}
+
+func (noHostStream) Write([]byte) (int, error) {
+	return 0, ErrNoHost
+}
+
+func (noHostStream) Close() error {
+	return ErrNoHost
+}
+
+func (noHostStream) Drop() {}
@@ -0,0 +1,32 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNoHost(t *testing.T) {
+	h := Current()
+
+	if _, err := h.CreateTcpSocket(IpAddressFamilyIpv4); !errors.Is(err, ErrNoHost) {
+		t.Errorf("CreateTcpSocket error = %v, expected ErrNoHost", err)
+	}
+	if _, err := h.CreateUdpSocket(IpAddressFamilyIpv6); !errors.Is(err, ErrNoHost) {
+		t.Errorf("CreateUdpSocket error = %v, expected ErrNoHost", err)
+	}
+	if _, err := h.ResolveAddresses("localhost"); !errors.Is(err, ErrNoHost) {
+		t.Errorf("ResolveAddresses error = %v, expected ErrNoHost", err)
+	}
+	if _, err := h.GetStdout().Write([]byte("x")); !errors.Is(err, ErrNoHost) {
+		t.Errorf("stdout write error = %v, expected ErrNoHost", err)
+	}
+	if _, err := h.GetStdin().Read(make([]byte, 1)); !errors.Is(err, ErrNoHost) {
+		t.Errorf("stdin read error = %v, expected ErrNoHost", err)
+	}
+	if preopens := h.GetDirectories(); len(preopens) != 0 {
+		t.Errorf("GetDirectories = %v, expected none", preopens)
+	}
+	if b := h.GetRandomBytes(16); len(b) != 16 {
+		t.Errorf("GetRandomBytes returned %d bytes, expected 16", len(b))
+	}
+}
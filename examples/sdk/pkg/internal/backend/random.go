@@ -0,0 +1,12 @@
+package backend
+
+// Mirrors wasi:random/random and wasi:random/insecure.
+type Random interface {
+	// Cryptographically secure random bytes.
+	GetRandomBytes(n uint64) []byte
+	GetRandomU64() uint64
+
+	// Fast random bytes, which must not be used for anything secret.
+	GetInsecureRandomBytes(n uint64) []byte
+	GetInsecureRandomU64() uint64
+}
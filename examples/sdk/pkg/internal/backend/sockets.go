@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"fmt"
+	"net/netip"
+	"time"
+)
+
+// Mirrors wasi:sockets/types.ip-address-family, in the same order. Hosts
+// implementing a newer version of wasi:sockets may report other values.
+type IpAddressFamily uint8
+
+const (
+	IpAddressFamilyIpv4 IpAddressFamily = iota
+	IpAddressFamilyIpv6
+)
+
+// Mirrors wasi:sockets/types.ip-socket-address. Addr never carries a zone;
+// the IPv6 scope is held in ScopeId instead.
+type IpSocketAddress struct {
+	Addr     netip.Addr
+	Port     uint16
+	FlowInfo uint32
+	ScopeId  uint32
+}
+
+// Mirrors wasi:sockets/types.error-code, in the same order.
+type SocketErrorCode uint8
+
+const (
+	SocketErrorCodeAccessDenied SocketErrorCode = iota
+	SocketErrorCodeNotSupported
+	SocketErrorCodeInvalidArgument
+	SocketErrorCodeOutOfMemory
+	SocketErrorCodeTimeout
+	SocketErrorCodeInvalidState
+	SocketErrorCodeAddressNotBindable
+	SocketErrorCodeAddressInUse
+	SocketErrorCodeRemoteUnreachable
+	SocketErrorCodeConnectionRefused
+	SocketErrorCodeConnectionBroken
+	SocketErrorCodeConnectionReset
+	SocketErrorCodeConnectionAborted
+	SocketErrorCodeDatagramTooLarge
+	SocketErrorCodeOther
+)
+
+// An error reported by the host's sockets implementation.
+type SocketError struct {
+	Code SocketErrorCode
+
+	// Only set for SocketErrorCodeOther, and only if the host provided one.
+	Message string
+}
+
+func (e *SocketError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("socket error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("socket error %d", e.Code)
+}
+
+type Sockets interface {
+	CreateTcpSocket(af IpAddressFamily) (TcpSocket, error)
+	CreateUdpSocket(af IpAddressFamily) (UdpSocket, error)
+}
+
+// Mirrors wasi:sockets/types.tcp-socket.
+type TcpSocket interface {
+	Bind(local IpSocketAddress) error
+	Connect(remote IpSocketAddress) error
+	Listen() (TcpAcceptor, error)
+
+	// Open the byte streams of a connected socket. Each is called at most
+	// once.
+	Send() OutputStream
+	Receive() InputStream
+
+	GetLocalAddress() (IpSocketAddress, error)
+	GetRemoteAddress() (IpSocketAddress, error)
+	GetIsListening() bool
+	GetAddressFamily() IpAddressFamily
+
+	SetListenBacklogSize(size uint64) error
+	GetKeepAliveEnabled() (bool, error)
+	SetKeepAliveEnabled(v bool) error
+	GetKeepAliveIdleTime() (time.Duration, error)
+	SetKeepAliveIdleTime(d time.Duration) error
+	GetKeepAliveInterval() (time.Duration, error)
+	SetKeepAliveInterval(d time.Duration) error
+	GetKeepAliveCount() (uint32, error)
+	SetKeepAliveCount(v uint32) error
+	GetHopLimit() (uint8, error)
+	SetHopLimit(v uint8) error
+	GetReceiveBufferSize() (uint64, error)
+	SetReceiveBufferSize(size uint64) error
+	GetSendBufferSize() (uint64, error)
+	SetSendBufferSize(size uint64) error
+
+	Drop()
+}
+
+// The stream of inbound connections returned by TcpSocket.Listen.
+type TcpAcceptor interface {
+	// Block until the next inbound connection arrives. Returns io.EOF once
+	// the host stops delivering connections.
+	Accept() (TcpSocket, error)
+
+	Drop()
+}
+
+// Mirrors wasi:sockets/types.udp-socket.
+type UdpSocket interface {
+	Bind(local IpSocketAddress) error
+	Connect(remote IpSocketAddress) error
+	Disconnect() error
+
+	// Send a single datagram. A nil remote sends to the connected peer.
+	Send(data []byte, remote *IpSocketAddress) error
+
+	// Block until a datagram arrives, returning it along with its sender.
+	Receive() ([]byte, IpSocketAddress, error)
+
+	GetLocalAddress() (IpSocketAddress, error)
+	GetRemoteAddress() (IpSocketAddress, error)
+	GetAddressFamily() IpAddressFamily
+
+	GetUnicastHopLimit() (uint8, error)
+	SetUnicastHopLimit(v uint8) error
+	GetReceiveBufferSize() (uint64, error)
+	SetReceiveBufferSize(size uint64) error
+	GetSendBufferSize() (uint64, error)
+	SetSendBufferSize(size uint64) error
+
+	Drop()
+}
+
+// Implemented by hosts that can map network interface names to the indices
+// used as IPv6 scope IDs. wasi:sockets has no such interface, so named zones
+// can only be used with hosts that provide it.
+type NetworkInterfaces interface {
+	InterfaceIndex(name string) (uint32, bool)
+	InterfaceName(index uint32) (string, bool)
+}
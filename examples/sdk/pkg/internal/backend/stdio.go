@@ -0,0 +1,46 @@
+package backend
+
+import "fmt"
+
+// Mirrors wasi:cli/types.error-code, in the same order.
+type StdioErrorCode uint8
+
+const (
+	StdioErrorCodeIo StdioErrorCode = iota
+	StdioErrorCodeIllegalByteSequence
+	StdioErrorCodePipe
+)
+
+// An error reported by the host for one of the standard streams.
+type StdioError struct {
+	Code StdioErrorCode
+}
+
+func (e *StdioError) Error() string {
+	return fmt.Sprintf("stdio error %d", e.Code)
+}
+
+// Mirrors wasi:cli/stdin, wasi:cli/stdout and wasi:cli/stderr. Each call
+// opens a new stream; data written through separate streams reaches the host
+// in the order the streams are written to.
+type Stdio interface {
+	GetStdin() InputStream
+	GetStdout() OutputStream
+	GetStderr() OutputStream
+}
+
+// Mirrors wasi:cli/exit. A real host never returns from these; hosts that
+// cannot end the component, like the fake, record the status and return.
+type Exit interface {
+	Exit(ok bool)
+	ExitWithCode(code uint8)
+}
+
+// Mirrors wasi:cli/terminal-stdin, wasi:cli/terminal-stdout and
+// wasi:cli/terminal-stderr. Each reports whether the stream is attached to a
+// terminal.
+type Terminals interface {
+	GetTerminalStdin() bool
+	GetTerminalStdout() bool
+	GetTerminalStderr() bool
+}
@@ -0,0 +1,26 @@
+package backend
+
+// The reading end of a byte stream provided by the host.
+type InputStream interface {
+	// Block until at least one byte is available. Returns io.EOF once the
+	// stream has finished successfully, or the error the host reported for
+	// it.
+	Read(b []byte) (int, error)
+
+	// Release the stream. Any data not yet read is discarded.
+	Drop()
+}
+
+// The writing end of a byte stream consumed by the host.
+type OutputStream interface {
+	// Block until all of b has been accepted. If the host stops reading part
+	// way through, returns the number of bytes accepted so far along with
+	// the error the host reported.
+	Write(b []byte) (int, error)
+
+	// Finish the stream, then wait for the host to report its outcome.
+	Close() error
+
+	// Release the stream without waiting for the host.
+	Drop()
+}
@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The wasi:sockets bindings are converted through the raw tags of their
+// variants and enums, which the types here number in the same order. Keeping
+// the mapping apart from the bindings lets it build, and be fuzzed, outside
+// of wasip1.
+
+// The SocketError for a wasi:sockets/network.error-code tag. message is only
+// used for the "other" case.
+func socketErrorFromTag(tag uint8, message string) *SocketError {
+	if tag > uint8(SocketErrorCodeOther) {
+		return &SocketError{Code: SocketErrorCodeOther, Message: fmt.Sprintf("unrecognized error code %d", tag)}
+	}
+
+	err := &SocketError{Code: SocketErrorCode(tag)}
+	if err.Code == SocketErrorCodeOther {
+		err.Message = message
+	}
+	return err
+}
+
+// The LookupError for a wasi:sockets/ip-name-lookup.error-code tag. message
+// is only used for the "other" case.
+func lookupErrorFromTag(tag uint8, message string) *LookupError {
+	if tag > uint8(LookupErrorCodeOther) {
+		return &LookupError{Code: LookupErrorCodeOther, Message: fmt.Sprintf("unrecognized error code %d", tag)}
+	}
+
+	err := &LookupError{Code: LookupErrorCode(tag)}
+	if err.Code == LookupErrorCodeOther {
+		err.Message = message
+	}
+	return err
+}
+
+// The address family for the tag of an ip-socket-address variant.
+func addressFamilyFromTag(tag uint8) (IpAddressFamily, error) {
+	switch af := IpAddressFamily(tag); af {
+	case IpAddressFamilyIpv4, IpAddressFamilyIpv6:
+		return af, nil
+	default:
+		return 0, fmt.Errorf("ip-socket-address variant %d: %w", tag, errors.ErrUnsupported)
+	}
+}
+
+// The wasi:sockets/network.ip-address-family tag for af.
+func addressFamilyTag(af IpAddressFamily) (uint8, error) {
+	switch af {
+	case IpAddressFamilyIpv4, IpAddressFamilyIpv6:
+		return uint8(af), nil
+	default:
+		return 0, fmt.Errorf("ip-address-family %d: %w", af, errors.ErrUnsupported)
+	}
+}
@@ -0,0 +1,60 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+)
+
+// Every tag a host could send must convert without panicking, to a known
+// code, and the known ones must survive the trip unchanged.
+func FuzzWitTags(f *testing.F) {
+	for tag := range 16 {
+		f.Add(uint8(tag), "")
+	}
+	f.Add(uint8(255), "message")
+
+	f.Fuzz(func(t *testing.T, tag uint8, message string) {
+		socketErr := socketErrorFromTag(tag, message)
+		switch {
+		case socketErr.Code > SocketErrorCodeOther:
+			t.Errorf("socket error tag %d became unknown code %d", tag, socketErr.Code)
+		case tag <= uint8(SocketErrorCodeOther) && socketErr.Code != SocketErrorCode(tag):
+			t.Errorf("socket error tag %d became code %d", tag, socketErr.Code)
+		case tag < uint8(SocketErrorCodeOther) && socketErr.Message != "":
+			t.Errorf("socket error tag %d has message %q", tag, socketErr.Message)
+		case tag == uint8(SocketErrorCodeOther) && socketErr.Message != message:
+			t.Errorf("other socket error has message %q, expected %q", socketErr.Message, message)
+		case tag > uint8(SocketErrorCodeOther) && socketErr.Message == "":
+			t.Errorf("unrecognized socket error tag %d has no message", tag)
+		}
+
+		lookupErr := lookupErrorFromTag(tag, message)
+		switch {
+		case lookupErr.Code > LookupErrorCodeOther:
+			t.Errorf("lookup error tag %d became unknown code %d", tag, lookupErr.Code)
+		case tag <= uint8(LookupErrorCodeOther) && lookupErr.Code != LookupErrorCode(tag):
+			t.Errorf("lookup error tag %d became code %d", tag, lookupErr.Code)
+		case tag == uint8(LookupErrorCodeOther) && lookupErr.Message != message:
+			t.Errorf("other lookup error has message %q, expected %q", lookupErr.Message, message)
+		case tag > uint8(LookupErrorCodeOther) && lookupErr.Message == "":
+			t.Errorf("unrecognized lookup error tag %d has no message", tag)
+		}
+
+		af, err := addressFamilyFromTag(tag)
+		if err != nil {
+			if !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("ip-socket-address tag %d gave %v, expected errors.ErrUnsupported", tag, err)
+			}
+		} else if back, err := addressFamilyTag(af); err != nil || back != tag {
+			t.Errorf("ip-socket-address tag %d came back as %d, %v", tag, back, err)
+		}
+
+		if back, err := addressFamilyTag(IpAddressFamily(tag)); err == nil {
+			if af, err := addressFamilyFromTag(back); err != nil || af != IpAddressFamily(tag) {
+				t.Errorf("address family %d came back as %d, %v", tag, af, err)
+			}
+		} else if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("address family %d gave %v, expected errors.ErrUnsupported", tag, err)
+		}
+	})
+}
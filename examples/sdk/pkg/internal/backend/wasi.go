@@ -0,0 +1,10 @@
+//go:build wasip1
+
+package backend
+
+// The host provided by the wasi:* imports.
+type wasiHost struct{}
+
+func init() {
+	current = wasiHost{}
+}
@@ -0,0 +1,150 @@
+//go:build wasip1
+
+package backend
+
+import (
+	wasiEnvironment "pkg/bindings/imports/wasi_cli_environment"
+	wasiExit "pkg/bindings/imports/wasi_cli_exit"
+	wasiStderr "pkg/bindings/imports/wasi_cli_stderr"
+	wasiStdin "pkg/bindings/imports/wasi_cli_stdin"
+	wasiStdout "pkg/bindings/imports/wasi_cli_stdout"
+	wasiTerminalStderr "pkg/bindings/imports/wasi_cli_terminal_stderr"
+	wasiTerminalStdin "pkg/bindings/imports/wasi_cli_terminal_stdin"
+	wasiTerminalStdout "pkg/bindings/imports/wasi_cli_terminal_stdout"
+	wasiCliTypes "pkg/bindings/imports/wasi_cli_types"
+	wasiMonotonicClock "pkg/bindings/imports/wasi_clocks_monotonic_clock"
+	wasiSystemClock "pkg/bindings/imports/wasi_clocks_system_clock"
+	wasiInsecureRandom "pkg/bindings/imports/wasi_random_insecure"
+	wasiRandom "pkg/bindings/imports/wasi_random_random"
+	"time"
+
+	witTypes "go.bytecodealliance.org/pkg/wit/types"
+)
+
+func (wasiHost) MonotonicNow() uint64 {
+	return wasiMonotonicClock.Now()
+}
+
+func (wasiHost) MonotonicResolution() time.Duration {
+	return time.Duration(wasiMonotonicClock.GetResolution())
+}
+
+func (wasiHost) WaitFor(d time.Duration) {
+	if d > 0 {
+		wasiMonotonicClock.WaitFor(uint64(d))
+	}
+}
+
+func (wasiHost) WaitUntil(mark uint64) {
+	wasiMonotonicClock.WaitUntil(mark)
+}
+
+func (wasiHost) SystemNow() time.Time {
+	now := wasiSystemClock.Now()
+	return time.Unix(now.Seconds, int64(now.Nanoseconds))
+}
+
+func (wasiHost) SystemResolution() time.Duration {
+	return time.Duration(wasiSystemClock.GetResolution())
+}
+
+func (wasiHost) GetRandomBytes(n uint64) []byte {
+	return wasiRandom.GetRandomBytes(n)
+}
+
+func (wasiHost) GetRandomU64() uint64 {
+	return wasiRandom.GetRandomU64()
+}
+
+func (wasiHost) GetInsecureRandomBytes(n uint64) []byte {
+	return wasiInsecureRandom.GetInsecureRandomBytes(n)
+}
+
+func (wasiHost) GetInsecureRandomU64() uint64 {
+	return wasiInsecureRandom.GetInsecureRandomU64()
+}
+
+func (wasiHost) GetEnvironment() [][2]string {
+	env := wasiEnvironment.GetEnvironment()
+	pairs := make([][2]string, len(env))
+	for i, kv := range env {
+		pairs[i] = [2]string{kv.F0, kv.F1}
+	}
+	return pairs
+}
+
+func (wasiHost) GetArguments() []string {
+	return wasiEnvironment.GetArguments()
+}
+
+func (wasiHost) GetInitialCwd() (string, bool) {
+	cwd := wasiEnvironment.GetInitialCwd()
+	if cwd.IsNone() {
+		return "", false
+	}
+	return cwd.Some(), true
+}
+
+func (wasiHost) GetStdin() InputStream {
+	rx, future := wasiStdin.ReadViaStream()
+	return newWasiInputStream(rx, future, stdioError)
+}
+
+// stdin is the only one of the standard stream interfaces that creates a
+// stream<u8>, but the type is shared with stdout and stderr.
+func (wasiHost) GetStdout() OutputStream {
+	tx, txReader := wasiStdin.MakeStreamU8()
+	future := wasiStdout.WriteViaStream(txReader)
+	return newWasiOutputStream(tx, future, stdioError, &StdioError{Code: StdioErrorCodePipe})
+}
+
+func (wasiHost) GetStderr() OutputStream {
+	tx, txReader := wasiStdin.MakeStreamU8()
+	future := wasiStderr.WriteViaStream(txReader)
+	return newWasiOutputStream(tx, future, stdioError, &StdioError{Code: StdioErrorCodePipe})
+}
+
+func stdioError(code wasiCliTypes.ErrorCode) error {
+	return &StdioError{Code: StdioErrorCode(code)}
+}
+
+// The terminal resources have no methods yet, so only their presence
+// matters.
+func (wasiHost) GetTerminalStdin() bool {
+	terminal := wasiTerminalStdin.GetTerminalStdin()
+	if terminal.IsNone() {
+		return false
+	}
+	terminal.Some().Drop()
+	return true
+}
+
+func (wasiHost) GetTerminalStdout() bool {
+	terminal := wasiTerminalStdout.GetTerminalStdout()
+	if terminal.IsNone() {
+		return false
+	}
+	terminal.Some().Drop()
+	return true
+}
+
+func (wasiHost) GetTerminalStderr() bool {
+	terminal := wasiTerminalStderr.GetTerminalStderr()
+	if terminal.IsNone() {
+		return false
+	}
+	terminal.Some().Drop()
+	return true
+}
+
+func (wasiHost) Exit(ok bool) {
+	if ok {
+		wasiExit.Exit(witTypes.Ok[witTypes.Unit, witTypes.Unit](witTypes.Unit{}))
+	} else {
+		wasiExit.Exit(witTypes.Err[witTypes.Unit, witTypes.Unit](witTypes.Unit{}))
+	}
+}
+
+func (wasiHost) ExitWithCode(code uint8) {
+	wasiExit.ExitWithCode(code)
+}
@@ -0,0 +1,292 @@
+//go:build wasip1
+
+package backend
+
+import (
+	"fmt"
+	"io"
+	wasiSystemClock "pkg/bindings/imports/wasi_clocks_system_clock"
+	wasiPreopens "pkg/bindings/imports/wasi_filesystem_preopens"
+	wasiFilesystem "pkg/bindings/imports/wasi_filesystem_types"
+	"time"
+
+	witTypes "go.bytecodealliance.org/pkg/wit/types"
+)
+
+func (wasiHost) GetDirectories() []Preopen {
+	dirs := wasiPreopens.GetDirectories()
+	preopens := make([]Preopen, len(dirs))
+	for i, dir := range dirs {
+		preopens[i] = Preopen{Descriptor: &wasiDescriptor{dir.F0}, Path: dir.F1}
+	}
+	return preopens
+}
+
+type wasiDescriptor struct {
+	inner *wasiFilesystem.Descriptor
+}
+
+func (d *wasiDescriptor) ReadViaStream(offset uint64) InputStream {
+	rx, future := d.inner.ReadViaStream(offset)
+	return newWasiInputStream(rx, future, fsError)
+}
+
+func (d *wasiDescriptor) WriteViaStream(offset uint64) OutputStream {
+	tx, txReader := wasiFilesystem.MakeStreamU8()
+	future := d.inner.WriteViaStream(txReader, offset)
+	return newWasiOutputStream(tx, future, fsError, &FsError{Code: FsErrorCodePipe})
+}
+
+func (d *wasiDescriptor) SpliceViaStream(in InputStream, offset uint64) (bool, error) {
+	s, ok := in.(*wasiInputStream[wasiFilesystem.ErrorCode])
+	if !ok || s.dropped {
+		return false, nil
+	}
+
+	// The stream now belongs to the host, which reports on the write through
+	// a future of its own.
+	s.dropped = true
+	write := wasiStreamResult[wasiFilesystem.ErrorCode]{
+		future:  d.inner.WriteViaStream(s.stream, offset),
+		convert: fsError,
+	}
+	writeErr := write.wait()
+	if err := s.result.wait(); err != nil {
+		return true, err
+	}
+	return true, writeErr
+}
+
+func (d *wasiDescriptor) AppendViaStream() OutputStream {
+	tx, txReader := wasiFilesystem.MakeStreamU8()
+	future := d.inner.AppendViaStream(txReader)
+	return newWasiOutputStream(tx, future, fsError, &FsError{Code: FsErrorCodePipe})
+}
+
+func (d *wasiDescriptor) Advise(offset, length uint64, advice Advice) error {
+	_, err := fsResult(d.inner.Advise(offset, length, wasiFilesystem.Advice(advice)))
+	return err
+}
+
+func (d *wasiDescriptor) SyncData() error {
+	_, err := fsResult(d.inner.SyncData())
+	return err
+}
+
+func (d *wasiDescriptor) GetFlags() (DescriptorFlags, error) {
+	flags, err := fsResult(d.inner.GetFlags())
+	return DescriptorFlags(flags), err
+}
+
+func (d *wasiDescriptor) GetType() (DescriptorType, error) {
+	t, err := fsResult(d.inner.GetType())
+	if err != nil {
+		return 0, err
+	}
+	return fromWasiDescriptorType(t), nil
+}
+
+func (d *wasiDescriptor) SetSize(size uint64) error {
+	_, err := fsResult(d.inner.SetSize(size))
+	return err
+}
+
+func (d *wasiDescriptor) SetTimes(atime, mtime NewTimestamp) error {
+	_, err := fsResult(d.inner.SetTimes(toWasiNewTimestamp(atime), toWasiNewTimestamp(mtime)))
+	return err
+}
+
+func (d *wasiDescriptor) ReadDirectory() DirectoryEntryStream {
+	stream, future := d.inner.ReadDirectory()
+	return &wasiDirectoryEntryStream{
+		stream: stream,
+		result: wasiStreamResult[wasiFilesystem.ErrorCode]{future: future, convert: fsError},
+	}
+}
+
+func (d *wasiDescriptor) Sync() error {
+	_, err := fsResult(d.inner.Sync())
+	return err
+}
+
+func (d *wasiDescriptor) CreateDirectoryAt(path string) error {
+	_, err := fsResult(d.inner.CreateDirectoryAt(path))
+	return err
+}
+
+func (d *wasiDescriptor) Stat() (DescriptorStat, error) {
+	stat, err := fsResult(d.inner.Stat())
+	if err != nil {
+		return DescriptorStat{}, err
+	}
+	return fromWasiDescriptorStat(stat), nil
+}
+
+func (d *wasiDescriptor) StatAt(flags PathFlags, path string) (DescriptorStat, error) {
+	stat, err := fsResult(d.inner.StatAt(wasiFilesystem.PathFlags(flags), path))
+	if err != nil {
+		return DescriptorStat{}, err
+	}
+	return fromWasiDescriptorStat(stat), nil
+}
+
+func (d *wasiDescriptor) SetTimesAt(flags PathFlags, path string, atime, mtime NewTimestamp) error {
+	_, err := fsResult(d.inner.SetTimesAt(wasiFilesystem.PathFlags(flags), path, toWasiNewTimestamp(atime), toWasiNewTimestamp(mtime)))
+	return err
+}
+
+func (d *wasiDescriptor) LinkAt(oldFlags PathFlags, oldPath string, newDir Descriptor, newPath string) error {
+	other, ok := newDir.(*wasiDescriptor)
+	if !ok {
+		return &FsError{Code: FsErrorCodeBadDescriptor}
+	}
+	_, err := fsResult(d.inner.LinkAt(wasiFilesystem.PathFlags(oldFlags), oldPath, other.inner, newPath))
+	return err
+}
+
+func (d *wasiDescriptor) OpenAt(flags PathFlags, path string, openFlags OpenFlags, descFlags DescriptorFlags) (Descriptor, error) {
+	inner, err := fsResult(d.inner.OpenAt(wasiFilesystem.PathFlags(flags), path, wasiFilesystem.OpenFlags(openFlags), wasiFilesystem.DescriptorFlags(descFlags)))
+	if err != nil {
+		return nil, err
+	}
+	return &wasiDescriptor{inner}, nil
+}
+
+func (d *wasiDescriptor) ReadlinkAt(path string) (string, error) {
+	return fsResult(d.inner.ReadlinkAt(path))
+}
+
+func (d *wasiDescriptor) RemoveDirectoryAt(path string) error {
+	_, err := fsResult(d.inner.RemoveDirectoryAt(path))
+	return err
+}
+
+func (d *wasiDescriptor) RenameAt(oldPath string, newDir Descriptor, newPath string) error {
+	other, ok := newDir.(*wasiDescriptor)
+	if !ok {
+		return &FsError{Code: FsErrorCodeBadDescriptor}
+	}
+	_, err := fsResult(d.inner.RenameAt(oldPath, other.inner, newPath))
+	return err
+}
+
+func (d *wasiDescriptor) SymlinkAt(oldPath, newPath string) error {
+	_, err := fsResult(d.inner.SymlinkAt(oldPath, newPath))
+	return err
+}
+
+func (d *wasiDescriptor) UnlinkFileAt(path string) error {
+	_, err := fsResult(d.inner.UnlinkFileAt(path))
+	return err
+}
+
+func (d *wasiDescriptor) IsSameObject(other Descriptor) bool {
+	o, ok := other.(*wasiDescriptor)
+	return ok && d.inner.IsSameObject(o.inner)
+}
+
+func (d *wasiDescriptor) MetadataHash() (MetadataHashValue, error) {
+	hash, err := fsResult(d.inner.MetadataHash())
+	return MetadataHashValue{Lower: hash.Lower, Upper: hash.Upper}, err
+}
+
+func (d *wasiDescriptor) MetadataHashAt(flags PathFlags, path string) (MetadataHashValue, error) {
+	hash, err := fsResult(d.inner.MetadataHashAt(wasiFilesystem.PathFlags(flags), path))
+	return MetadataHashValue{Lower: hash.Lower, Upper: hash.Upper}, err
+}
+
+func (d *wasiDescriptor) Drop() {
+	d.inner.Drop()
+}
+
+type wasiDirectoryEntryStream struct {
+	stream  *witTypes.StreamReader[wasiFilesystem.DirectoryEntry]
+	result  wasiStreamResult[wasiFilesystem.ErrorCode]
+	dropped bool
+}
+
+func (s *wasiDirectoryEntryStream) Next() (DirectoryEntry, error) {
+	buf := make([]wasiFilesystem.DirectoryEntry, 1)
+	if readSome(s.stream, buf) > 0 {
+		return DirectoryEntry{Type: fromWasiDescriptorType(buf[0].Type), Name: buf[0].Name}, nil
+	}
+	if err := s.result.wait(); err != nil {
+		return DirectoryEntry{}, err
+	}
+	return DirectoryEntry{}, io.EOF
+}
+
+func (s *wasiDirectoryEntryStream) Drop() {
+	if s.dropped {
+		return
+	}
+	s.dropped = true
+	s.stream.Drop()
+	s.result.drop()
+}
+
+// Unwrap a result returned by the filesystem bindings.
+func fsResult[T any](result witTypes.Result[T, wasiFilesystem.ErrorCode]) (T, error) {
+	if result.IsErr() {
+		var zero T
+		return zero, fsError(result.Err())
+	}
+	return result.Ok(), nil
+}
+
+func fsError(code wasiFilesystem.ErrorCode) error {
+	return fromWitFsErrorCode(code)
+}
+
+func fromWitFsErrorCode(code wasiFilesystem.ErrorCode) *FsError {
+	if code.Tag() > wasiFilesystem.ErrorCodeOther {
+		return &FsError{Code: FsErrorCodeOther, Message: fmt.Sprintf("unrecognized error code %d", code.Tag())}
+	}
+
+	err := &FsError{Code: FsErrorCode(code.Tag())}
+	if code.Tag() == wasiFilesystem.ErrorCodeOther {
+		if message := code.Other(); message.Tag() == witTypes.OptionSome {
+			err.Message = message.Some()
+		}
+	}
+	return err
+}
+
+func fromWasiDescriptorType(t wasiFilesystem.DescriptorType) DescriptorType {
+	if t.Tag() > wasiFilesystem.DescriptorTypeOther {
+		return DescriptorTypeOther
+	}
+	return DescriptorType(t.Tag())
+}
+
+func fromWasiDescriptorStat(stat wasiFilesystem.DescriptorStat) DescriptorStat {
+	return DescriptorStat{
+		Type:                      fromWasiDescriptorType(stat.Type),
+		LinkCount:                 stat.LinkCount,
+		Size:                      stat.Size,
+		DataAccessTimestamp:       fromWasiInstant(stat.DataAccessTimestamp),
+		DataModificationTimestamp: fromWasiInstant(stat.DataModificationTimestamp),
+		StatusChangeTimestamp:     fromWasiInstant(stat.StatusChangeTimestamp),
+	}
+}
+
+func fromWasiInstant(instant witTypes.Option[wasiSystemClock.Instant]) time.Time {
+	if instant.IsNone() {
+		return time.Time{}
+	}
+	return time.Unix(instant.Some().Seconds, int64(instant.Some().Nanoseconds))
+}
+
+func toWasiNewTimestamp(t NewTimestamp) wasiFilesystem.NewTimestamp {
+	switch t.Kind {
+	case NewTimestampNow:
+		return wasiFilesystem.MakeNewTimestampNow()
+	case NewTimestampTimestamp:
+		return wasiFilesystem.MakeNewTimestampTimestamp(wasiSystemClock.Instant{
+			Seconds:     t.Time.Unix(),
+			Nanoseconds: uint32(t.Time.Nanosecond()),
+		})
+	default:
+		return wasiFilesystem.MakeNewTimestampNoChange()
+	}
+}
@@ -0,0 +1,40 @@
+//go:build wasip1
+
+package backend
+
+import (
+	"net/netip"
+	wasiNameLookup "pkg/bindings/imports/wasi_sockets_ip_name_lookup"
+	wasiSockets "pkg/bindings/imports/wasi_sockets_types"
+
+	witTypes "go.bytecodealliance.org/pkg/wit/types"
+)
+
+func (wasiHost) ResolveAddresses(name string) ([]netip.Addr, error) {
+	result := wasiNameLookup.ResolveAddresses(name)
+	if result.IsErr() {
+		return nil, fromWitLookupErrorCode(result.Err())
+	}
+
+	addrs := make([]netip.Addr, 0, len(result.Ok()))
+	for _, addr := range result.Ok() {
+		switch addr.Tag() {
+		case wasiSockets.IpAddressIpv4:
+			b := addr.Ipv4()
+			addrs = append(addrs, netip.AddrFrom4([4]byte{b.F0, b.F1, b.F2, b.F3}))
+		case wasiSockets.IpAddressIpv6:
+			addrs = append(addrs, fromWasiIpv6Address(addr.Ipv6()))
+		}
+	}
+	return addrs, nil
+}
+
+func fromWitLookupErrorCode(code wasiNameLookup.ErrorCode) *LookupError {
+	var message string
+	if code.Tag() == wasiNameLookup.ErrorCodeOther {
+		if m := code.Other(); m.Tag() == witTypes.OptionSome {
+			message = m.Some()
+		}
+	}
+	return lookupErrorFromTag(code.Tag(), message)
+}
@@ -0,0 +1,392 @@
+//go:build wasip1
+
+package backend
+
+import (
+	"encoding/binary"
+	"io"
+	"net/netip"
+	wasiSockets "pkg/bindings/imports/wasi_sockets_types"
+	"time"
+
+	witTypes "go.bytecodealliance.org/pkg/wit/types"
+)
+
+func (wasiHost) CreateTcpSocket(af IpAddressFamily) (TcpSocket, error) {
+	family, err := toWasiIpAddressFamily(af)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := socketResult(wasiSockets.TcpSocketCreate(family))
+	if err != nil {
+		return nil, err
+	}
+	return &wasiTcpSocket{inner}, nil
+}
+
+func (wasiHost) CreateUdpSocket(af IpAddressFamily) (UdpSocket, error) {
+	family, err := toWasiIpAddressFamily(af)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := socketResult(wasiSockets.UdpSocketCreate(family))
+	if err != nil {
+		return nil, err
+	}
+	return &wasiUdpSocket{inner}, nil
+}
+
+type wasiTcpSocket struct {
+	inner *wasiSockets.TcpSocket
+}
+
+func (s *wasiTcpSocket) Bind(local IpSocketAddress) error {
+	addr, err := toWasiIpSockAddr(local)
+	if err != nil {
+		return err
+	}
+	_, err = socketResult(s.inner.Bind(addr))
+	return err
+}
+
+func (s *wasiTcpSocket) Connect(remote IpSocketAddress) error {
+	addr, err := toWasiIpSockAddr(remote)
+	if err != nil {
+		return err
+	}
+	_, err = socketResult(s.inner.Connect(addr))
+	return err
+}
+
+func (s *wasiTcpSocket) Listen() (TcpAcceptor, error) {
+	stream, err := socketResult(s.inner.Listen())
+	if err != nil {
+		return nil, err
+	}
+	return &wasiTcpAcceptor{stream: stream}, nil
+}
+
+func (s *wasiTcpSocket) Send() OutputStream {
+	tx, txReader := wasiSockets.MakeStreamU8()
+	future := s.inner.Send(txReader)
+	return newWasiOutputStream(tx, future, socketError, &SocketError{Code: SocketErrorCodeConnectionBroken})
+}
+
+func (s *wasiTcpSocket) Receive() InputStream {
+	rx, future := s.inner.Receive()
+	return newWasiInputStream(rx, future, socketError)
+}
+
+func (s *wasiTcpSocket) GetLocalAddress() (IpSocketAddress, error) {
+	addr, err := socketResult(s.inner.GetLocalAddress())
+	if err != nil {
+		return IpSocketAddress{}, err
+	}
+	return fromWasiIpSocketAddr(addr)
+}
+
+func (s *wasiTcpSocket) GetRemoteAddress() (IpSocketAddress, error) {
+	addr, err := socketResult(s.inner.GetRemoteAddress())
+	if err != nil {
+		return IpSocketAddress{}, err
+	}
+	return fromWasiIpSocketAddr(addr)
+}
+
+func (s *wasiTcpSocket) GetIsListening() bool {
+	return s.inner.GetIsListening()
+}
+
+func (s *wasiTcpSocket) GetAddressFamily() IpAddressFamily {
+	return IpAddressFamily(s.inner.GetAddressFamily())
+}
+
+func (s *wasiTcpSocket) SetListenBacklogSize(size uint64) error {
+	_, err := socketResult(s.inner.SetListenBacklogSize(size))
+	return err
+}
+
+func (s *wasiTcpSocket) GetKeepAliveEnabled() (bool, error) {
+	return socketResult(s.inner.GetKeepAliveEnabled())
+}
+
+func (s *wasiTcpSocket) SetKeepAliveEnabled(v bool) error {
+	_, err := socketResult(s.inner.SetKeepAliveEnabled(v))
+	return err
+}
+
+func (s *wasiTcpSocket) GetKeepAliveIdleTime() (time.Duration, error) {
+	v, err := socketResult(s.inner.GetKeepAliveIdleTime())
+	return time.Duration(v), err
+}
+
+func (s *wasiTcpSocket) SetKeepAliveIdleTime(d time.Duration) error {
+	_, err := socketResult(s.inner.SetKeepAliveIdleTime(uint64(d)))
+	return err
+}
+
+func (s *wasiTcpSocket) GetKeepAliveInterval() (time.Duration, error) {
+	v, err := socketResult(s.inner.GetKeepAliveInterval())
+	return time.Duration(v), err
+}
+
+func (s *wasiTcpSocket) SetKeepAliveInterval(d time.Duration) error {
+	_, err := socketResult(s.inner.SetKeepAliveInterval(uint64(d)))
+	return err
+}
+
+func (s *wasiTcpSocket) GetKeepAliveCount() (uint32, error) {
+	return socketResult(s.inner.GetKeepAliveCount())
+}
+
+func (s *wasiTcpSocket) SetKeepAliveCount(v uint32) error {
+	_, err := socketResult(s.inner.SetKeepAliveCount(v))
+	return err
+}
+
+func (s *wasiTcpSocket) GetHopLimit() (uint8, error) {
+	return socketResult(s.inner.GetHopLimit())
+}
+
+func (s *wasiTcpSocket) SetHopLimit(v uint8) error {
+	_, err := socketResult(s.inner.SetHopLimit(v))
+	return err
+}
+
+func (s *wasiTcpSocket) GetReceiveBufferSize() (uint64, error) {
+	return socketResult(s.inner.GetReceiveBufferSize())
+}
+
+func (s *wasiTcpSocket) SetReceiveBufferSize(size uint64) error {
+	_, err := socketResult(s.inner.SetReceiveBufferSize(size))
+	return err
+}
+
+func (s *wasiTcpSocket) GetSendBufferSize() (uint64, error) {
+	return socketResult(s.inner.GetSendBufferSize())
+}
+
+func (s *wasiTcpSocket) SetSendBufferSize(size uint64) error {
+	_, err := socketResult(s.inner.SetSendBufferSize(size))
+	return err
+}
+
+func (s *wasiTcpSocket) Drop() {
+	s.inner.Drop()
+}
+
+type wasiTcpAcceptor struct {
+	stream *witTypes.StreamReader[*wasiSockets.TcpSocket]
+}
+
+func (a *wasiTcpAcceptor) Accept() (TcpSocket, error) {
+	buf := make([]*wasiSockets.TcpSocket, 1)
+	if readSome(a.stream, buf) > 0 {
+		return &wasiTcpSocket{buf[0]}, nil
+	}
+	return nil, io.EOF
+}
+
+func (a *wasiTcpAcceptor) Drop() {
+	a.stream.Drop()
+}
+
+type wasiUdpSocket struct {
+	inner *wasiSockets.UdpSocket
+}
+
+func (s *wasiUdpSocket) Bind(local IpSocketAddress) error {
+	addr, err := toWasiIpSockAddr(local)
+	if err != nil {
+		return err
+	}
+	_, err = socketResult(s.inner.Bind(addr))
+	return err
+}
+
+func (s *wasiUdpSocket) Connect(remote IpSocketAddress) error {
+	addr, err := toWasiIpSockAddr(remote)
+	if err != nil {
+		return err
+	}
+	_, err = socketResult(s.inner.Connect(addr))
+	return err
+}
+
+func (s *wasiUdpSocket) Disconnect() error {
+	_, err := socketResult(s.inner.Disconnect())
+	return err
+}
+
+func (s *wasiUdpSocket) Send(data []byte, remote *IpSocketAddress) error {
+	addr := witTypes.None[wasiSockets.IpSocketAddress]()
+	if remote != nil {
+		wasiAddr, err := toWasiIpSockAddr(*remote)
+		if err != nil {
+			return err
+		}
+		addr = witTypes.Some(wasiAddr)
+	}
+	_, err := socketResult(s.inner.Send(data, addr))
+	return err
+}
+
+func (s *wasiUdpSocket) Receive() ([]byte, IpSocketAddress, error) {
+	value, err := socketResult(s.inner.Receive())
+	if err != nil {
+		return nil, IpSocketAddress{}, err
+	}
+	from, err := fromWasiIpSocketAddr(value.F1)
+	if err != nil {
+		return nil, IpSocketAddress{}, err
+	}
+	return value.F0, from, nil
+}
+
+func (s *wasiUdpSocket) GetLocalAddress() (IpSocketAddress, error) {
+	addr, err := socketResult(s.inner.GetLocalAddress())
+	if err != nil {
+		return IpSocketAddress{}, err
+	}
+	return fromWasiIpSocketAddr(addr)
+}
+
+func (s *wasiUdpSocket) GetRemoteAddress() (IpSocketAddress, error) {
+	addr, err := socketResult(s.inner.GetRemoteAddress())
+	if err != nil {
+		return IpSocketAddress{}, err
+	}
+	return fromWasiIpSocketAddr(addr)
+}
+
+func (s *wasiUdpSocket) GetAddressFamily() IpAddressFamily {
+	return IpAddressFamily(s.inner.GetAddressFamily())
+}
+
+func (s *wasiUdpSocket) GetUnicastHopLimit() (uint8, error) {
+	return socketResult(s.inner.GetUnicastHopLimit())
+}
+
+func (s *wasiUdpSocket) SetUnicastHopLimit(v uint8) error {
+	_, err := socketResult(s.inner.SetUnicastHopLimit(v))
+	return err
+}
+
+func (s *wasiUdpSocket) GetReceiveBufferSize() (uint64, error) {
+	return socketResult(s.inner.GetReceiveBufferSize())
+}
+
+func (s *wasiUdpSocket) SetReceiveBufferSize(size uint64) error {
+	_, err := socketResult(s.inner.SetReceiveBufferSize(size))
+	return err
+}
+
+func (s *wasiUdpSocket) GetSendBufferSize() (uint64, error) {
+	return socketResult(s.inner.GetSendBufferSize())
+}
+
+func (s *wasiUdpSocket) SetSendBufferSize(size uint64) error {
+	_, err := socketResult(s.inner.SetSendBufferSize(size))
+	return err
+}
+
+func (s *wasiUdpSocket) Drop() {
+	s.inner.Drop()
+}
+
+// Unwrap a result returned by the sockets bindings.
+func socketResult[T any](result witTypes.Result[T, wasiSockets.ErrorCode]) (T, error) {
+	if result.IsErr() {
+		var zero T
+		return zero, socketError(result.Err())
+	}
+	return result.Ok(), nil
+}
+
+func socketError(code wasiSockets.ErrorCode) error {
+	return fromWitErrorCode(code)
+}
+
+func fromWitErrorCode(code wasiSockets.ErrorCode) *SocketError {
+	var message string
+	if code.Tag() == wasiSockets.ErrorCodeOther {
+		if m := code.Other(); m.Tag() == witTypes.OptionSome {
+			message = m.Some()
+		}
+	}
+	return socketErrorFromTag(code.Tag(), message)
+}
+
+func toWasiIpSockAddr(addr IpSocketAddress) (wasiSockets.IpSocketAddress, error) {
+	if !addr.Addr.IsValid() {
+		return wasiSockets.IpSocketAddress{}, &SocketError{Code: SocketErrorCodeInvalidArgument}
+	}
+	if addr.Addr.Is4() {
+		b := addr.Addr.As4()
+		return wasiSockets.MakeIpSocketAddressIpv4(wasiSockets.Ipv4SocketAddress{
+			Address: witTypes.Tuple4[uint8, uint8, uint8, uint8]{
+				F0: b[0],
+				F1: b[1],
+				F2: b[2],
+				F3: b[3],
+			},
+			Port: addr.Port,
+		}), nil
+	}
+
+	b := addr.Addr.As16()
+	return wasiSockets.MakeIpSocketAddressIpv6(wasiSockets.Ipv6SocketAddress{
+		FlowInfo: addr.FlowInfo,
+		Address: witTypes.Tuple8[uint16, uint16, uint16, uint16, uint16, uint16, uint16, uint16]{
+			F0: binary.BigEndian.Uint16(b[0:2]),
+			F1: binary.BigEndian.Uint16(b[2:4]),
+			F2: binary.BigEndian.Uint16(b[4:6]),
+			F3: binary.BigEndian.Uint16(b[6:8]),
+			F4: binary.BigEndian.Uint16(b[8:10]),
+			F5: binary.BigEndian.Uint16(b[10:12]),
+			F6: binary.BigEndian.Uint16(b[12:14]),
+			F7: binary.BigEndian.Uint16(b[14:16]),
+		},
+		Port:    addr.Port,
+		ScopeId: addr.ScopeId,
+	}), nil
+}
+
+func fromWasiIpSocketAddr(addr wasiSockets.IpSocketAddress) (IpSocketAddress, error) {
+	af, err := addressFamilyFromTag(addr.Tag())
+	if err != nil {
+		return IpSocketAddress{}, err
+	}
+	if af == IpAddressFamilyIpv4 {
+		v4 := addr.Ipv4()
+		b := v4.Address
+		return IpSocketAddress{
+			Addr: netip.AddrFrom4([4]byte{b.F0, b.F1, b.F2, b.F3}),
+			Port: v4.Port,
+		}, nil
+	}
+	v6 := addr.Ipv6()
+	return IpSocketAddress{
+		Addr:     fromWasiIpv6Address(v6.Address),
+		Port:     v6.Port,
+		FlowInfo: v6.FlowInfo,
+		ScopeId:  v6.ScopeId,
+	}, nil
+}
+
+func fromWasiIpv6Address(a wasiSockets.Ipv6Address) netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint16(b[0:2], a.F0)
+	binary.BigEndian.PutUint16(b[2:4], a.F1)
+	binary.BigEndian.PutUint16(b[4:6], a.F2)
+	binary.BigEndian.PutUint16(b[6:8], a.F3)
+	binary.BigEndian.PutUint16(b[8:10], a.F4)
+	binary.BigEndian.PutUint16(b[10:12], a.F5)
+	binary.BigEndian.PutUint16(b[12:14], a.F6)
+	binary.BigEndian.PutUint16(b[14:16], a.F7)
+	return netip.AddrFrom16(b)
+}
+
+func toWasiIpAddressFamily(af IpAddressFamily) (wasiSockets.IpAddressFamily, error) {
+	return addressFamilyTag(af)
+}
@@ -0,0 +1,143 @@
+//go:build wasip1
+
+package backend
+
+import (
+	"io"
+
+	witTypes "go.bytecodealliance.org/pkg/wit/types"
+)
+
+// The outcome of a stream, which the host reports through a future once the
+// stream has finished. The future is read at most once.
+type wasiStreamResult[E any] struct {
+	future  *witTypes.FutureReader[witTypes.Result[witTypes.Unit, E]]
+	convert func(E) error
+	read    bool
+	err     error
+}
+
+// Wait for the stream to finish. Returns nil if it finished successfully.
+func (r *wasiStreamResult[E]) wait() error {
+	if !r.read {
+		r.read = true
+		result := r.future.Read()
+		if result.IsErr() {
+			r.err = r.convert(result.Err())
+		}
+	}
+	return r.err
+}
+
+// Release the future if its result was never needed.
+func (r *wasiStreamResult[E]) drop() {
+	if !r.read {
+		r.read = true
+		r.future.Drop()
+	}
+}
+
+// Read into buf, blocking until the host writes at least one item or drops
+// its end, and return the number read, or 0 once the writer is gone.
+//
+// Read blocks until the host completes it, but the host may complete it
+// without any items, so read again until some arrive.
+func readSome[T any](stream *witTypes.StreamReader[T], buf []T) int {
+	for !stream.WriterDropped() {
+		if n := stream.Read(buf); n > 0 {
+			return int(n)
+		}
+	}
+	return 0
+}
+
+type wasiInputStream[E any] struct {
+	stream  *witTypes.StreamReader[uint8]
+	result  wasiStreamResult[E]
+	dropped bool
+}
+
+func newWasiInputStream[E any](stream *witTypes.StreamReader[uint8], future *witTypes.FutureReader[witTypes.Result[witTypes.Unit, E]], convert func(E) error) *wasiInputStream[E] {
+	return &wasiInputStream[E]{
+		stream: stream,
+		result: wasiStreamResult[E]{future: future, convert: convert},
+	}
+}
+
+func (s *wasiInputStream[E]) Read(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+	if n := readSome(s.stream, b); n > 0 {
+		return n, nil
+	}
+	if err := s.result.wait(); err != nil {
+		return 0, err
+	}
+	return 0, io.EOF
+}
+
+func (s *wasiInputStream[E]) Drop() {
+	if s.dropped {
+		return
+	}
+	s.dropped = true
+	s.stream.Drop()
+	s.result.drop()
+}
+
+type wasiOutputStream[E any] struct {
+	stream *witTypes.StreamWriter[uint8]
+	result wasiStreamResult[E]
+
+	// Reported when the host stops reading without saying why.
+	broken error
+
+	dropped bool
+}
+
+func newWasiOutputStream[E any](stream *witTypes.StreamWriter[uint8], future *witTypes.FutureReader[witTypes.Result[witTypes.Unit, E]], convert func(E) error, broken error) *wasiOutputStream[E] {
+	return &wasiOutputStream[E]{
+		stream: stream,
+		result: wasiStreamResult[E]{future: future, convert: convert},
+		broken: broken,
+	}
+}
+
+func (s *wasiOutputStream[E]) Write(b []byte) (int, error) {
+	if s.stream.ReaderDropped() {
+		return 0, s.failure()
+	}
+
+	n := int(s.stream.WriteAll(b))
+	if n < len(b) {
+		// WriteAll only comes up short once the host has stopped reading.
+		return n, s.failure()
+	}
+	return n, nil
+}
+
+// The error that stopped the host from reading the stream.
+func (s *wasiOutputStream[E]) failure() error {
+	if err := s.result.wait(); err != nil {
+		return err
+	}
+	return s.broken
+}
+
+func (s *wasiOutputStream[E]) Close() error {
+	if !s.dropped {
+		s.dropped = true
+		s.stream.Drop()
+	}
+	return s.result.wait()
+}
+
+func (s *wasiOutputStream[E]) Drop() {
+	if s.dropped {
+		return
+	}
+	s.dropped = true
+	s.stream.Drop()
+	s.result.drop()
+}
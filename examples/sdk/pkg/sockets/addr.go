@@ -0,0 +1,129 @@
+package sockets
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"pkg/internal/backend"
+	"strconv"
+)
+
+// An IP socket address, including the IPv6 flow information that
+// netip.AddrPort has no room for.
+//
+// The IPv6 scope ID is carried as the zone of AddrPort, as a decimal
+// interface index such as "fe80::1%2". wasi:sockets has no way to look up
+// interfaces, so in a component an interface name such as "%eth0" fails with
+// an error matching errors.ErrUnsupported, and addresses reported by the
+// host carry the index. Names only work against hosts that can map them to
+// indices, such as the fake host from package pkg/fake, which also report
+// addresses with the name.
+type SocketAddress struct {
+	AddrPort netip.AddrPort
+
+	// The IPv6 flow information. Ignored for IPv4.
+	FlowInfo uint32
+}
+
+func (a SocketAddress) String() string {
+	return a.AddrPort.String()
+}
+
+func toHostSockAddr(sa SocketAddress) (backend.IpSocketAddress, error) {
+	addr := sa.AddrPort.Addr()
+	if !addr.IsValid() {
+		return backend.IpSocketAddress{}, errors.New("invalid IP address")
+	}
+	if addr.Is4() {
+		return backend.IpSocketAddress{Addr: addr, Port: sa.AddrPort.Port()}, nil
+	}
+
+	scope, err := zoneToScopeId(addr.Zone())
+	if err != nil {
+		return backend.IpSocketAddress{}, err
+	}
+	return backend.IpSocketAddress{
+		Addr:     addr.WithZone(""),
+		Port:     sa.AddrPort.Port(),
+		FlowInfo: sa.FlowInfo,
+		ScopeId:  scope,
+	}, nil
+}
+
+func fromHostSockAddr(addr backend.IpSocketAddress) SocketAddress {
+	if addr.Addr.Is4() {
+		return SocketAddress{AddrPort: netip.AddrPortFrom(addr.Addr, addr.Port)}
+	}
+	ip := addr.Addr.WithZone(scopeIdToZone(addr.ScopeId))
+	return SocketAddress{
+		AddrPort: netip.AddrPortFrom(ip, addr.Port),
+		FlowInfo: addr.FlowInfo,
+	}
+}
+
+// The scope ID named by an IPv6 zone: a decimal interface index, or the name
+// of an interface the host knows about. The wasi host knows none.
+func zoneToScopeId(zone string) (uint32, error) {
+	if zone == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseUint(zone, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+
+	interfaces, ok := backend.Current().(backend.NetworkInterfaces)
+	if !ok {
+		return 0, fmt.Errorf("zone %q: host cannot resolve interface names: %w", zone, errors.ErrUnsupported)
+	}
+	index, ok := interfaces.InterfaceIndex(zone)
+	if !ok {
+		return 0, fmt.Errorf("zone %q: no such interface: %w", zone, ErrInvalidArgument)
+	}
+	return index, nil
+}
+
+// The zone for an IPv6 scope ID: the interface name if the host knows it, and
+// the decimal index otherwise.
+func scopeIdToZone(scope uint32) string {
+	if scope == 0 {
+		return ""
+	}
+	if interfaces, ok := backend.Current().(backend.NetworkInterfaces); ok {
+		if name, ok := interfaces.InterfaceName(scope); ok {
+			return name
+		}
+	}
+	return strconv.FormatUint(uint64(scope), 10)
+}
+
+func toHostAddressFamily(af IpAddressFamily) (backend.IpAddressFamily, error) {
+	switch af {
+	case IpAddressFamilyIpv4:
+		return backend.IpAddressFamilyIpv4, nil
+	case IpAddressFamilyIpv6:
+		return backend.IpAddressFamilyIpv6, nil
+	default:
+		return 0, fmt.Errorf("address family %d: %w", af, errors.ErrUnsupported)
+	}
+}
+
+// Convert an address family reported by the host. Families the SDK does not
+// know about are reported as 0.
+func fromHostAddressFamily(af backend.IpAddressFamily) IpAddressFamily {
+	switch af {
+	case backend.IpAddressFamilyIpv4:
+		return IpAddressFamilyIpv4
+	case backend.IpAddressFamilyIpv6:
+		return IpAddressFamilyIpv6
+	default:
+		return 0
+	}
+}
+
+// The address family needed to reach the provided IP address.
+func addressFamilyOf(ip netip.Addr) IpAddressFamily {
+	if ip.Unmap().Is4() {
+		return IpAddressFamilyIpv4
+	}
+	return IpAddressFamilyIpv6
+}
@@ -0,0 +1,219 @@
+package sockets
+
+import (
+	"errors"
+	"net/netip"
+	"pkg/fake"
+	"pkg/internal/backend"
+	"testing"
+)
+
+func TestToHostSockAddr(t *testing.T) {
+	host := fake.Install(t)
+	host.AddInterface("eth0", 2)
+
+	ll := netip.MustParseAddr("fe80::1")
+	tests := []struct {
+		addr     SocketAddress
+		expected backend.IpSocketAddress
+	}{
+		{
+			SocketAddress{AddrPort: netip.MustParseAddrPort("127.0.0.1:80"), FlowInfo: 7},
+			backend.IpSocketAddress{Addr: netip.MustParseAddr("127.0.0.1"), Port: 80},
+		},
+		{
+			SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1]:80"), FlowInfo: 7},
+			backend.IpSocketAddress{Addr: ll, Port: 80, FlowInfo: 7},
+		},
+		{
+			SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1%5]:80")},
+			backend.IpSocketAddress{Addr: ll, Port: 80, ScopeId: 5},
+		},
+		{
+			SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1%eth0]:80"), FlowInfo: 1},
+			backend.IpSocketAddress{Addr: ll, Port: 80, FlowInfo: 1, ScopeId: 2},
+		},
+	}
+	for _, test := range tests {
+		got, err := toHostSockAddr(test.addr)
+		if err != nil || got != test.expected {
+			t.Errorf("toHostSockAddr(%v) = %+v, %v, expected %+v", test.addr, got, err, test.expected)
+		}
+	}
+
+	_, err := toHostSockAddr(SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1%wlan0]:80")})
+	if !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("unknown zone gave %v, expected ErrInvalidArgument", err)
+	}
+	if _, err := toHostSockAddr(SocketAddress{}); err == nil {
+		t.Error("toHostSockAddr accepted an invalid address")
+	}
+}
+
+func TestFromHostSockAddr(t *testing.T) {
+	host := fake.Install(t)
+	host.AddInterface("eth0", 2)
+
+	ll := netip.MustParseAddr("fe80::1")
+	tests := []struct {
+		addr     backend.IpSocketAddress
+		expected SocketAddress
+	}{
+		{
+			backend.IpSocketAddress{Addr: netip.MustParseAddr("127.0.0.1"), Port: 80},
+			SocketAddress{AddrPort: netip.MustParseAddrPort("127.0.0.1:80")},
+		},
+		{
+			backend.IpSocketAddress{Addr: ll, Port: 80, FlowInfo: 7},
+			SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1]:80"), FlowInfo: 7},
+		},
+		{
+			backend.IpSocketAddress{Addr: ll, Port: 80, ScopeId: 2},
+			SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1%eth0]:80")},
+		},
+		{
+			backend.IpSocketAddress{Addr: ll, Port: 80, FlowInfo: 3, ScopeId: 9},
+			SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1%9]:80"), FlowInfo: 3},
+		},
+	}
+	for _, test := range tests {
+		if got := fromHostSockAddr(test.addr); got != test.expected {
+			t.Errorf("fromHostSockAddr(%+v) = %+v, expected %+v", test.addr, got, test.expected)
+		}
+	}
+}
+
+// Hides the fake's network interfaces, like wasi:sockets does.
+type hostWithoutInterfaces struct {
+	backend.Host
+}
+
+func TestZonesWithoutInterfaces(t *testing.T) {
+	host := fake.Install(t)
+	backend.Set(hostWithoutInterfaces{host})
+
+	_, err := toHostSockAddr(SocketAddress{AddrPort: netip.MustParseAddrPort("[fe80::1%lo]:80")})
+	if !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("named zone gave %v, expected errors.ErrUnsupported", err)
+	}
+
+	addr := fromHostSockAddr(backend.IpSocketAddress{Addr: netip.MustParseAddr("fe80::1"), Port: 80, ScopeId: 1})
+	if got := addr.AddrPort.Addr().Zone(); got != "1" {
+		t.Errorf("zone = %q, expected the numeric scope ID", got)
+	}
+}
+
+func TestSocketAddressRoundTrip(t *testing.T) {
+	fake.Install(t)
+
+	local := SocketAddress{AddrPort: netip.MustParseAddrPort("[::1%lo]:0"), FlowInfo: 42}
+	tcp, err := NewSocket(IpAddressFamilyIpv6)
+	if err != nil {
+		t.Fatalf("NewSocket: %v", err)
+	}
+	defer tcp.Close()
+	if err := tcp.BindSocketAddress(local); err != nil {
+		t.Fatalf("BindSocketAddress: %v", err)
+	}
+	got, err := tcp.GetLocalSocketAddress()
+	if err != nil || got.AddrPort.Addr() != local.AddrPort.Addr() || got.FlowInfo != 42 {
+		t.Errorf("TCP GetLocalSocketAddress() = %+v, %v", got, err)
+	}
+	if ap, _ := tcp.GetLocalAddress(); ap.Addr().Zone() != "lo" {
+		t.Errorf("TCP GetLocalAddress() = %v, lost the zone", ap)
+	}
+
+	udp, err := NewUdpSocket(IpAddressFamilyIpv6)
+	if err != nil {
+		t.Fatalf("NewUdpSocket: %v", err)
+	}
+	defer udp.Close()
+	if err := udp.BindSocketAddress(local); err != nil {
+		t.Fatalf("BindSocketAddress: %v", err)
+	}
+	remote := SocketAddress{AddrPort: netip.MustParseAddrPort("[::1%1]:9"), FlowInfo: 5}
+	if err := udp.ConnectSocketAddress(remote); err != nil {
+		t.Fatalf("ConnectSocketAddress: %v", err)
+	}
+	got, err = udp.GetRemoteSocketAddress()
+	if err != nil || got.AddrPort.String() != "[::1%lo]:9" || got.FlowInfo != 5 {
+		t.Errorf("UDP GetRemoteSocketAddress() = %+v, %v", got, err)
+	}
+}
+
+func TestUnknownAddressFamily(t *testing.T) {
+	fake.Install(t)
+
+	if _, err := NewSocket(3); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("NewSocket(3) = %v, expected errors.ErrUnsupported", err)
+	}
+	if _, err := NewUdpSocket(0); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("NewUdpSocket(0) = %v, expected errors.ErrUnsupported", err)
+	}
+	if af := fromHostAddressFamily(7); af != 0 {
+		t.Errorf("fromHostAddressFamily(7) = %d, expected 0", af)
+	}
+}
+
+// Conversions must never panic, whatever they are given. Valid addresses
+// must survive a round trip through the host representation.
+func FuzzSocketAddress(f *testing.F) {
+	for _, seed := range []string{"127.0.0.1:80", "[::1]:0", "[fe80::1%lo]:443", "[fe80::1%7]:1", "[::ffff:10.0.0.1]:8", "[fe80::1%eth9]:1", ":80", "1.2.3.4"} {
+		f.Add(seed, uint32(0))
+	}
+
+	f.Fuzz(func(t *testing.T, s string, flowInfo uint32) {
+		fake.Install(t)
+
+		ip, err := netip.ParseAddrPort(s)
+		if err != nil {
+			return
+		}
+		addr := SocketAddress{AddrPort: ip, FlowInfo: flowInfo}
+		host, err := toHostSockAddr(addr)
+		if err != nil {
+			return
+		}
+
+		got := fromHostSockAddr(host)
+		if got.AddrPort.Addr().WithZone("") != ip.Addr().WithZone("") || got.AddrPort.Port() != ip.Port() {
+			t.Errorf("%v became %v", addr, got)
+		}
+		if ip.Addr().Is6() && got.FlowInfo != flowInfo {
+			t.Errorf("flow info %d became %d", flowInfo, got.FlowInfo)
+		}
+		if again, err := toHostSockAddr(got); err != nil || again != host {
+			t.Errorf("%v converted back to %+v, %v, expected %+v", got, again, err, host)
+		}
+	})
+}
+
+// Variant tags outside the ones the SDK knows about must be reported as
+// errors rather than panicking.
+func FuzzVariantTags(f *testing.F) {
+	for tag := range 8 {
+		f.Add(uint8(tag))
+	}
+	f.Add(uint8(255))
+
+	f.Fuzz(func(t *testing.T, tag uint8) {
+		fake.Install(t)
+
+		if af, err := toHostAddressFamily(IpAddressFamily(tag)); err == nil && fromHostAddressFamily(af) != IpAddressFamily(tag) {
+			t.Errorf("address family %d did not survive a round trip", tag)
+		}
+		fromHostAddressFamily(backend.IpAddressFamily(tag))
+
+		if s, err := NewSocket(IpAddressFamily(tag)); err == nil {
+			s.Close()
+		} else if !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("NewSocket(%d) = %v", tag, err)
+		}
+
+		err, _ := fromHostError(&backend.SocketError{Code: backend.SocketErrorCode(tag)})
+		var code ErrorCode
+		if !errors.As(err, &code) {
+			t.Errorf("socket error %d became %v, which is not an ErrorCode", tag, err)
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// Keepalive settings for a TCP socket. Keepalive is left at the host default
+// (disabled) unless Enable is set. Zero values for the remaining fields leave
+// the corresponding host default in place.
+type KeepAliveConfig struct {
+	Enable bool
+
+	// How long the connection must be idle before the first keepalive
+	// packet is sent.
+	Idle time.Duration
+
+	// The time between keepalive packets.
+	Interval time.Duration
+
+	// The number of unacknowledged keepalive packets before the connection
+	// is aborted.
+	Count uint32
+}
+
+// Options for creating listening sockets. The zero value leaves every option
+// at the host default.
+type ListenConfig struct {
+	// Hints the desired listen queue size. Host implementations might
+	// ignore this.
+	Backlog uint64
+
+	// Keepalive settings, which are inherited by accepted connections.
+	KeepAlive KeepAliveConfig
+
+	// The hop limit for outgoing packets. For UDP sockets this is the
+	// unicast hop limit.
+	HopLimit uint8
+
+	// Kernel buffer space reserved for receiving and sending.
+	ReceiveBufferSize uint64
+	SendBufferSize    uint64
+
+	// Receives the events of the listening socket and of every connection
+	// it accepts. Not used for UDP.
+	Tracer Tracer
+}
+
+// Options for creating connected TCP sockets. The zero value leaves every
+// option at the host default.
+type DialConfig struct {
+	KeepAlive KeepAliveConfig
+
+	// The hop limit for outgoing packets.
+	HopLimit uint8
+
+	// Kernel buffer space reserved for receiving and sending.
+	ReceiveBufferSize uint64
+	SendBufferSize    uint64
+
+	// Receives the events of the connection, including the connect itself.
+	Tracer Tracer
+}
+
+// Create a TCP socket listening on the provided local IP address and port.
+// The network must be "tcp", "tcp4" or "tcp6". As with net.Listen, an address
+// without a host, such as ":80", listens on every local address: IPv4 ones,
+// unless the network is "tcp6". Options are applied before the socket is
+// bound, so that buffer sizes are in place before the listen queue
+// exists and accepted connections inherit them. If the host rejects an
+// option, the returned *OpError names it in Op, e.g.
+// "set-receive-buffer-size".
+func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (*Listener, error) {
+	ip, af, err := resolveAddr("listen", network, address, "tcp", "tcp4", "tcp6")
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Addr: ip, Err: err}
+	}
+
+	s, err := NewSocket(af)
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := lc.listen(s, ip)
+	if err != nil {
+		s.Close()
+		return nil, withAddr(err, ip)
+	}
+	return l, nil
+}
+
+func (lc *ListenConfig) listen(s *TcpSocket, ip netip.AddrPort) (*Listener, error) {
+	err := applyTcpOptions(s, lc.KeepAlive, lc.HopLimit, lc.ReceiveBufferSize, lc.SendBufferSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Bind(ip.String()); err != nil {
+		return nil, err
+	}
+	if lc.Backlog != 0 {
+		if err := s.SetListenBacklogSize(lc.Backlog); err != nil {
+			return nil, err
+		}
+	}
+	if lc.Tracer != nil {
+		s.SetTracer(lc.Tracer)
+	}
+
+	l, err := s.Listen()
+	if err != nil {
+		return nil, err
+	}
+	l.socket = s
+	return l, nil
+}
+
+// Create a UDP socket bound to the provided local IP address and port. The
+// network must be "udp", "udp4" or "udp6". Backlog and KeepAlive do not apply
+// to UDP and are ignored.
+func (lc *ListenConfig) ListenPacket(ctx context.Context, network, address string) (*UdpConn, error) {
+	ip, af, err := resolveAddr("listen", network, address, "udp", "udp4", "udp6")
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, &OpError{Op: "listen", Net: network, Addr: ip, Err: err}
+	}
+
+	c, err := NewUdpSocket(af)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lc.listenPacket(c, ip); err != nil {
+		c.Close()
+		return nil, withAddr(err, ip)
+	}
+	return c, nil
+}
+
+func (lc *ListenConfig) listenPacket(c *UdpConn, ip netip.AddrPort) error {
+	if lc.HopLimit != 0 {
+		if err := c.SetUnicastHopLimit(lc.HopLimit); err != nil {
+			return err
+		}
+	}
+	if lc.ReceiveBufferSize != 0 {
+		if err := c.SetReceiveBufferSize(lc.ReceiveBufferSize); err != nil {
+			return err
+		}
+	}
+	if lc.SendBufferSize != 0 {
+		if err := c.SetSendBufferSize(lc.SendBufferSize); err != nil {
+			return err
+		}
+	}
+	return c.Bind(ip.String())
+}
+
+// Connect to the provided remote IP address and port. The network must be
+// "tcp", "tcp4" or "tcp6". An address without a host, such as ":80", connects
+// to the loopback address. Options are applied before connecting. If the host
+// rejects an option, the returned *OpError names it in Op.
+func (d *DialConfig) Dial(ctx context.Context, network, address string) (*TcpSocket, error) {
+	ip, af, err := resolveAddr("connect", network, address, "tcp", "tcp4", "tcp6")
+	if err != nil {
+		return nil, err
+	}
+	return d.dial(ctx, ip, af)
+}
+
+// Connect to ip, which resolveAddr has already checked against the network.
+func (d *DialConfig) dial(ctx context.Context, ip netip.AddrPort, af IpAddressFamily) (*TcpSocket, error) {
+	s, err := NewSocket(af)
+	if err != nil {
+		return nil, err
+	}
+	if d.Tracer != nil {
+		s.SetTracer(d.Tracer)
+	}
+
+	err = applyTcpOptions(s, d.KeepAlive, d.HopLimit, d.ReceiveBufferSize, d.SendBufferSize)
+	if err == nil {
+		err = s.ConnectContext(ctx, ip.String())
+	}
+	if err != nil {
+		s.Close()
+		return nil, withAddr(err, ip)
+	}
+	return s, nil
+}
+
+// Apply the options shared by listening and connecting TCP sockets, skipping
+// any that are left at their zero value.
+func applyTcpOptions(s *TcpSocket, keepAlive KeepAliveConfig, hopLimit uint8, receiveBufferSize, sendBufferSize uint64) error {
+	if keepAlive.Enable {
+		if err := s.SetKeepAliveEnabled(true); err != nil {
+			return err
+		}
+		if keepAlive.Idle != 0 {
+			if err := s.SetKeepAliveIdleTime(keepAlive.Idle); err != nil {
+				return err
+			}
+		}
+		if keepAlive.Interval != 0 {
+			if err := s.SetKeepAliveInterval(keepAlive.Interval); err != nil {
+				return err
+			}
+		}
+		if keepAlive.Count != 0 {
+			if err := s.SetKeepAliveCount(keepAlive.Count); err != nil {
+				return err
+			}
+		}
+	}
+	if hopLimit != 0 {
+		if err := s.SetHopLimit(hopLimit); err != nil {
+			return err
+		}
+	}
+	if receiveBufferSize != 0 {
+		if err := s.SetReceiveBufferSize(receiveBufferSize); err != nil {
+			return err
+		}
+	}
+	if sendBufferSize != 0 {
+		if err := s.SetSendBufferSize(sendBufferSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse an IP address and port for one of the provided networks, returning
+// the address family the socket needs. A "4" or "6" suffix on the network
+// restricts the address to that family. As with net.Listen and net.Dial, the
+// host may be left out, as in ":80", for every local address when listening
+// and the local system when connecting. Without a "6" suffix, that is IPv4.
+func resolveAddr(op, network, address string, networks ...string) (netip.AddrPort, IpAddressFamily, error) {
+	known := false
+	for _, n := range networks {
+		known = known || n == network
+	}
+	if !known {
+		return netip.AddrPort{}, 0, &OpError{Op: op, Net: network, Err: net.UnknownNetworkError(network)}
+	}
+
+	ip, err := netip.ParseAddrPort(address)
+	if err != nil && strings.HasPrefix(address, ":") {
+		ip, err = hostlessAddr(op, network, address)
+	}
+	if err != nil {
+		return netip.AddrPort{}, 0, &OpError{Op: op, Net: network, Err: err}
+	}
+
+	switch network[len(network)-1] {
+	case '4':
+		if !ip.Addr().Unmap().Is4() {
+			return netip.AddrPort{}, 0, &OpError{Op: op, Net: network, Addr: ip, Err: &net.AddrError{Err: "address is not IPv4", Addr: address}}
+		}
+	case '6':
+		if ip.Addr().Is4() {
+			return netip.AddrPort{}, 0, &OpError{Op: op, Net: network, Addr: ip, Err: &net.AddrError{Err: "address is not IPv6", Addr: address}}
+		}
+		return ip, IpAddressFamilyIpv6, nil
+	}
+
+	af := addressFamilyOf(ip.Addr())
+	if af == IpAddressFamilyIpv4 {
+		ip = netip.AddrPortFrom(ip.Addr().Unmap(), ip.Port())
+	}
+	return ip, af, nil
+}
+
+// The address for a ":port" address without a host.
+func hostlessAddr(op, network, address string) (netip.AddrPort, error) {
+	ip, err := netip.ParseAddrPort("0.0.0.0" + address)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+
+	ipv6 := network[len(network)-1] == '6'
+	var addr netip.Addr
+	switch {
+	case op == "listen" && ipv6:
+		addr = netip.IPv6Unspecified()
+	case op == "listen":
+		addr = netip.IPv4Unspecified()
+	case ipv6:
+		addr = netip.IPv6Loopback()
+	default:
+		addr = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+	}
+	return netip.AddrPortFrom(addr, ip.Port()), nil
+}
+
+// Attach the address to an *OpError that was reported without one.
+func withAddr(err error, ip netip.AddrPort) error {
+	var opErr *OpError
+	if errors.As(err, &opErr) && !opErr.Addr.IsValid() {
+		opErr.Addr = ip
+	}
+	return err
+}
@@ -0,0 +1,234 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"pkg/fake"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResolveAddr(t *testing.T) {
+	tests := []struct {
+		network string
+		address string
+		want    netip.AddrPort
+		af      IpAddressFamily
+		err     bool
+	}{
+		{"tcp", "127.0.0.1:80", netip.MustParseAddrPort("127.0.0.1:80"), IpAddressFamilyIpv4, false},
+		{"tcp", "[::1]:80", netip.MustParseAddrPort("[::1]:80"), IpAddressFamilyIpv6, false},
+		{"tcp", "[::ffff:127.0.0.1]:80", netip.MustParseAddrPort("127.0.0.1:80"), IpAddressFamilyIpv4, false},
+		{"tcp4", "127.0.0.1:80", netip.MustParseAddrPort("127.0.0.1:80"), IpAddressFamilyIpv4, false},
+		{"tcp4", "[::1]:80", netip.AddrPort{}, 0, true},
+		{"tcp6", "[::ffff:127.0.0.1]:80", netip.MustParseAddrPort("[::ffff:127.0.0.1]:80"), IpAddressFamilyIpv6, false},
+		{"tcp6", "127.0.0.1:80", netip.AddrPort{}, 0, true},
+		{"tcp", "localhost:80", netip.AddrPort{}, 0, true},
+		{"tcp", ":80", netip.MustParseAddrPort("0.0.0.0:80"), IpAddressFamilyIpv4, false},
+		{"tcp4", ":80", netip.MustParseAddrPort("0.0.0.0:80"), IpAddressFamilyIpv4, false},
+		{"tcp6", ":80", netip.MustParseAddrPort("[::]:80"), IpAddressFamilyIpv6, false},
+		{"tcp", ":http", netip.AddrPort{}, 0, true},
+		{"udp", "127.0.0.1:80", netip.AddrPort{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network+" "+tt.address, func(t *testing.T) {
+			ip, af, err := resolveAddr("listen", tt.network, tt.address, "tcp", "tcp4", "tcp6")
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ip != tt.want || af != tt.af {
+				t.Errorf("resolveAddr() = %v, %v, expected %v, %v", ip, af, tt.want, tt.af)
+			}
+		})
+	}
+}
+
+func TestResolveAddrUnknownNetwork(t *testing.T) {
+	_, _, err := resolveAddr("listen", "unix", "/tmp/sock", "tcp", "tcp4", "tcp6")
+
+	var unknown net.UnknownNetworkError
+	if !errors.As(err, &unknown) {
+		t.Errorf("expected net.UnknownNetworkError, got %v", err)
+	}
+}
+
+func TestListenConfigOptions(t *testing.T) {
+	host := fake.Install(t)
+
+	lc := ListenConfig{
+		KeepAlive:         KeepAliveConfig{Enable: true, Idle: time.Minute, Interval: 5 * time.Second, Count: 3},
+		HopLimit:          7,
+		ReceiveBufferSize: 1 << 16,
+		SendBufferSize:    1 << 17,
+	}
+	l, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	s := l.socket
+	if v, err := s.GetKeepAliveEnabled(); err != nil || !v {
+		t.Errorf("GetKeepAliveEnabled() = %v, %v", v, err)
+	}
+	if v, err := s.GetKeepAliveIdleTime(); err != nil || v != time.Minute {
+		t.Errorf("GetKeepAliveIdleTime() = %v, %v", v, err)
+	}
+	if v, err := s.GetKeepAliveInterval(); err != nil || v != 5*time.Second {
+		t.Errorf("GetKeepAliveInterval() = %v, %v", v, err)
+	}
+	if v, err := s.GetKeepAliveCount(); err != nil || v != 3 {
+		t.Errorf("GetKeepAliveCount() = %v, %v", v, err)
+	}
+	if v, err := s.GetHopLimit(); err != nil || v != 7 {
+		t.Errorf("GetHopLimit() = %v, %v", v, err)
+	}
+	if v, err := s.GetReceiveBufferSize(); err != nil || v != 1<<16 {
+		t.Errorf("GetReceiveBufferSize() = %v, %v", v, err)
+	}
+	if v, err := s.GetSendBufferSize(); err != nil || v != 1<<17 {
+		t.Errorf("GetSendBufferSize() = %v, %v", v, err)
+	}
+
+	// An option failing stops the socket from being bound at all, so the
+	// error injected for bind is still waiting afterwards.
+	optionErr := errors.New("option rejected")
+	bindErr := errors.New("bind rejected")
+	host.InjectError("tcp-socket.set-send-buffer-size", optionErr)
+	host.InjectError("tcp-socket.bind", bindErr)
+	if _, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0"); !errors.Is(err, optionErr) {
+		t.Fatalf("Listen error = %v, expected the option's error", err)
+	}
+	if _, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0"); !errors.Is(err, bindErr) {
+		t.Errorf("Listen error = %v, expected bind to have been skipped", err)
+	}
+}
+
+func TestListenPacketOptions(t *testing.T) {
+	host := fake.Install(t)
+
+	lc := ListenConfig{HopLimit: 7, ReceiveBufferSize: 1 << 16, SendBufferSize: 1 << 17}
+	c, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: %v", err)
+	}
+	defer c.Close()
+
+	if v, err := c.GetUnicastHopLimit(); err != nil || v != 7 {
+		t.Errorf("GetUnicastHopLimit() = %v, %v", v, err)
+	}
+	if v, err := c.GetReceiveBufferSize(); err != nil || v != 1<<16 {
+		t.Errorf("GetReceiveBufferSize() = %v, %v", v, err)
+	}
+	if v, err := c.GetSendBufferSize(); err != nil || v != 1<<17 {
+		t.Errorf("GetSendBufferSize() = %v, %v", v, err)
+	}
+
+	optionErr := errors.New("option rejected")
+	bindErr := errors.New("bind rejected")
+	host.InjectError("udp-socket.set-receive-buffer-size", optionErr)
+	host.InjectError("udp-socket.bind", bindErr)
+	if _, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0"); !errors.Is(err, optionErr) {
+		t.Fatalf("ListenPacket error = %v, expected the option's error", err)
+	}
+	if _, err := lc.ListenPacket(context.Background(), "udp", "127.0.0.1:0"); !errors.Is(err, bindErr) {
+		t.Errorf("ListenPacket error = %v, expected bind to have been skipped", err)
+	}
+}
+
+func TestDialConfigOptions(t *testing.T) {
+	host := fake.Install(t)
+
+	l, err := (&ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	d := DialConfig{KeepAlive: KeepAliveConfig{Enable: true, Count: 4}, HopLimit: 9, ReceiveBufferSize: 1 << 15}
+	s, err := d.Dial(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer s.Close()
+
+	if v, err := s.GetKeepAliveEnabled(); err != nil || !v {
+		t.Errorf("GetKeepAliveEnabled() = %v, %v", v, err)
+	}
+	if v, err := s.GetKeepAliveCount(); err != nil || v != 4 {
+		t.Errorf("GetKeepAliveCount() = %v, %v", v, err)
+	}
+	if v, err := s.GetHopLimit(); err != nil || v != 9 {
+		t.Errorf("GetHopLimit() = %v, %v", v, err)
+	}
+	if v, err := s.GetReceiveBufferSize(); err != nil || v != 1<<15 {
+		t.Errorf("GetReceiveBufferSize() = %v, %v", v, err)
+	}
+
+	optionErr := errors.New("option rejected")
+	connectErr := errors.New("connect rejected")
+	host.InjectError("tcp-socket.set-hop-limit", optionErr)
+	host.InjectError("tcp-socket.connect", connectErr)
+	if _, err := d.Dial(context.Background(), "tcp", l.Addr().String()); !errors.Is(err, optionErr) {
+		t.Fatalf("Dial error = %v, expected the option's error", err)
+	}
+	if _, err := d.Dial(context.Background(), "tcp", l.Addr().String()); !errors.Is(err, connectErr) {
+		t.Errorf("Dial error = %v, expected connect to have been skipped", err)
+	}
+}
+
+func TestConfigRejectedOption(t *testing.T) {
+	host := fake.Install(t)
+
+	for _, test := range []struct {
+		inject string
+		op     string
+		lc     ListenConfig
+	}{
+		{"tcp-socket.set-keep-alive-enabled", "set-keep-alive-enabled", ListenConfig{KeepAlive: KeepAliveConfig{Enable: true}}},
+		{"tcp-socket.set-keep-alive-idle-time", "set-keep-alive-idle-time", ListenConfig{KeepAlive: KeepAliveConfig{Enable: true, Idle: time.Second}}},
+		{"tcp-socket.set-hop-limit", "set-hop-limit", ListenConfig{HopLimit: 1}},
+		{"tcp-socket.set-receive-buffer-size", "set-receive-buffer-size", ListenConfig{ReceiveBufferSize: 1}},
+		{"tcp-socket.set-send-buffer-size", "set-send-buffer-size", ListenConfig{SendBufferSize: 1}},
+		{"tcp-socket.set-listen-backlog-size", "set-listen-backlog-size", ListenConfig{Backlog: 1}},
+	} {
+		host.InjectError(test.inject, ErrNotSupported)
+		_, err := test.lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+
+		var opErr *OpError
+		if !errors.As(err, &opErr) || opErr.Op != test.op || !errors.Is(err, ErrNotSupported) {
+			t.Errorf("%s: Listen error = %v", test.op, err)
+			continue
+		}
+		if opErr.Addr != netip.MustParseAddrPort("127.0.0.1:0") {
+			t.Errorf("%s: Addr = %v", test.op, opErr.Addr)
+		}
+	}
+}
+
+func TestHostlessAddress(t *testing.T) {
+	fake.Install(t)
+
+	l, err := (&ListenConfig{}).Listen(context.Background(), "tcp", ":0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	addr := l.Addr().(*net.TCPAddr)
+	if !addr.IP.IsUnspecified() || addr.IP.To4() == nil {
+		t.Errorf("listening on %v", addr)
+	}
+
+	s, err := (&DialConfig{}).Dial(context.Background(), "tcp", ":"+strconv.Itoa(addr.Port))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer s.Close()
+	if remote, _ := s.GetRemoteAddress(); remote != netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), uint16(addr.Port)) {
+		t.Errorf("connected to %v", remote)
+	}
+}
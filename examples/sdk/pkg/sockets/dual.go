@@ -0,0 +1,172 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// Listens on the same port over both IPv4 and IPv6, merging the inbound
+// connections of each into a single stream. Where the host supports only one
+// of the families, only that one is used.
+//
+// Connections are accepted in the background as they arrive, so up to one
+// connection per family may be waiting for the next call to Accept.
+type DualListener struct {
+	listeners []*Listener
+	addr      netip.AddrPort
+
+	accepted chan *TcpSocket
+	done     chan struct{}
+	once     sync.Once
+
+	// Closed once every listener has stopped delivering connections, after
+	// which err holds the reason the first one stopped.
+	ended chan struct{}
+	err   error
+}
+
+var _ net.Listener = (*DualListener)(nil)
+
+// Listen on the provided port over both IPv4 and IPv6. A port of 0 picks a
+// free port, which is the same for both families.
+func ListenDual(port uint16) (*DualListener, error) {
+	var lc ListenConfig
+	return lc.ListenDual(context.Background(), port)
+}
+
+// Listen on the provided port over both IPv4 and IPv6, applying the options
+// in lc to both sockets. Fails only if neither family could be used, or if a
+// family the host supports could not be bound, e.g. because the port is in
+// use.
+func (lc *ListenConfig) ListenDual(ctx context.Context, port uint16) (*DualListener, error) {
+	var listeners []*Listener
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	var firstErr error
+	for _, ip := range []netip.Addr{netip.IPv4Unspecified(), netip.IPv6Unspecified()} {
+		l, err := lc.Listen(ctx, "tcp", netip.AddrPortFrom(ip, port).String())
+		if err != nil {
+			if !errors.Is(err, ErrNotSupported) {
+				closeAll()
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		// Both families need to share the port the host picked.
+		port = l.addr.Port()
+		listeners = append(listeners, l)
+	}
+	if len(listeners) == 0 {
+		return nil, firstErr
+	}
+
+	d := &DualListener{
+		listeners: listeners,
+		addr:      listeners[len(listeners)-1].addr,
+		accepted:  make(chan *TcpSocket),
+		done:      make(chan struct{}),
+		ended:     make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	for _, l := range listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := d.pump(l); err != nil {
+				errOnce.Do(func() { d.err = err })
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(d.ended)
+	}()
+
+	return d, nil
+}
+
+// Accept connections from l and hand them over to Accept, until l stops
+// delivering connections or d is closed.
+func (d *DualListener) pump(l *Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case d.accepted <- conn:
+		case <-d.done:
+			conn.Close()
+			return nil
+		}
+	}
+}
+
+// Wait for the next inbound connection on either family. The returned
+// net.Conn is a *TcpSocket.
+func (d *DualListener) Accept() (net.Conn, error) {
+	conn, err := d.AcceptContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Wait for the next inbound connection on either family, giving up once ctx
+// is done. Once both families have stopped delivering connections, returns
+// the reason the first one stopped.
+func (d *DualListener) AcceptContext(ctx context.Context) (*TcpSocket, error) {
+	select {
+	case conn := <-d.accepted:
+		return conn, nil
+	case <-d.ended:
+		if isClosedChan(d.done) {
+			return nil, closedError("tcp", "accept")
+		}
+		return nil, d.err
+	case <-d.done:
+		return nil, closedError("tcp", "accept")
+	case <-ctx.Done():
+		return nil, &OpError{Op: "accept", Net: "tcp", Err: ctx.Err()}
+	}
+}
+
+// Stop listening on both families. Blocked accepts return net.ErrClosed.
+func (d *DualListener) Close() error {
+	d.once.Do(func() {
+		close(d.done)
+		for _, l := range d.listeners {
+			l.Close()
+		}
+	})
+	return nil
+}
+
+// Returns the local address as a *net.TCPAddr. When both families are in
+// use, this is the IPv6 address.
+func (d *DualListener) Addr() net.Addr {
+	return net.TCPAddrFromAddrPort(d.addr)
+}
+
+// The local addresses of each family in use.
+func (d *DualListener) Addrs() []netip.AddrPort {
+	addrs := make([]netip.AddrPort, len(d.listeners))
+	for i, l := range d.listeners {
+		addrs[i] = l.addr
+	}
+	return addrs
+}
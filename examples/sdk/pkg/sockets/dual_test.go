@@ -0,0 +1,115 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"pkg/fake"
+	"testing"
+	"time"
+)
+
+func TestListenDual(t *testing.T) {
+	fake.Install(t)
+
+	d, err := ListenDual(0)
+	if err != nil {
+		t.Fatalf("ListenDual: %v", err)
+	}
+	defer d.Close()
+
+	addrs := d.Addrs()
+	if len(addrs) != 2 || addrs[0].Addr() != netip.IPv4Unspecified() || addrs[1].Addr() != netip.IPv6Unspecified() {
+		t.Fatalf("Addrs() = %v", addrs)
+	}
+	port := addrs[0].Port()
+	if port == 0 || addrs[1].Port() != port {
+		t.Errorf("families listen on different ports: %v", addrs)
+	}
+	if addr := d.Addr().(*net.TCPAddr); addr.AddrPort() != addrs[1] {
+		t.Errorf("Addr() = %v", addr)
+	}
+
+	// Connections over either family come out of the same Accept.
+	families := make(map[IpAddressFamily]bool)
+	for _, ip := range []string{"127.0.0.1", "::1"} {
+		client := dial(t, netip.AddrPortFrom(netip.MustParseAddr(ip), port).String())
+		server, err := d.AcceptContext(context.Background())
+		if err != nil {
+			t.Fatalf("Accept: %v", err)
+		}
+		defer server.Close()
+
+		families[server.GetAddressFamily()] = true
+		local, _ := client.GetLocalAddress()
+		if remote, _ := server.GetRemoteAddress(); remote != local {
+			t.Errorf("accepted a connection from %v, expected %v", remote, local)
+		}
+	}
+	if !families[IpAddressFamilyIpv4] || !families[IpAddressFamilyIpv6] {
+		t.Errorf("accepted families = %v", families)
+	}
+}
+
+func TestListenDualSingleFamily(t *testing.T) {
+	host := fake.Install(t)
+
+	// The host cannot create IPv4 sockets.
+	host.InjectError("create-tcp-socket", ErrNotSupported)
+	d, err := ListenDual(0)
+	if err != nil {
+		t.Fatalf("ListenDual: %v", err)
+	}
+	defer d.Close()
+
+	addrs := d.Addrs()
+	if len(addrs) != 1 || addrs[0].Addr() != netip.IPv6Unspecified() {
+		t.Fatalf("Addrs() = %v", addrs)
+	}
+	dial(t, netip.AddrPortFrom(netip.IPv6Loopback(), addrs[0].Port()).String())
+	if _, err := d.Accept(); err != nil {
+		t.Errorf("Accept: %v", err)
+	}
+
+	// Other failures are reported rather than skipped.
+	host.InjectError("tcp-socket.bind", ErrAddressInUse)
+	if _, err := ListenDual(0); !errors.Is(err, ErrAddressInUse) {
+		t.Errorf("ListenDual error = %v, expected ErrAddressInUse", err)
+	}
+
+	host.InjectError("create-tcp-socket", ErrNotSupported)
+	host.InjectError("create-tcp-socket", ErrNotSupported)
+	if _, err := ListenDual(0); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ListenDual error = %v, expected ErrNotSupported", err)
+	}
+}
+
+func TestDualListenerClose(t *testing.T) {
+	fake.Install(t)
+
+	d, err := ListenDual(0)
+	if err != nil {
+		t.Fatalf("ListenDual: %v", err)
+	}
+
+	accepted := make(chan error, 1)
+	go func() {
+		_, err := d.Accept()
+		accepted <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	d.Close()
+
+	select {
+	case err := <-accepted:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Accept error = %v, expected net.ErrClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not unblock Accept")
+	}
+	if _, err := d.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept after Close error = %v, expected net.ErrClosed", err)
+	}
+}
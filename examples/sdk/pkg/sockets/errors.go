@@ -0,0 +1,210 @@
+package sockets
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"pkg/internal/backend"
+	"syscall"
+)
+
+// An error code reported by the host. Each code is a sentinel error that can
+// be matched with errors.Is. Where a POSIX equivalent exists, the code also
+// matches the corresponding syscall.Errno.
+type ErrorCode uint8
+
+const (
+	_ ErrorCode = iota
+	ErrAccessDenied
+	ErrNotSupported
+	ErrInvalidArgument
+	ErrOutOfMemory
+	ErrTimeout
+	ErrInvalidState
+	ErrAddressNotBindable
+	ErrAddressInUse
+	ErrRemoteUnreachable
+	ErrConnectionRefused
+	ErrConnectionBroken
+	ErrConnectionReset
+	ErrConnectionAborted
+	ErrDatagramTooLarge
+	ErrOther
+)
+
+var errorCodeMessages = [...]string{
+	ErrAccessDenied:       "access denied",
+	ErrNotSupported:       "operation is not supported",
+	ErrInvalidArgument:    "invalid argument",
+	ErrOutOfMemory:        "out of memory",
+	ErrTimeout:            "operation timed out",
+	ErrInvalidState:       "operation is not valid in the socket's current state",
+	ErrAddressNotBindable: "address is not bindable",
+	ErrAddressInUse:       "address already in use",
+	ErrRemoteUnreachable:  "remote address is not reachable",
+	ErrConnectionRefused:  "connection refused",
+	ErrConnectionBroken:   "connection broken",
+	ErrConnectionReset:    "connection reset",
+	ErrConnectionAborted:  "connection aborted",
+	ErrDatagramTooLarge:   "datagram too large",
+	ErrOther:              "other error",
+}
+
+// The POSIX equivalents, as documented by wasi:sockets.
+var errorCodeErrnos = [...]syscall.Errno{
+	ErrAccessDenied:       syscall.EACCES,
+	ErrNotSupported:       syscall.ENOTSUP,
+	ErrInvalidArgument:    syscall.EINVAL,
+	ErrOutOfMemory:        syscall.ENOMEM,
+	ErrTimeout:            syscall.ETIMEDOUT,
+	ErrAddressNotBindable: syscall.EADDRNOTAVAIL,
+	ErrAddressInUse:       syscall.EADDRINUSE,
+	ErrRemoteUnreachable:  syscall.EHOSTUNREACH,
+	ErrConnectionRefused:  syscall.ECONNREFUSED,
+	ErrConnectionBroken:   syscall.EPIPE,
+	ErrConnectionReset:    syscall.ECONNRESET,
+	ErrConnectionAborted:  syscall.ECONNABORTED,
+	ErrDatagramTooLarge:   syscall.EMSGSIZE,
+}
+
+func (c ErrorCode) Error() string {
+	if int(c) < len(errorCodeMessages) && errorCodeMessages[c] != "" {
+		return errorCodeMessages[c]
+	}
+	return fmt.Sprintf("unknown error code %d", uint8(c))
+}
+
+// Reports whether the code matches target. Besides the code itself, this
+// matches the equivalent syscall.Errno, and errors.ErrUnsupported for
+// ErrNotSupported.
+func (c ErrorCode) Is(target error) bool {
+	if c == ErrNotSupported && target == errors.ErrUnsupported {
+		return true
+	}
+	errno := c.Errno()
+	return errno != 0 && (target == errno || errno.Is(target))
+}
+
+// The POSIX equivalent of the code, or 0 if there is none.
+func (c ErrorCode) Errno() syscall.Errno {
+	if int(c) < len(errorCodeErrnos) {
+		return errorCodeErrnos[c]
+	}
+	return 0
+}
+
+// Whether the code reports that the operation timed out.
+func (c ErrorCode) Timeout() bool {
+	return c == ErrTimeout
+}
+
+// Describes a failed socket operation. Err is the underlying cause, which is
+// an ErrorCode when the host reported the failure, net.ErrClosed when the
+// socket was already closed, and os.ErrDeadlineExceeded when a deadline
+// passed.
+type OpError struct {
+	// The operation that failed, e.g. "connect" or "set-keep-alive-enabled".
+	Op string
+
+	// Either "tcp" or "udp".
+	Net string
+
+	// The address involved in the operation, if any.
+	Addr netip.AddrPort
+
+	Err error
+
+	// Additional detail provided by the host for ErrOther, if any.
+	Detail string
+}
+
+var _ net.Error = (*OpError)(nil)
+
+func (e *OpError) Error() string {
+	s := e.Net + " " + e.Op
+	if e.Addr.IsValid() {
+		s += " " + e.Addr.String()
+	}
+	s += ": " + e.Err.Error()
+	if e.Detail != "" {
+		s += ": " + e.Detail
+	}
+	return s
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// The host error code, if the failure was reported by the host.
+func (e *OpError) Code() (ErrorCode, bool) {
+	var code ErrorCode
+	if errors.As(e.Err, &code) {
+		return code, true
+	}
+	return 0, false
+}
+
+// Whether the operation timed out, either because of a deadline or because
+// the host reported ErrTimeout.
+func (e *OpError) Timeout() bool {
+	var t interface{ Timeout() bool }
+	return errors.As(e.Err, &t) && t.Timeout()
+}
+
+// Deprecated: Temporary errors are not well-defined. Use Timeout instead.
+func (e *OpError) Temporary() bool {
+	return e.Timeout()
+}
+
+// Build an *OpError from an error returned by the host.
+func newOpError(network, op string, addr netip.AddrPort, err error) *OpError {
+	err, detail := fromHostError(err)
+	return &OpError{Op: op, Net: network, Addr: addr, Err: err, Detail: detail}
+}
+
+// Build an *OpError for a socket that has already been closed.
+func closedError(network, op string) *OpError {
+	return &OpError{Op: op, Net: network, Err: net.ErrClosed}
+}
+
+// Build an *OpError for an operation whose deadline has passed.
+func timeoutError(network, op string) *OpError {
+	return &OpError{Op: op, Net: network, Err: os.ErrDeadlineExceeded}
+}
+
+// The ErrorCode for each backend.SocketErrorCode.
+var hostErrorCodes = [...]ErrorCode{
+	backend.SocketErrorCodeAccessDenied:       ErrAccessDenied,
+	backend.SocketErrorCodeNotSupported:       ErrNotSupported,
+	backend.SocketErrorCodeInvalidArgument:    ErrInvalidArgument,
+	backend.SocketErrorCodeOutOfMemory:        ErrOutOfMemory,
+	backend.SocketErrorCodeTimeout:            ErrTimeout,
+	backend.SocketErrorCodeInvalidState:       ErrInvalidState,
+	backend.SocketErrorCodeAddressNotBindable: ErrAddressNotBindable,
+	backend.SocketErrorCodeAddressInUse:       ErrAddressInUse,
+	backend.SocketErrorCodeRemoteUnreachable:  ErrRemoteUnreachable,
+	backend.SocketErrorCodeConnectionRefused:  ErrConnectionRefused,
+	backend.SocketErrorCodeConnectionBroken:   ErrConnectionBroken,
+	backend.SocketErrorCodeConnectionReset:    ErrConnectionReset,
+	backend.SocketErrorCodeConnectionAborted:  ErrConnectionAborted,
+	backend.SocketErrorCodeDatagramTooLarge:   ErrDatagramTooLarge,
+	backend.SocketErrorCodeOther:              ErrOther,
+}
+
+// Convert an error returned by the host, returning the message attached to
+// `SocketErrorCodeOther` separately. Errors that do not carry a host error
+// code, such as those injected by a fake host, are passed through as is.
+func fromHostError(err error) (error, string) {
+	var hostErr *backend.SocketError
+	if !errors.As(err, &hostErr) {
+		return err, ""
+	}
+
+	if int(hostErr.Code) >= len(hostErrorCodes) {
+		return ErrOther, fmt.Sprintf("unrecognized error code %d", hostErr.Code)
+	}
+	return hostErrorCodes[hostErr.Code], hostErr.Message
+}
@@ -0,0 +1,174 @@
+package sockets
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"pkg/internal/backend"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+var allErrorCodes = []ErrorCode{
+	ErrAccessDenied,
+	ErrNotSupported,
+	ErrInvalidArgument,
+	ErrOutOfMemory,
+	ErrTimeout,
+	ErrInvalidState,
+	ErrAddressNotBindable,
+	ErrAddressInUse,
+	ErrRemoteUnreachable,
+	ErrConnectionRefused,
+	ErrConnectionBroken,
+	ErrConnectionReset,
+	ErrConnectionAborted,
+	ErrDatagramTooLarge,
+	ErrOther,
+}
+
+func TestFromHostError(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  backend.SocketErrorCode
+		want  ErrorCode
+		errno syscall.Errno
+	}{
+		{"access denied", backend.SocketErrorCodeAccessDenied, ErrAccessDenied, syscall.EACCES},
+		{"not supported", backend.SocketErrorCodeNotSupported, ErrNotSupported, syscall.ENOTSUP},
+		{"invalid argument", backend.SocketErrorCodeInvalidArgument, ErrInvalidArgument, syscall.EINVAL},
+		{"out of memory", backend.SocketErrorCodeOutOfMemory, ErrOutOfMemory, syscall.ENOMEM},
+		{"timeout", backend.SocketErrorCodeTimeout, ErrTimeout, syscall.ETIMEDOUT},
+		{"invalid state", backend.SocketErrorCodeInvalidState, ErrInvalidState, 0},
+		{"address not bindable", backend.SocketErrorCodeAddressNotBindable, ErrAddressNotBindable, syscall.EADDRNOTAVAIL},
+		{"address in use", backend.SocketErrorCodeAddressInUse, ErrAddressInUse, syscall.EADDRINUSE},
+		{"remote unreachable", backend.SocketErrorCodeRemoteUnreachable, ErrRemoteUnreachable, syscall.EHOSTUNREACH},
+		{"connection refused", backend.SocketErrorCodeConnectionRefused, ErrConnectionRefused, syscall.ECONNREFUSED},
+		{"connection broken", backend.SocketErrorCodeConnectionBroken, ErrConnectionBroken, syscall.EPIPE},
+		{"connection reset", backend.SocketErrorCodeConnectionReset, ErrConnectionReset, syscall.ECONNRESET},
+		{"connection aborted", backend.SocketErrorCodeConnectionAborted, ErrConnectionAborted, syscall.ECONNABORTED},
+		{"datagram too large", backend.SocketErrorCodeDatagramTooLarge, ErrDatagramTooLarge, syscall.EMSGSIZE},
+		{"other", backend.SocketErrorCodeOther, ErrOther, 0},
+	}
+
+	addr := netip.MustParseAddrPort("192.0.2.1:80")
+	seen := make(map[backend.SocketErrorCode]bool)
+	for _, tt := range tests {
+		seen[tt.code] = true
+
+		t.Run(tt.name, func(t *testing.T) {
+			err := newOpError("tcp", "connect", addr, &backend.SocketError{Code: tt.code})
+
+			if code, ok := err.Code(); !ok || code != tt.want {
+				t.Errorf("Code() = %v, %v, expected %v", code, ok, tt.want)
+			}
+			for _, other := range allErrorCodes {
+				if got := errors.Is(err, other); got != (other == tt.want) {
+					t.Errorf("errors.Is(err, %v) = %v", other, got)
+				}
+			}
+			if tt.errno != 0 && !errors.Is(err, tt.errno) {
+				t.Errorf("expected error to match %v", tt.errno)
+			}
+			if got := err.Timeout(); got != (tt.want == ErrTimeout) {
+				t.Errorf("Timeout() = %v", got)
+			}
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("host error unexpectedly matches a local error")
+			}
+
+			expected := "tcp connect 192.0.2.1:80: " + tt.want.Error()
+			if err.Error() != expected {
+				t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+			}
+		})
+	}
+
+	for code := range backend.SocketErrorCodeOther + 1 {
+		if !seen[code] {
+			t.Errorf("no test case for error code %d", code)
+		}
+	}
+}
+
+func TestUnrecognizedHostError(t *testing.T) {
+	err := newOpError("tcp", "connect", netip.AddrPort{}, &backend.SocketError{Code: backend.SocketErrorCodeOther + 1})
+
+	if !errors.Is(err, ErrOther) {
+		t.Errorf("expected error to match ErrOther")
+	}
+	if expected := "unrecognized error code 15"; err.Detail != expected {
+		t.Errorf("Detail = %q, expected %q", err.Detail, expected)
+	}
+}
+
+func TestNonHostErrorPassesThrough(t *testing.T) {
+	err := newOpError("tcp", "read", netip.AddrPort{}, ErrConnectionReset)
+
+	if err.Err != ErrConnectionReset || err.Detail != "" {
+		t.Errorf("Err, Detail = %v, %q", err.Err, err.Detail)
+	}
+}
+
+func TestErrorCodeMessages(t *testing.T) {
+	for _, code := range allErrorCodes {
+		if strings.HasPrefix(code.Error(), "unknown") {
+			t.Errorf("error code %d has no message", uint8(code))
+		}
+	}
+}
+
+func TestOtherErrorDetail(t *testing.T) {
+	err := newOpError("udp", "send", netip.AddrPort{}, &backend.SocketError{Code: backend.SocketErrorCodeOther, Message: "host exploded"})
+
+	if !errors.Is(err, ErrOther) {
+		t.Errorf("expected error to match ErrOther")
+	}
+	if err.Detail != "host exploded" {
+		t.Errorf("Detail = %q", err.Detail)
+	}
+	if expected := "udp send: other error: host exploded"; err.Error() != expected {
+		t.Errorf("Error() = %q, expected %q", err.Error(), expected)
+	}
+}
+
+func TestStandardLibraryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+	}{
+		{"access denied is a permission error", ErrAccessDenied, os.ErrPermission},
+		{"not supported is unsupported", ErrNotSupported, errors.ErrUnsupported},
+		{"closed socket", closedError("tcp", "read"), net.ErrClosed},
+		{"deadline", timeoutError("tcp", "read"), os.ErrDeadlineExceeded},
+		{"datagram too large", &OpError{Op: "send", Net: "udp", Err: &DatagramTooLargeError{Size: 70000}}, ErrDatagramTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.target) {
+				t.Errorf("expected %v to match %v", tt.err, tt.target)
+			}
+		})
+	}
+}
+
+func TestOpErrorIsNetError(t *testing.T) {
+	var err error = timeoutError("tcp", "read")
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a net.Error that timed out")
+	}
+
+	err = closedError("tcp", "read")
+	if !errors.As(err, &netErr) || netErr.Timeout() {
+		t.Errorf("expected a net.Error that did not time out")
+	}
+	if _, ok := err.(*OpError).Code(); ok {
+		t.Errorf("expected a local error to have no host error code")
+	}
+}
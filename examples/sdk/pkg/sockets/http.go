@@ -0,0 +1,64 @@
+package sockets
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Create an http.Transport that connects over SDK sockets, for use with
+// http.Client in components that import wasi:sockets but not wasi:http.
+// Host names in URLs are resolved with LookupHost, and connections are kept
+// alive and reused like with http.DefaultTransport.
+//
+// d configures the connections, and may be nil. https URLs are dialed with a
+// copy of tlsConfig, which may also be nil. Since the host has no system
+// certificate pool, tlsConfig.RootCAs should be set to reach servers outside
+// of tests.
+//
+// Proxies from the environment are not used. TLSHandshakeTimeout is applied
+// by the transport's own TLS dialer, so changes to it take effect as usual.
+func NewTransport(d *DialConfig, tlsConfig *tls.Config) *http.Transport {
+	if d == nil {
+		d = &DialConfig{}
+	}
+
+	t := &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return d.dialHost(ctx, network, address)
+		},
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+	// net/http leaves the handshake, and so its timeout, to DialTLSContext.
+	t.DialTLSContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return d.dialTLSHost(ctx, network, address, tlsConfig, t.TLSHandshakeTimeout)
+	}
+	return t
+}
+
+// Like DialTLS, except that the host part of address may be a name, which is
+// also the name the server's certificate is verified against unless config
+// sets ServerName. A non-zero handshakeTimeout limits the handshake alone.
+func (d *DialConfig) dialTLSHost(ctx context.Context, network, address string, config *tls.Config, handshakeTimeout time.Duration) (*tls.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, &OpError{Op: "connect", Net: network, Err: err}
+	}
+
+	conn, err := d.dialHost(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if handshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, handshakeTimeout)
+		defer cancel()
+	}
+	return clientHandshake(ctx, conn, config, host)
+}
@@ -0,0 +1,142 @@
+package sockets
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"pkg/fake"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Counts accepted connections.
+type acceptCounter struct {
+	NopTracer
+	accepted atomic.Int32
+}
+
+func (c *acceptCounter) Accept(*TcpSocket) { c.accepted.Add(1) }
+
+// Serve handler over HTTP on l.
+func serveHTTP(t *testing.T, l net.Listener, handler http.Handler) {
+	t.Helper()
+
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+}
+
+var helloHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "hello from %s", r.Host)
+})
+
+func get(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body of %s: %v", url, err)
+	}
+	return string(body)
+}
+
+func TestTransportGet(t *testing.T) {
+	fake.Install(t)
+
+	counter := &acceptCounter{}
+	l, err := (&ListenConfig{Tracer: counter}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	serveHTTP(t, l.NetListener(), helloHandler)
+
+	transport := NewTransport(nil, nil)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	host := fmt.Sprintf("localhost:%d", l.Addr().(*net.TCPAddr).Port)
+	for range 3 {
+		if got, want := get(t, client, "http://"+host+"/"), "hello from "+host; got != want {
+			t.Errorf("body = %q, expected %q", got, want)
+		}
+	}
+	if n := counter.accepted.Load(); n != 1 {
+		t.Errorf("server accepted %d connections, expected the first to be reused", n)
+	}
+}
+
+func TestTransportGetTLS(t *testing.T) {
+	fake.Install(t)
+	cert, certPEM := selfSignedCert(t)
+
+	l, err := ListenTLS(context.Background(), "tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("ListenTLS: %v", err)
+	}
+	serveHTTP(t, l, helloHandler)
+
+	roots, _ := RootCAsFromPEM(certPEM)
+	transport := NewTransport(nil, &tls.Config{RootCAs: roots})
+	defer transport.CloseIdleConnections()
+
+	url := "https://" + l.Addr().String() + "/"
+	if got := get(t, &http.Client{Transport: transport}, url); got != "hello from "+l.Addr().String() {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestTransportUnknownHost(t *testing.T) {
+	fake.Install(t)
+
+	client := &http.Client{Transport: NewTransport(nil, nil)}
+	_, err := client.Get("http://nowhere.invalid/")
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("GET = %v, expected a not found *net.DNSError", err)
+	}
+}
+
+func TestTransportTLSHandshakeTimeout(t *testing.T) {
+	fake.Install(t)
+
+	// A server that accepts connections but never answers the handshake.
+	l, err := (&ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	transport := NewTransport(nil, nil)
+	transport.TLSHandshakeTimeout = 50 * time.Millisecond
+	client := &http.Client{Transport: transport}
+
+	start := time.Now()
+	_, err = client.Get("https://" + l.Addr().String() + "/")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GET = %v, expected the handshake to time out", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("GET took %v", elapsed)
+	}
+}
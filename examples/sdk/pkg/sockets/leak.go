@@ -0,0 +1,62 @@
+package sockets
+
+import (
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// A socket or listener that was garbage collected without being closed. Its
+// host handles were never released.
+type Leak struct {
+	// "tcp-socket", "tcp-listener" or "udp-socket".
+	Resource string
+
+	// The stack trace of the goroutine that created the resource.
+	Stack string
+}
+
+var leakReporter atomic.Pointer[func(Leak)]
+
+// Report sockets and listeners that are garbage collected without having
+// been closed. report is called from a goroutine of its own and must not
+// block. Recording a stack trace for every resource is slow, so this is meant
+// for debugging and tests. Pass nil to turn detection off again.
+//
+// Only resources created while detection is on are tracked.
+func DetectLeaks(report func(Leak)) {
+	if report == nil {
+		leakReporter.Store(nil)
+		return
+	}
+	leakReporter.Store(&report)
+}
+
+// Shared between a resource and the cleanup attached to its owner. It must
+// not point back at the owner, or the owner would never become unreachable.
+type leakRecord struct {
+	resource string
+	stack    string
+	closed   atomic.Bool
+}
+
+// Start tracking owner if leak detection is on. r is the resource guarding
+// owner's handles.
+func trackLeaks[T any](owner *T, r *resource, kind string) {
+	if leakReporter.Load() == nil {
+		return
+	}
+
+	record := &leakRecord{resource: kind, stack: string(debug.Stack())}
+	r.leak = record
+	runtime.AddCleanup(owner, reportLeak, record)
+}
+
+func reportLeak(record *leakRecord) {
+	if record.closed.Load() {
+		return
+	}
+	if report := leakReporter.Load(); report != nil {
+		(*report)(Leak{Resource: record.resource, Stack: record.stack})
+	}
+}
@@ -0,0 +1,90 @@
+package sockets
+
+import (
+	"context"
+	"io"
+	"pkg/fake"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Turn on leak detection for the duration of the test.
+func detectLeaks(t *testing.T) <-chan Leak {
+	t.Helper()
+
+	leaks := make(chan Leak, 16)
+	DetectLeaks(func(l Leak) { leaks <- l })
+	t.Cleanup(func() { DetectLeaks(nil) })
+	return leaks
+}
+
+// Run the garbage collector until a leak is reported, or give up after a
+// while.
+func collectLeak(leaks <-chan Leak) (Leak, bool) {
+	for range 50 {
+		runtime.GC()
+		select {
+		case leak := <-leaks:
+			return leak, true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return Leak{}, false
+}
+
+func TestLeakDetection(t *testing.T) {
+	fake.Install(t)
+	leaks := detectLeaks(t)
+
+	func() {
+		if _, err := NewSocket(IpAddressFamilyIpv4); err != nil {
+			t.Fatalf("NewSocket: %v", err)
+		}
+	}()
+
+	leak, ok := collectLeak(leaks)
+	if !ok {
+		t.Fatal("leaked socket was not reported")
+	}
+	if leak.Resource != "tcp-socket" || !strings.Contains(leak.Stack, "TestLeakDetection") {
+		t.Errorf("leak = %q, stack:\n%s", leak.Resource, leak.Stack)
+	}
+
+	func() {
+		conn, err := NewUdpSocket(IpAddressFamilyIpv4)
+		if err != nil {
+			t.Fatalf("NewUdpSocket: %v", err)
+		}
+		conn.Close()
+		conn.Close()
+	}()
+
+	if leak, ok := collectLeak(leaks); ok {
+		t.Errorf("closed socket reported as leaked: %+v", leak)
+	}
+}
+
+func TestListenerCloseDropsAbandonedAccept(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+
+	// Give up on an accept before anyone connects. The connection below is
+	// accepted by the host afterwards, but never handed to the caller.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.AcceptContext(ctx); err == nil {
+		t.Fatal("AcceptContext succeeded with nobody connecting")
+	}
+
+	client := dial(t, l.Addr().String())
+	time.Sleep(10 * time.Millisecond)
+	l.Close()
+	l.Close()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if n, err := client.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read = %d, %v, expected EOF once the listener dropped the connection", n, err)
+	}
+}
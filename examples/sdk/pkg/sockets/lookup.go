@@ -0,0 +1,87 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"pkg/internal/backend"
+)
+
+var lookupErrorMessages = [...]string{
+	backend.LookupErrorCodeAccessDenied:             "access denied",
+	backend.LookupErrorCodeInvalidArgument:          "invalid name",
+	backend.LookupErrorCodeNameUnresolvable:         "no such host",
+	backend.LookupErrorCodeTemporaryResolverFailure: "temporary resolver failure",
+	backend.LookupErrorCodePermanentResolverFailure: "resolver failure",
+	backend.LookupErrorCodeOther:                    "lookup failed",
+}
+
+// Resolve name to its IP addresses using the host's resolver. IP address
+// literals resolve to themselves. Failures are reported as *net.DNSError.
+func LookupHost(ctx context.Context, name string) ([]netip.Addr, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: name, IsTimeout: errors.Is(err, context.DeadlineExceeded)}
+	}
+
+	addrs, err := backend.Current().ResolveAddresses(name)
+	if err == nil {
+		return addrs, nil
+	}
+
+	dnsErr := &net.DNSError{Err: err.Error(), Name: name}
+	var lookupErr *backend.LookupError
+	if errors.As(err, &lookupErr) {
+		if int(lookupErr.Code) < len(lookupErrorMessages) {
+			dnsErr.Err = lookupErrorMessages[lookupErr.Code]
+		}
+		if lookupErr.Message != "" {
+			dnsErr.Err += ": " + lookupErr.Message
+		}
+		dnsErr.IsNotFound = lookupErr.Code == backend.LookupErrorCodeNameUnresolvable
+		dnsErr.IsTemporary = lookupErr.Code == backend.LookupErrorCodeTemporaryResolverFailure
+	}
+	return nil, dnsErr
+}
+
+// Connect to address like Dial, except that the host part may be a name,
+// which is resolved with LookupHost. Each address of the matching family is
+// tried in turn until one connects, and the last error is returned if none
+// does.
+func (d *DialConfig) dialHost(ctx context.Context, network, address string) (*TcpSocket, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, &OpError{Op: "connect", Net: network, Err: err}
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return d.Dial(ctx, network, address)
+	}
+
+	addrs, err := LookupHost(ctx, host)
+	if err != nil {
+		return nil, &OpError{Op: "connect", Net: network, Err: err}
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		switch {
+		case network == "tcp4" && !addr.Unmap().Is4():
+			continue
+		case network == "tcp6" && addr.Is4():
+			continue
+		}
+
+		conn, err := d.Dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if lastErr == nil {
+		lastErr = &OpError{Op: "connect", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+	return nil, lastErr
+}
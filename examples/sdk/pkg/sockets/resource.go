@@ -0,0 +1,68 @@
+package sockets
+
+import "sync"
+
+// Guards a host resource that may be in use by blocking calls running on
+// other goroutines. Dropping a resource while a call still borrows it would
+// trap, so the drop is deferred until the last in-flight call has returned.
+type resource struct {
+	mu     sync.Mutex
+	closed bool
+	users  int
+	done   chan struct{}
+	drop   func()
+	leak   *leakRecord
+}
+
+func newResource(drop func()) *resource {
+	return &resource{
+		done: make(chan struct{}),
+		drop: drop,
+	}
+}
+
+// Register an in-flight call. Returns false if the resource is closed.
+func (r *resource) acquire() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.users++
+	return true
+}
+
+// Mark an in-flight call as finished.
+func (r *resource) release() {
+	r.mu.Lock()
+	r.users--
+	drop := r.closed && r.users == 0
+	r.mu.Unlock()
+
+	if drop {
+		r.drop()
+	}
+}
+
+// Close the resource. Calls that are still blocked will see `done` closed,
+// and the resource is dropped once they have all returned. Returns false if
+// the resource was already closed.
+func (r *resource) close() bool {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return false
+	}
+	r.closed = true
+	close(r.done)
+	if r.leak != nil {
+		r.leak.closed.Store(true)
+	}
+	drop := r.users == 0
+	r.mu.Unlock()
+
+	if drop {
+		r.drop()
+	}
+	return true
+}
@@ -0,0 +1,313 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/netip"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Returned by Server.Serve after a call to Shutdown or Close.
+var ErrServerClosed = errors.New("sockets: server closed")
+
+// Handles a single connection accepted by a Server. The connection is closed
+// once ServeConn returns. ctx is cancelled when the server shuts down, as a
+// signal to finish up.
+type Handler interface {
+	ServeConn(ctx context.Context, conn *TcpSocket)
+}
+
+// Adapts an ordinary function to the Handler interface.
+type HandlerFunc func(ctx context.Context, conn *TcpSocket)
+
+func (f HandlerFunc) ServeConn(ctx context.Context, conn *TcpSocket) {
+	f(ctx, conn)
+}
+
+// Describes a connection once the server has finished with it.
+type ConnStats struct {
+	RemoteAddr netip.AddrPort
+
+	// When the connection was accepted, and how long it was handled for.
+	Accepted time.Time
+	Duration time.Duration
+
+	// Whether the server closed the connection because it was idle.
+	IdleTimeout bool
+
+	// The value the handler panicked with, if it panicked.
+	Panic any
+
+	// The socket's own counters, including the bytes read and written.
+	Socket SocketStats
+}
+
+// A source of inbound TCP connections, such as *Listener or *DualListener.
+type TcpListener interface {
+	AcceptContext(ctx context.Context) (*TcpSocket, error)
+	Close() error
+}
+
+// Serves TCP connections, running each one on its own goroutine. The zero
+// value is not usable; Handler must be set.
+type Server struct {
+	Handler Handler
+
+	// The maximum number of connections handled at once. Once the limit is
+	// reached, the server stops accepting until a connection finishes, so
+	// further connections wait in the listen queue. Zero means no limit.
+	MaxConns int
+
+	// The maximum time a single read may block. Zero means no limit.
+	ReadTimeout time.Duration
+
+	// How long a connection may go without a read or write completing before
+	// the server closes it. Zero means no limit.
+	IdleTimeout time.Duration
+
+	// Called once for every connection after it has been closed. Must be
+	// safe to call from multiple goroutines.
+	ConnMetrics func(ConnStats)
+
+	// Where panics in handlers are reported. If nil, the log package's
+	// standard logger is used.
+	ErrorLog *log.Logger
+
+	mu        sync.Mutex
+	listeners map[TcpListener]struct{}
+	conns     map[*TcpSocket]struct{}
+	active    sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closed    bool
+}
+
+// Accept connections on l and handle each with srv.Handler, until l fails or
+// the server is shut down. The listener is closed when Serve returns. Returns
+// ErrServerClosed after Shutdown or Close, and io.EOF if the host stops
+// delivering connections.
+func (srv *Server) Serve(l TcpListener) error {
+	defer l.Close()
+
+	ctx, ok := srv.track(l)
+	if !ok {
+		return ErrServerClosed
+	}
+	defer srv.untrack(l)
+
+	var limit chan struct{}
+	if srv.MaxConns > 0 {
+		limit = make(chan struct{}, srv.MaxConns)
+	}
+
+	for {
+		if limit != nil {
+			select {
+			case limit <- struct{}{}:
+			case <-ctx.Done():
+				return ErrServerClosed
+			}
+		}
+
+		conn, err := l.AcceptContext(ctx)
+		if err != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		if !srv.add(conn) {
+			conn.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			srv.serveConn(ctx, conn)
+			if limit != nil {
+				<-limit
+			}
+		}()
+	}
+}
+
+func (srv *Server) serveConn(ctx context.Context, conn *TcpSocket) {
+	stats := ConnStats{Accepted: time.Now()}
+	stats.RemoteAddr, _ = conn.GetRemoteAddress()
+
+	defer func() {
+		if p := recover(); p != nil {
+			stats.Panic = p
+			srv.logf("sockets: panic serving %v: %v\n%s", stats.RemoteAddr, p, debug.Stack())
+		}
+
+		conn.Close()
+		stats.Duration = time.Since(stats.Accepted)
+		stats.Socket = conn.Stats()
+		srv.remove(conn)
+		if srv.ConnMetrics != nil {
+			srv.ConnMetrics(stats)
+		}
+	}()
+
+	conn.readTimeout = srv.ReadTimeout
+	if srv.IdleTimeout > 0 {
+		stop := srv.watchIdle(conn, &stats)
+		defer stop()
+	}
+
+	srv.Handler.ServeConn(ctx, conn)
+}
+
+// Close conn once it has been idle for srv.IdleTimeout. Returns a function
+// that stops watching, which must be called before stats is read.
+func (srv *Server) watchIdle(conn *TcpSocket, stats *ConnStats) func() {
+	var mu sync.Mutex
+	var timer *time.Timer
+	stopped := false
+	timer = time.AfterFunc(srv.IdleTimeout, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Stop cannot prevent a call that has already started.
+		if stopped {
+			return
+		}
+		idle := time.Since(time.Unix(0, conn.lastActivity.Load()))
+		if idle < srv.IdleTimeout {
+			timer.Reset(srv.IdleTimeout - idle)
+			return
+		}
+		stats.IdleTimeout = true
+		conn.closeWith(CloseReasonIdleTimeout, nil)
+	})
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		stopped = true
+		timer.Stop()
+	}
+}
+
+// Stop accepting connections, then wait for the connections still being
+// handled to finish. The context passed to handlers is cancelled so that
+// they can wrap up. If ctx is done first, the remaining connections are left
+// running and ctx's error is returned; call Close to force them shut.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.closeListeners()
+
+	done := make(chan struct{})
+	go func() {
+		srv.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop accepting connections and close every connection still being
+// handled.
+func (srv *Server) Close() error {
+	srv.closeListeners()
+
+	srv.mu.Lock()
+	conns := make([]*TcpSocket, 0, len(srv.conns))
+	for conn := range srv.conns {
+		conns = append(conns, conn)
+	}
+	srv.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.closeWith(CloseReasonShutdown, nil)
+	}
+	return nil
+}
+
+func (srv *Server) closeListeners() {
+	srv.mu.Lock()
+	srv.init()
+	srv.closed = true
+	srv.cancel()
+	listeners := make([]TcpListener, 0, len(srv.listeners))
+	for l := range srv.listeners {
+		listeners = append(listeners, l)
+	}
+	srv.mu.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+// The number of connections currently being handled.
+func (srv *Server) ActiveConns() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.conns)
+}
+
+// Must be called with srv.mu held.
+func (srv *Server) init() {
+	if srv.ctx == nil {
+		srv.ctx, srv.cancel = context.WithCancel(context.Background())
+		srv.listeners = make(map[TcpListener]struct{})
+		srv.conns = make(map[*TcpSocket]struct{})
+	}
+}
+
+func (srv *Server) track(l TcpListener) (context.Context, bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.init()
+	if srv.closed {
+		return nil, false
+	}
+	srv.listeners[l] = struct{}{}
+	return srv.ctx, true
+}
+
+func (srv *Server) untrack(l TcpListener) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	delete(srv.listeners, l)
+}
+
+func (srv *Server) add(conn *TcpSocket) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.closed {
+		return false
+	}
+	srv.conns[conn] = struct{}{}
+	srv.active.Add(1)
+	return true
+}
+
+func (srv *Server) remove(conn *TcpSocket) {
+	srv.mu.Lock()
+	delete(srv.conns, conn)
+	srv.mu.Unlock()
+	srv.active.Done()
+}
+
+func (srv *Server) shuttingDown() bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return srv.closed
+}
+
+func (srv *Server) logf(format string, args ...any) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
@@ -0,0 +1,294 @@
+package sockets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"pkg/fake"
+	"testing"
+	"time"
+)
+
+// Start serving on an ephemeral loopback port, returning the listener's
+// address and a channel that receives the result of Serve.
+func startServer(t *testing.T, srv *Server) (string, <-chan error) {
+	t.Helper()
+
+	l, err := (&ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String(), done
+}
+
+func dial(t *testing.T, address string) *TcpSocket {
+	t.Helper()
+
+	conn, err := (&DialConfig{}).Dial(context.Background(), "tcp", address)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServerEcho(t *testing.T) {
+	fake.Install(t)
+
+	stats := make(chan ConnStats, 1)
+	srv := &Server{
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			io.Copy(conn, conn)
+			conn.CloseWrite()
+		}),
+		ConnMetrics: func(s ConnStats) { stats <- s },
+	}
+	addr, _ := startServer(t, srv)
+
+	conn := dial(t, addr)
+	conn.Write([]byte("hello"))
+	conn.CloseWrite()
+
+	got, err := io.ReadAll(conn)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("ReadAll = %q, %v, expected \"hello\"", got, err)
+	}
+
+	s := <-stats
+	if local, _ := conn.GetLocalAddress(); s.RemoteAddr != local {
+		t.Errorf("RemoteAddr = %v, expected %v", s.RemoteAddr, local)
+	}
+	if s.Panic != nil || s.IdleTimeout {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestServerRecoversPanics(t *testing.T) {
+	fake.Install(t)
+
+	var logs bytes.Buffer
+	stats := make(chan ConnStats, 1)
+	srv := &Server{
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			panic("boom")
+		}),
+		ConnMetrics: func(s ConnStats) { stats <- s },
+		ErrorLog:    log.New(&logs, "", 0),
+	}
+	addr, _ := startServer(t, srv)
+
+	dial(t, addr)
+	if s := <-stats; s.Panic != "boom" {
+		t.Errorf("Panic = %v, expected \"boom\"", s.Panic)
+	}
+	if !bytes.Contains(logs.Bytes(), []byte("boom")) {
+		t.Errorf("panic was not logged: %q", logs.String())
+	}
+
+	// The server keeps going after a handler panics.
+	dial(t, addr)
+	<-stats
+}
+
+func TestServerShutdown(t *testing.T) {
+	fake.Install(t)
+
+	started := make(chan struct{})
+	srv := &Server{
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			close(started)
+			<-ctx.Done()
+		}),
+	}
+	addr, done := startServer(t, srv)
+
+	dial(t, addr)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-done; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, expected ErrServerClosed", err)
+	}
+	if n := srv.ActiveConns(); n != 0 {
+		t.Errorf("ActiveConns() = %d after Shutdown", n)
+	}
+}
+
+func TestServerMaxConns(t *testing.T) {
+	fake.Install(t)
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	srv := &Server{
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			started <- struct{}{}
+			<-release
+		}),
+		MaxConns: 1,
+	}
+	addr, _ := startServer(t, srv)
+
+	dial(t, addr)
+	<-started
+	dial(t, addr)
+
+	// The second connection waits in the listen queue.
+	select {
+	case <-started:
+		t.Fatal("second connection was handled while the first was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := srv.ActiveConns(); n != 1 {
+		t.Errorf("ActiveConns() = %d, expected 1", n)
+	}
+
+	release <- struct{}{}
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second connection was not handled after the first finished")
+	}
+	close(release)
+}
+
+func TestServerReadTimeout(t *testing.T) {
+	fake.Install(t)
+
+	type result struct {
+		data string
+		err  error
+	}
+	results := make(chan result, 2)
+	srv := &Server{
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			buf := make([]byte, 16)
+			for range 2 {
+				n, err := conn.Read(buf)
+				results <- result{string(buf[:n]), err}
+			}
+		}),
+		ReadTimeout: 50 * time.Millisecond,
+	}
+	addr, _ := startServer(t, srv)
+
+	conn := dial(t, addr)
+	conn.Write([]byte("hi"))
+	if r := <-results; r.err != nil || r.data != "hi" {
+		t.Errorf("first Read = %q, %v", r.data, r.err)
+	}
+
+	// Nothing else is sent, so the next read gives up.
+	r := <-results
+	if !errors.Is(r.err, os.ErrDeadlineExceeded) {
+		t.Errorf("second Read error = %v, expected os.ErrDeadlineExceeded", r.err)
+	}
+	var netErr net.Error
+	if !errors.As(r.err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a timeout error, got %v", r.err)
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	fake.Install(t)
+
+	readErr := make(chan error, 1)
+	stats := make(chan ConnStats, 1)
+	srv := &Server{
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			buf := make([]byte, 16)
+			for {
+				if _, err := conn.Read(buf); err != nil {
+					readErr <- err
+					return
+				}
+			}
+		}),
+		IdleTimeout: 50 * time.Millisecond,
+		ConnMetrics: func(s ConnStats) { stats <- s },
+	}
+	addr, _ := startServer(t, srv)
+
+	// Activity keeps the connection open past the idle timeout.
+	conn := dial(t, addr)
+	start := time.Now()
+	for range 5 {
+		conn.Write([]byte("x"))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := <-readErr; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read error = %v, expected net.ErrClosed", err)
+	}
+	s := <-stats
+	if !s.IdleTimeout {
+		t.Errorf("IdleTimeout = false")
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("connection closed after %v despite activity", elapsed)
+	}
+	if s.Socket.CloseReason != CloseReasonIdleTimeout {
+		t.Errorf("CloseReason = %v", s.Socket.CloseReason)
+	}
+}
+
+func TestServerShutdownTimeout(t *testing.T) {
+	fake.Install(t)
+
+	started := make(chan struct{})
+	finished := make(chan error, 1)
+	srv := &Server{
+		// Ignores ctx, so only Close can end it.
+		Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {
+			close(started)
+			_, err := conn.Read(make([]byte, 1))
+			finished <- err
+		}),
+	}
+	addr, _ := startServer(t, srv)
+
+	dial(t, addr)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, expected context.DeadlineExceeded", err)
+	}
+	if n := srv.ActiveConns(); n != 1 {
+		t.Errorf("ActiveConns() = %d after Shutdown timed out, expected 1", n)
+	}
+
+	srv.Close()
+	if err := <-finished; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("handler Read = %v after Close, expected net.ErrClosed", err)
+	}
+}
+
+func TestServeAfterShutdown(t *testing.T) {
+	fake.Install(t)
+
+	srv := &Server{Handler: HandlerFunc(func(ctx context.Context, conn *TcpSocket) {})}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	l := listenLoopback(t)
+	if err := srv.Serve(l); !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve = %v, expected ErrServerClosed", err)
+	}
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept = %v, expected Serve to have closed the listener", err)
+	}
+}
@@ -1,15 +1,15 @@
 package sockets
 
 import (
-	"encoding/binary"
-	"fmt"
+	"bytes"
+	"context"
 	"io"
+	"net"
 	"net/netip"
-	wasiSockets "pkg/bindings/imports/wasi_sockets_types"
-	"strconv"
+	"pkg/internal/backend"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	witTypes "go.bytecodealliance.org/pkg/wit/types"
 )
 
 type IpAddressFamily uint8
@@ -20,33 +20,231 @@ const (
 	IpAddressFamilyIpv6
 )
 
+// A TCP socket. As with net.Conn, methods may be called from several
+// goroutines at once: a read and a write can run concurrently, and further
+// concurrent reads, or writes, take turns. Close may be called at any time,
+// and unblocks every pending call.
 type TcpSocket struct {
-	inner wasiSockets.TcpSocket
-	rx    *witTypes.StreamReader[uint8]
-	tx    *witTypes.StreamWriter[uint8]
+	inner backend.TcpSocket
+	res   *resource
+
+	// The byte streams of a connected socket.
+	rx       backend.InputStream
+	tx       backend.OutputStream
+	txClosed bool
+
+	// Reads and writes run on their own goroutine so that they can be
+	// abandoned when a deadline passes, the context is cancelled, or the
+	// socket is closed. An abandoned read is picked up by the next read, and
+	// an abandoned write is waited for by the next write, so no data is lost
+	// or reordered. Concurrent reads, and concurrent writes, take turns.
+	readTurn     turn
+	pendingRead  chan readResult
+	readBuf      []byte
+	unread       []byte
+	writeTurn    turn
+	pendingWrite chan writeResult
+
+	readDeadline  deadline
+	writeDeadline deadline
+
+	// Set by Server. Bounds how long each individual read may block, in
+	// addition to any read deadline.
+	readTimeout time.Duration
+
+	// When a read or write last completed, in nanoseconds since the Unix
+	// epoch. Used by Server to detect idle connections.
+	lastActivity atomic.Int64
+
+	// Reported by Stats. The byte counters are updated as reads and writes
+	// complete; everything else is guarded by statsMu.
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+	statsMu      sync.Mutex
+	tracer       Tracer
+	created      time.Time
+	connected    time.Time
+	closed       time.Time
+	accepted     bool
+
+	// What ended the connection before it was closed, if anything, and why
+	// it was closed.
+	endReason   CloseReason
+	endErr      error
+	closeReason CloseReason
+	closeErr    error
+}
+
+var _ net.Conn = (*TcpSocket)(nil)
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+type writeResult struct {
+	n   int
+	err error
 }
 
 // Create a new TCP socket.
-func NewSocket(af IpAddressFamily) (TcpSocket, error) {
-	result := wasiSockets.TcpSocketCreate(toWasiIpAddressFamily(af))
-	if result.IsErr() {
-		return TcpSocket{}, fmt.Errorf("Error creating TCP socket: %w", fromWitErrorCode(result.Err()))
+func NewSocket(af IpAddressFamily) (*TcpSocket, error) {
+	family, err := toHostAddressFamily(af)
+	if err != nil {
+		return nil, &OpError{Op: "create", Net: "tcp", Err: err}
+	}
+	inner, err := backend.Current().CreateTcpSocket(family)
+	if err != nil {
+		return nil, newOpError("tcp", "create", netip.AddrPort{}, err)
+	}
+
+	return newTcpSocket(inner), nil
+}
+
+func newTcpSocket(inner backend.TcpSocket) *TcpSocket {
+	s := &TcpSocket{
+		inner:         inner,
+		readTurn:      newTurn(),
+		writeTurn:     newTurn(),
+		readDeadline:  makeDeadline(),
+		writeDeadline: makeDeadline(),
+		tracer:        NopTracer{},
+		created:       time.Now(),
+	}
+	s.res = newResource(s.dropHandles)
+	trackLeaks(s, s.res, "tcp-socket")
+	s.touch()
+	return s
+}
+
+// Record that the connection has just been used.
+func (s *TcpSocket) touch() {
+	s.lastActivity.Store(time.Now().UnixNano())
+}
+
+// Release the streams and the socket itself.
+func (s *TcpSocket) dropHandles() {
+	if s.rx != nil {
+		s.rx.Drop()
+	}
+	if s.tx != nil && !s.txClosed {
+		s.tx.Drop()
+	}
+	s.inner.Drop()
+}
+
+// Close the socket. Blocked reads, writes and connects return net.ErrClosed.
+// The underlying handles are released exactly once, after every host call
+// that is still in flight has returned. Closing again has no effect.
+func (s *TcpSocket) Close() error {
+	s.closeWith(CloseReasonLocal, nil)
+	return nil
+}
+
+// Close the socket, recording reason unless something had already ended the
+// connection. Reports the close to the tracer the first time only.
+func (s *TcpSocket) closeWith(reason CloseReason, err error) {
+	if !s.res.close() {
+		return
 	}
 
-	return TcpSocket{
-		inner: *result.Ok(),
-	}, nil
+	s.statsMu.Lock()
+	s.closed = time.Now()
+	s.closeReason, s.closeErr = reason, err
+	if s.endReason != CloseReasonNone {
+		s.closeReason, s.closeErr = s.endReason, s.endErr
+	}
+	tracer := s.tracer
+	s.statsMu.Unlock()
+
+	tracer.Close(s, s.Stats())
+}
+
+// Record what ended the connection, unless something already has.
+func (s *TcpSocket) ended(reason CloseReason, err error) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.endReason == CloseReasonNone {
+		s.endReason, s.endErr = reason, err
+	}
+}
+
+// Record the outcome of reading from or writing to a stream: EOF means the
+// peer finished, and any other error means the connection failed.
+func (s *TcpSocket) streamEnded(err error) {
+	switch {
+	case err == io.EOF:
+		s.ended(CloseReasonPeer, nil)
+	case err != nil:
+		s.ended(CloseReasonError, err)
+	}
+}
+
+// Record that the socket has just been connected or accepted.
+func (s *TcpSocket) markConnected(accepted bool) {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	s.connected = time.Now()
+	s.accepted = accepted
+}
+
+// Set the Tracer that receives this socket's events, replacing any tracer
+// set before. A nil t disables tracing.
+func (s *TcpSocket) SetTracer(t Tracer) {
+	if t == nil {
+		t = NopTracer{}
+	}
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	s.tracer = t
+}
+
+func (s *TcpSocket) trace() Tracer {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return s.tracer
+}
+
+// Take a snapshot of the socket's counters and timestamps. It remains
+// available after the socket is closed.
+func (s *TcpSocket) Stats() SocketStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	return SocketStats{
+		BytesRead:    s.bytesRead.Load(),
+		BytesWritten: s.bytesWritten.Load(),
+		Created:      s.created,
+		Connected:    s.connected,
+		Closed:       s.closed,
+		Accepted:     s.accepted,
+		CloseReason:  s.closeReason,
+		CloseErr:     s.closeErr,
+	}
 }
 
 // Bind the socket to the provided IP address and port
 func (s *TcpSocket) Bind(address string) error {
-	socketAddr, err := toWasiIpSockAddr(address)
+	ip, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &OpError{Op: "bind", Net: "tcp", Err: err}
+	}
+	return s.BindSocketAddress(SocketAddress{AddrPort: ip})
+}
+
+// Bind the socket to the provided socket address, including its IPv6 flow
+// information.
+func (s *TcpSocket) BindSocketAddress(addr SocketAddress) error {
+	socketAddr, err := toHostSockAddr(addr)
 	if err != nil {
-		return err
+		return &OpError{Op: "bind", Net: "tcp", Addr: addr.AddrPort, Err: err}
 	}
-	result := s.inner.Bind(socketAddr)
-	if result.IsErr() {
-		return fmt.Errorf("failed to bind to socket: %w", fromWitErrorCode(result.Err()))
+	if !s.res.acquire() {
+		return closedError("tcp", "bind")
+	}
+	defer s.res.release()
+
+	if err := s.inner.Bind(socketAddr); err != nil {
+		return newOpError("tcp", "bind", addr.AddrPort, err)
 	}
 
 	return nil
@@ -54,130 +252,645 @@ func (s *TcpSocket) Bind(address string) error {
 
 // Connect to a remote endpoint.
 func (s *TcpSocket) Connect(address string) error {
-	socketAddr, err := toWasiIpSockAddr(address)
+	return s.ConnectContext(context.Background(), address)
+}
+
+// Connect to a remote endpoint, giving up once ctx is done. The host call
+// itself cannot be interrupted, so a cancelled connect closes the socket, and
+// its handles are released as soon as the host call completes.
+func (s *TcpSocket) ConnectContext(ctx context.Context, address string) error {
+	ip, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &OpError{Op: "connect", Net: "tcp", Err: err}
+	}
+	return s.ConnectSocketAddress(ctx, SocketAddress{AddrPort: ip})
+}
+
+// Connect to the provided socket address, including its IPv6 flow
+// information, giving up once ctx is done like ConnectContext.
+func (s *TcpSocket) ConnectSocketAddress(ctx context.Context, addr SocketAddress) error {
+	ip := addr.AddrPort
+	socketAddr, err := toHostSockAddr(addr)
 	if err != nil {
-		return err
+		return &OpError{Op: "connect", Net: "tcp", Addr: ip, Err: err}
 	}
-	result := s.inner.Connect(socketAddr)
-	if result.IsErr() {
-		return fmt.Errorf("failed to connect to socket: %w", fromWitErrorCode(result.Err()))
+
+	tracer := s.trace()
+	tracer.ConnectStart(s, ip)
+	abandoned, err := s.connect(ctx, ip, socketAddr)
+	tracer.ConnectDone(s, ip, err)
+	if abandoned {
+		s.closeWith(CloseReasonCanceled, ctx.Err())
 	}
-	return nil
+	return err
 }
 
+// Connect, reporting whether the connect was abandoned because ctx is done,
+// in which case the socket must be closed.
+func (s *TcpSocket) connect(ctx context.Context, ip netip.AddrPort, socketAddr backend.IpSocketAddress) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, &OpError{Op: "connect", Net: "tcp", Addr: ip, Err: err}
+	}
+	if !s.res.acquire() {
+		return false, closedError("tcp", "connect")
+	}
+
+	connect := func() error {
+		defer s.res.release()
+
+		if err := s.inner.Connect(socketAddr); err != nil {
+			err := newOpError("tcp", "connect", ip, err)
+			s.ended(CloseReasonError, err)
+			return err
+		}
+
+		s.startStreams()
+		s.markConnected(false)
+		return nil
+	}
+
+	// A context that can never be cancelled needs no extra goroutine.
+	if ctx.Done() == nil {
+		return false, connect()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- connect()
+	}()
+
+	select {
+	case err := <-done:
+		return false, err
+	case <-ctx.Done():
+		return true, &OpError{Op: "connect", Net: "tcp", Addr: ip, Err: ctx.Err()}
+	case <-s.res.done:
+		return false, closedError("tcp", "connect")
+	}
+}
+
+// Open the send and receive streams of a connected socket.
+func (s *TcpSocket) startStreams() {
+	s.readTurn.lock()
+	s.rx = s.inner.Receive()
+	s.readTurn.give()
+
+	s.writeTurn.lock()
+	s.tx = s.inner.Send()
+	s.writeTurn.give()
+}
+
+// Inbound connections on a listening TCP socket. Accept may be called from
+// several goroutines at once.
 type Listener struct {
-	inner *witTypes.StreamReader[*wasiSockets.TcpSocket]
+	inner backend.TcpAcceptor
+	res   *resource
+
+	// The listening socket, when the listener owns it and closes it along
+	// with the stream.
+	socket *TcpSocket
+	addr   netip.AddrPort
+
+	// As with reads, an accept abandoned because of a deadline or a
+	// cancelled context is picked up by the next call. Concurrent accepts
+	// take turns.
+	turn     turn
+	pending  chan acceptResult
+	deadline deadline
+
+	// Inherited by accepted sockets.
+	tracer Tracer
 }
 
+type acceptResult struct {
+	inner backend.TcpSocket
+	err   error
+}
+
+// Wait for the next inbound connection. Returns io.EOF once the host stops
+// delivering connections.
 func (l *Listener) Accept() (*TcpSocket, error) {
-	if l.inner.WriterDropped() {
-		return nil, io.EOF
+	return l.AcceptContext(context.Background())
+}
+
+// Wait for the next inbound connection, giving up once ctx is done. Accept
+// may be called from several goroutines at once; each connection is handed
+// to exactly one of them.
+func (l *Listener) AcceptContext(ctx context.Context) (*TcpSocket, error) {
+	if !l.turn.take(ctx, &l.deadline, l.res) {
+		return nil, turnError("tcp", "accept", ctx, &l.deadline, l.res)
 	}
-	buf := make([]*wasiSockets.TcpSocket, 1)
-	count := l.inner.Read(buf)
-	if count == 0 {
-		return nil, io.EOF
+	defer l.turn.give()
+
+	if l.pending == nil {
+		if isClosedChan(l.deadline.wait()) {
+			return nil, timeoutError("tcp", "accept")
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, &OpError{Op: "accept", Net: "tcp", Err: err}
+		}
+		if !l.res.acquire() {
+			return nil, closedError("tcp", "accept")
+		}
+
+		pending := make(chan acceptResult, 1)
+		l.pending = pending
+		go func() {
+			defer l.res.release()
+			inner, err := l.inner.Accept()
+			pending <- acceptResult{inner, err}
+		}()
+	}
+
+	select {
+	case result := <-l.pending:
+		l.pending = nil
+		if result.err == io.EOF {
+			return nil, io.EOF
+		}
+		if result.err != nil {
+			return nil, newOpError("tcp", "accept", netip.AddrPort{}, result.err)
+		}
+
+		sock := newTcpSocket(result.inner)
+		sock.startStreams()
+		sock.markConnected(true)
+		sock.SetTracer(l.tracer)
+		l.tracer.Accept(sock)
+		return sock, nil
+	case <-l.deadline.wait():
+		return nil, timeoutError("tcp", "accept")
+	case <-ctx.Done():
+		return nil, &OpError{Op: "accept", Net: "tcp", Err: ctx.Err()}
+	case <-l.res.done:
+		return nil, closedError("tcp", "accept")
 	}
+}
+
+// The listener as a net.Listener, for use with packages such as net/http and
+// crypto/tls. Accept returns *TcpSocket connections.
+func (l *Listener) NetListener() net.Listener {
+	return netListener{l}
+}
 
-	sock := &TcpSocket{inner: *buf[0]}
+type netListener struct {
+	*Listener
+}
 
-	rx, _ := sock.inner.Receive()
-	sock.rx = rx
+func (l netListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
 
-	tx, txReader := wasiSockets.MakeStreamU8()
-	sock.inner.Send(txReader)
-	sock.tx = tx
+// Returns the local address the listener is bound to, as a *net.TCPAddr.
+func (l *Listener) Addr() net.Addr {
+	return net.TCPAddrFromAddrPort(l.addr)
+}
 
-	return sock, nil
+// Set the deadline for pending and future accepts. A zero value disables it.
+func (l *Listener) SetDeadline(t time.Time) error {
+	l.deadline.set(t)
+	return nil
 }
 
-func (l *Listener) Close() error {
+// Release the listener, along with a connection that was accepted by an
+// abandoned Accept call but never handed out.
+func (l *Listener) dropHandles() {
+	l.turn.lock()
+	if l.pending != nil {
+		select {
+		case result := <-l.pending:
+			l.pending = nil
+			if result.err == nil {
+				result.inner.Drop()
+			}
+		default:
+		}
+	}
+	l.turn.give()
 	l.inner.Drop()
+}
+
+// Stop listening. Blocked accepts return net.ErrClosed.
+func (l *Listener) Close() error {
+	l.res.close()
+	if l.socket != nil {
+		l.socket.Close()
+	}
 	return nil
 }
 
 // Start listening and return a stream of new inbound connections.
 func (s *TcpSocket) Listen() (*Listener, error) {
-	result := s.inner.Listen()
-	if result.IsErr() {
-		return nil, fmt.Errorf("failed to listen to socket: %w", fromWitErrorCode(result.Err()))
+	if !s.res.acquire() {
+		return nil, closedError("tcp", "listen")
+	}
+	defer s.res.release()
+
+	inner, err := s.inner.Listen()
+	if err != nil {
+		return nil, newOpError("tcp", "listen", netip.AddrPort{}, err)
 	}
 
-	return &Listener{
-		inner: result.Ok(),
-	}, nil
+	l := &Listener{
+		inner:    inner,
+		turn:     newTurn(),
+		deadline: makeDeadline(),
+		tracer:   s.trace(),
+	}
+	l.res = newResource(l.dropHandles)
+	trackLeaks(l, l.res, "tcp-listener")
+	if addr, err := s.inner.GetLocalAddress(); err == nil {
+		l.addr = fromHostSockAddr(addr).AddrPort
+	}
+	return l, nil
 }
 
-// Write data to TCP stream
+// Write data to TCP stream. Blocks until the host has accepted all of b. If
+// the connection fails part way through, the number of bytes accepted so far
+// is returned along with the error reported by the host.
 func (s *TcpSocket) Write(b []byte) (int, error) {
-	s.tx.WriteAll(b)
-	return len(b), nil
+	return s.WriteContext(context.Background(), b)
+}
+
+// Write data to TCP stream, giving up once ctx is done or the write deadline
+// passes. Data that was handed to the host before giving up may still be
+// sent.
+func (s *TcpSocket) WriteContext(ctx context.Context, b []byte) (int, error) {
+	n, err := s.writeContext(ctx, b)
+	if err != nil {
+		s.trace().WriteError(s, err)
+	}
+	return n, err
+}
+
+func (s *TcpSocket) writeContext(ctx context.Context, b []byte) (int, error) {
+	if !s.writeTurn.take(ctx, &s.writeDeadline, s.res) {
+		return 0, turnError("tcp", "write", ctx, &s.writeDeadline, s.res)
+	}
+	defer s.writeTurn.give()
+
+	if !s.res.acquire() {
+		return 0, closedError("tcp", "write")
+	}
+	if s.tx == nil {
+		s.res.release()
+		return 0, &OpError{Op: "write", Net: "tcp", Err: ErrInvalidState}
+	}
+	if s.txClosed {
+		s.res.release()
+		return 0, closedError("tcp", "write")
+	}
+
+	// Let an earlier write that was abandoned finish first.
+	if s.pendingWrite != nil {
+		select {
+		case result := <-s.pendingWrite:
+			s.pendingWrite = nil
+			s.streamEnded(result.err)
+			if result.err != nil {
+				s.res.release()
+				return 0, result.err
+			}
+		case <-s.writeDeadline.wait():
+			s.res.release()
+			return 0, timeoutError("tcp", "write")
+		case <-ctx.Done():
+			s.res.release()
+			return 0, &OpError{Op: "write", Net: "tcp", Err: ctx.Err()}
+		case <-s.res.done:
+			s.res.release()
+			return 0, closedError("tcp", "write")
+		}
+	}
+	if isClosedChan(s.writeDeadline.wait()) {
+		s.res.release()
+		return 0, timeoutError("tcp", "write")
+	}
+	if err := ctx.Err(); err != nil {
+		s.res.release()
+		return 0, &OpError{Op: "write", Net: "tcp", Err: err}
+	}
+
+	// The write may outlive this call, so it gets its own copy of the data.
+	data := bytes.Clone(b)
+	pending := make(chan writeResult, 1)
+	s.pendingWrite = pending
+	go func() {
+		defer s.res.release()
+		n, err := s.writeStream(data)
+		s.bytesWritten.Add(uint64(n))
+		pending <- writeResult{n, err}
+	}()
+
+	select {
+	case result := <-pending:
+		s.pendingWrite = nil
+		s.streamEnded(result.err)
+		s.touch()
+		return result.n, result.err
+	case <-s.writeDeadline.wait():
+		return 0, timeoutError("tcp", "write")
+	case <-ctx.Done():
+		return 0, &OpError{Op: "write", Net: "tcp", Err: ctx.Err()}
+	case <-s.res.done:
+		return 0, closedError("tcp", "write")
+	}
+}
+
+// Write all of b to the send stream, blocking while the host applies
+// backpressure.
+func (s *TcpSocket) writeStream(b []byte) (int, error) {
+	n, err := s.tx.Write(b)
+	if err != nil {
+		return n, newOpError("tcp", "write", netip.AddrPort{}, err)
+	}
+	return n, nil
 }
 
-// Read data from TCP stream
+// Read data from TCP stream. Returns io.EOF once the peer has shut down its
+// side of the connection, or the error reported by the host if the
+// connection failed.
 func (s *TcpSocket) Read(b []byte) (int, error) {
-	if s.rx.WriterDropped() {
-		return 0, io.EOF
+	return s.ReadContext(context.Background(), b)
+}
+
+// Read data from TCP stream, giving up once ctx is done or the read deadline
+// passes.
+func (s *TcpSocket) ReadContext(ctx context.Context, b []byte) (int, error) {
+	n, err := s.readContext(ctx, b)
+	s.bytesRead.Add(uint64(n))
+	if err != nil && err != io.EOF {
+		s.trace().ReadError(s, err)
+	}
+	return n, err
+}
+
+func (s *TcpSocket) readContext(ctx context.Context, b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if !s.readTurn.take(ctx, &s.readDeadline, s.res) {
+		return 0, turnError("tcp", "read", ctx, &s.readDeadline, s.res)
+	}
+	defer s.readTurn.give()
+
+	if len(s.unread) > 0 {
+		n := copy(b, s.unread)
+		s.unread = s.unread[n:]
+		return n, nil
+	}
+
+	if s.pendingRead == nil {
+		if isClosedChan(s.readDeadline.wait()) {
+			return 0, timeoutError("tcp", "read")
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, &OpError{Op: "read", Net: "tcp", Err: err}
+		}
+		if !s.res.acquire() {
+			return 0, closedError("tcp", "read")
+		}
+		if s.rx == nil {
+			s.res.release()
+			return 0, &OpError{Op: "read", Net: "tcp", Err: ErrInvalidState}
+		}
+
+		// The read fills an internal buffer, since it may complete after
+		// this call has returned.
+		if cap(s.readBuf) < len(b) {
+			s.readBuf = make([]byte, len(b))
+		}
+		buf := s.readBuf[:len(b)]
+		pending := make(chan readResult, 1)
+		s.pendingRead = pending
+		go func() {
+			defer s.res.release()
+			n, err := s.readStream(buf)
+			pending <- readResult{buf[:n], err}
+		}()
+	}
+
+	var timeout <-chan time.Time
+	if s.readTimeout > 0 {
+		timer := time.NewTimer(s.readTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case result := <-s.pendingRead:
+		s.pendingRead = nil
+		n := copy(b, result.data)
+		s.unread = result.data[n:]
+		s.streamEnded(result.err)
+		s.touch()
+		return n, result.err
+	case <-s.readDeadline.wait():
+		return 0, timeoutError("tcp", "read")
+	case <-timeout:
+		return 0, timeoutError("tcp", "read")
+	case <-ctx.Done():
+		return 0, &OpError{Op: "read", Net: "tcp", Err: ctx.Err()}
+	case <-s.res.done:
+		return 0, closedError("tcp", "read")
+	}
+}
+
+// Read from the receive stream, blocking until data arrives or the stream
+// has ended.
+func (s *TcpSocket) readStream(b []byte) (int, error) {
+	n, err := s.rx.Read(b)
+	if err != nil && err != io.EOF {
+		return n, newOpError("tcp", "read", netip.AddrPort{}, err)
+	}
+	return n, err
+}
+
+// Shut down the sending side of the connection, so that the peer reads EOF
+// once all previously written data has been delivered. Blocks until the host
+// has finished sending, and returns any error it reported.
+func (s *TcpSocket) CloseWrite() error {
+	ctx := context.Background()
+	if !s.writeTurn.take(ctx, nil, s.res) {
+		return turnError("tcp", "close-write", ctx, nil, s.res)
+	}
+	defer s.writeTurn.give()
+
+	if !s.res.acquire() {
+		return closedError("tcp", "close-write")
+	}
+	defer s.res.release()
+
+	if s.tx == nil {
+		return &OpError{Op: "close-write", Net: "tcp", Err: ErrInvalidState}
+	}
+	if s.txClosed {
+		return nil
+	}
+	if s.pendingWrite != nil {
+		select {
+		case <-s.pendingWrite:
+			s.pendingWrite = nil
+		case <-s.res.done:
+			return closedError("tcp", "close-write")
+		}
+	}
+	s.txClosed = true
+
+	if err := s.tx.Close(); err != nil {
+		return newOpError("tcp", "close-write", netip.AddrPort{}, err)
+	}
+	return nil
+}
+
+// Set both the read and write deadlines.
+func (s *TcpSocket) SetDeadline(t time.Time) error {
+	if !s.res.acquire() {
+		return closedError("tcp", "set-deadline")
+	}
+	defer s.res.release()
+
+	s.readDeadline.set(t)
+	s.writeDeadline.set(t)
+	return nil
+}
+
+// Set the deadline for pending and future reads. A zero value disables it.
+func (s *TcpSocket) SetReadDeadline(t time.Time) error {
+	if !s.res.acquire() {
+		return closedError("tcp", "set-read-deadline")
+	}
+	defer s.res.release()
+
+	s.readDeadline.set(t)
+	return nil
+}
+
+// Set the deadline for pending and future writes. A zero value disables it.
+func (s *TcpSocket) SetWriteDeadline(t time.Time) error {
+	if !s.res.acquire() {
+		return closedError("tcp", "set-write-deadline")
+	}
+	defer s.res.release()
+
+	s.writeDeadline.set(t)
+	return nil
+}
+
+// Returns the local address as a *net.TCPAddr, or nil if the socket is not
+// bound.
+func (s *TcpSocket) LocalAddr() net.Addr {
+	addr, err := s.GetLocalAddress()
+	if err != nil {
+		return nil
 	}
-	n := s.rx.Read(b)
-	return int(n), nil
+	return net.TCPAddrFromAddrPort(addr)
+}
+
+// Returns the remote address as a *net.TCPAddr, or nil if the socket is not
+// connected.
+func (s *TcpSocket) RemoteAddr() net.Addr {
+	addr, err := s.GetRemoteAddress()
+	if err != nil {
+		return nil
+	}
+	return net.TCPAddrFromAddrPort(addr)
 }
 
 // Get the bound local address.
 func (s *TcpSocket) GetLocalAddress() (netip.AddrPort, error) {
-	result := s.inner.GetLocalAddress()
-	if result.IsErr() {
-		return netip.AddrPort{}, fmt.Errorf("failed to get local address%w", fromWitErrorCode(result.Err()))
+	addr, err := s.GetLocalSocketAddress()
+	return addr.AddrPort, err
+}
+
+// Get the bound local address, including its IPv6 flow information.
+func (s *TcpSocket) GetLocalSocketAddress() (SocketAddress, error) {
+	if !s.res.acquire() {
+		return SocketAddress{}, closedError("tcp", "get-local-address")
+	}
+	defer s.res.release()
+
+	addr, err := s.inner.GetLocalAddress()
+	if err != nil {
+		return SocketAddress{}, newOpError("tcp", "get-local-address", netip.AddrPort{}, err)
 	}
 
-	return fromWasiIpSocketAddr(result.Ok()), nil
+	return fromHostSockAddr(addr), nil
 }
 
 // Get the remote address.
 func (s *TcpSocket) GetRemoteAddress() (netip.AddrPort, error) {
-	result := s.inner.GetRemoteAddress()
-	if result.IsErr() {
-		return netip.AddrPort{}, fmt.Errorf("failed to get local address%w", fromWitErrorCode(result.Err()))
+	addr, err := s.GetRemoteSocketAddress()
+	return addr.AddrPort, err
+}
+
+// Get the remote address, including its IPv6 flow information.
+func (s *TcpSocket) GetRemoteSocketAddress() (SocketAddress, error) {
+	if !s.res.acquire() {
+		return SocketAddress{}, closedError("tcp", "get-remote-address")
+	}
+	defer s.res.release()
+
+	addr, err := s.inner.GetRemoteAddress()
+	if err != nil {
+		return SocketAddress{}, newOpError("tcp", "get-remote-address", netip.AddrPort{}, err)
 	}
 
-	return fromWasiIpSocketAddr(result.Ok()), nil
+	return fromHostSockAddr(addr), nil
 }
 
-// Whether this is a IPv4 or IPv6 socket.
+// Whether this is a IPv4 or IPv6 socket. Returns 0 once the socket is closed,
+// or if the host reports a family the SDK does not know about.
 func (s *TcpSocket) GetAddressFamily() IpAddressFamily {
-	switch s.inner.GetAddressFamily() {
-	case wasiSockets.IpAddressFamilyIpv4:
-		return IpAddressFamilyIpv4
-	case wasiSockets.IpAddressFamilyIpv6:
-		return IpAddressFamilyIpv6
-	default:
-		panic("GetAddressFamily has retrieved a 3rd, heretofore unknown IpAddressFamily type")
+	if !s.res.acquire() {
+		return 0
 	}
+	defer s.res.release()
+
+	return fromHostAddressFamily(s.inner.GetAddressFamily())
 }
 
 // Hints the desired listen queue size. Host implementations might ignore this.
 func (s *TcpSocket) SetListenBacklogSize(size uint64) error {
-	err := s.inner.SetListenBacklogSize(size)
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-listen-backlog-size")
+	}
+	defer s.res.release()
+
+	if err := s.inner.SetListenBacklogSize(size); err != nil {
+		return newOpError("tcp", "set-listen-backlog-size", netip.AddrPort{}, err)
 	}
 	return nil
 }
 
 // Indicates whether keepalive is enabled or disabled.
 func (s *TcpSocket) GetKeepAliveEnabled() (bool, error) {
-	result := s.inner.GetKeepAliveEnabled()
-	if result.IsErr() {
-		return false, fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return false, closedError("tcp", "get-keep-alive-enabled")
+	}
+	defer s.res.release()
+
+	v, err := s.inner.GetKeepAliveEnabled()
+	if err != nil {
+		return false, newOpError("tcp", "get-keep-alive-enabled", netip.AddrPort{}, err)
 	}
-	return result.Ok(), nil
+	return v, nil
 }
 
 // Enables or disables keepalive.
 func (s *TcpSocket) SetKeepAliveEnabled(v bool) error {
-	result := s.inner.SetKeepAliveEnabled(v)
-	if result.IsErr() {
-		return fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-keep-alive-enabled")
+	}
+	defer s.res.release()
+
+	if err := s.inner.SetKeepAliveEnabled(v); err != nil {
+		return newOpError("tcp", "set-keep-alive-enabled", netip.AddrPort{}, err)
 	}
 	return nil
 }
@@ -185,43 +898,61 @@ func (s *TcpSocket) SetKeepAliveEnabled(v bool) error {
 // Amount of time the connection has been set to be idle before TCP starts
 // sending keepalive packets.
 func (s *TcpSocket) GetKeepAliveIdleTime() (time.Duration, error) {
-	result := s.inner.GetKeepAliveIdleTime()
-	if result.IsErr() {
-		return time.Duration(-1), fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return time.Duration(-1), closedError("tcp", "get-keep-alive-idle-time")
+	}
+	defer s.res.release()
+
+	v, err := s.inner.GetKeepAliveIdleTime()
+	if err != nil {
+		return time.Duration(-1), newOpError("tcp", "get-keep-alive-idle-time", netip.AddrPort{}, err)
 	}
-	return time.Duration(result.Ok()), nil
+	return v, nil
 }
 
 // Amount of time the connection has to be idle before TCP starts
 // sending keepalive packets.
 func (s *TcpSocket) SetKeepAliveIdleTime(duration time.Duration) error {
 	if duration < 0 {
-		return fmt.Errorf("duration must be >= 0")
+		return &OpError{Op: "set-keep-alive-idle-time", Net: "tcp", Err: ErrInvalidArgument, Detail: "duration must be >= 0"}
+	}
+	if !s.res.acquire() {
+		return closedError("tcp", "set-keep-alive-idle-time")
 	}
-	err := s.inner.SetKeepAliveIdleTime(uint64(duration))
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	defer s.res.release()
+
+	if err := s.inner.SetKeepAliveIdleTime(duration); err != nil {
+		return newOpError("tcp", "set-keep-alive-idle-time", netip.AddrPort{}, err)
 	}
 	return nil
 }
 
 // The time between keepalive packets.
 func (s *TcpSocket) GetKeepAliveInterval() (time.Duration, error) {
-	result := s.inner.GetKeepAliveInterval()
-	if result.IsErr() {
-		return time.Duration(-1), fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return time.Duration(-1), closedError("tcp", "get-keep-alive-interval")
+	}
+	defer s.res.release()
+
+	v, err := s.inner.GetKeepAliveInterval()
+	if err != nil {
+		return time.Duration(-1), newOpError("tcp", "get-keep-alive-interval", netip.AddrPort{}, err)
 	}
-	return time.Duration(result.Ok()), nil
+	return v, nil
 }
 
 // The time between keepalive packets.
 func (s *TcpSocket) SetKeepAliveInterval(duration time.Duration) error {
 	if duration < 0 {
-		return fmt.Errorf("duration must be >= 0")
+		return &OpError{Op: "set-keep-alive-interval", Net: "tcp", Err: ErrInvalidArgument, Detail: "duration must be >= 0"}
 	}
-	err := s.inner.SetKeepAliveInterval(uint64(duration))
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-keep-alive-interval")
+	}
+	defer s.res.release()
+
+	if err := s.inner.SetKeepAliveInterval(duration); err != nil {
+		return newOpError("tcp", "set-keep-alive-interval", netip.AddrPort{}, err)
 	}
 	return nil
 }
@@ -229,200 +960,109 @@ func (s *TcpSocket) SetKeepAliveInterval(duration time.Duration) error {
 // The maximum amount of keepalive packets TCP should send before
 // aborting the connection.
 func (s *TcpSocket) GetKeepAliveCount() (uint32, error) {
-	result := s.inner.GetKeepAliveCount()
-	if result.IsErr() {
-		return 0, fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return 0, closedError("tcp", "get-keep-alive-count")
 	}
-	return result.Ok(), nil
+	defer s.res.release()
+
+	v, err := s.inner.GetKeepAliveCount()
+	if err != nil {
+		return 0, newOpError("tcp", "get-keep-alive-count", netip.AddrPort{}, err)
+	}
+	return v, nil
 }
 
 // The maximum amount of keepalive packets TCP should send before
 // aborting the connection.
 func (s *TcpSocket) SetKeepAliveCount(v uint32) error {
-	err := s.inner.SetKeepAliveCount(v)
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-keep-alive-count")
+	}
+	defer s.res.release()
+
+	if err := s.inner.SetKeepAliveCount(v); err != nil {
+		return newOpError("tcp", "set-keep-alive-count", netip.AddrPort{}, err)
 	}
 	return nil
 }
 
 // Equivalent to the IP_TTL & IPV6_UNICAST_HOPS socket options.
 func (s *TcpSocket) GetHopLimit() (uint8, error) {
-	result := s.inner.GetHopLimit()
-	if result.IsErr() {
-		return 0, fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return 0, closedError("tcp", "get-hop-limit")
+	}
+	defer s.res.release()
+
+	v, err := s.inner.GetHopLimit()
+	if err != nil {
+		return 0, newOpError("tcp", "get-hop-limit", netip.AddrPort{}, err)
 	}
-	return result.Ok(), nil
+	return v, nil
 }
 
 // Equivalent to the IP_TTL & IPV6_UNICAST_HOPS socket options.
 func (s *TcpSocket) SetHopLimit(v uint8) error {
-	err := s.inner.SetHopLimit(v)
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-hop-limit")
+	}
+	defer s.res.release()
+
+	if err := s.inner.SetHopLimit(v); err != nil {
+		return newOpError("tcp", "set-hop-limit", netip.AddrPort{}, err)
 	}
 	return nil
 }
 
 // Kernel buffer space reserved for receiving on this socket.
 func (s *TcpSocket) GetReceiveBufferSize() (uint64, error) {
-	result := s.inner.GetReceiveBufferSize()
-	if result.IsErr() {
-		return 0, fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return 0, closedError("tcp", "get-receive-buffer-size")
+	}
+	defer s.res.release()
+
+	v, err := s.inner.GetReceiveBufferSize()
+	if err != nil {
+		return 0, newOpError("tcp", "get-receive-buffer-size", netip.AddrPort{}, err)
 	}
-	return result.Ok(), nil
+	return v, nil
 }
 
 // Kernel buffer space reserved for receiving on this socket.
 func (s *TcpSocket) SetReceiveBufferSize(size uint64) error {
-	err := s.inner.SetReceiveBufferSize(size)
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-receive-buffer-size")
+	}
+	defer s.res.release()
+
+	if err := s.inner.SetReceiveBufferSize(size); err != nil {
+		return newOpError("tcp", "set-receive-buffer-size", netip.AddrPort{}, err)
 	}
 	return nil
 }
 
 // Kernel buffer space reserved for sending on this socket.
 func (s *TcpSocket) GetSendBufferSize() (uint64, error) {
-	result := s.inner.GetSendBufferSize()
-	if result.IsErr() {
-		return 0, fromWitErrorCode(result.Err())
+	if !s.res.acquire() {
+		return 0, closedError("tcp", "get-send-buffer-size")
+	}
+	defer s.res.release()
+
+	v, err := s.inner.GetSendBufferSize()
+	if err != nil {
+		return 0, newOpError("tcp", "get-send-buffer-size", netip.AddrPort{}, err)
 	}
-	return result.Ok(), nil
+	return v, nil
 }
 
 // Kernel buffer space reserved for sending on this socket.
 func (s *TcpSocket) SetSendBufferSize(size uint64) error {
-	err := s.inner.SetSendBufferSize(size)
-	if err.IsErr() {
-		return fromWitErrorCode(err.Err())
+	if !s.res.acquire() {
+		return closedError("tcp", "set-send-buffer-size")
 	}
-	return nil
-}
+	defer s.res.release()
 
-func toWasiIpSockAddr(addr string) (wasiSockets.IpSocketAddress, error) {
-	ip, err := netip.ParseAddrPort(addr)
-	if err != nil {
-		return wasiSockets.IpSocketAddress{}, err
-	}
-
-	var socketAddr wasiSockets.IpSocketAddress
-
-	if ip.Addr().Is4() {
-		b := ip.Addr().As4()
-		socketAddr = wasiSockets.MakeIpSocketAddressIpv4(wasiSockets.Ipv4SocketAddress{
-			Address: witTypes.Tuple4[uint8, uint8, uint8, uint8]{
-				F0: b[0],
-				F1: b[1],
-				F2: b[2],
-				F3: b[3],
-			},
-			Port: ip.Port(),
-		})
-	} else if ip.Addr().Is6() {
-		b := ip.Addr().As16()
-
-		// Zone == ScopeId
-		var scope uint32
-		if z := ip.Addr().Zone(); z != "" {
-			n, err := strconv.ParseUint(z, 10, 32)
-			if err != nil {
-				// Non-numeric zone (e.g. "eth0")
-				return wasiSockets.IpSocketAddress{}, fmt.Errorf("non-numeric zone %q: %w", z, err)
-			}
-			scope = uint32(n)
-		}
-		socketAddr = wasiSockets.MakeIpSocketAddressIpv6(wasiSockets.Ipv6SocketAddress{
-			FlowInfo: 0, // Setting to 0 since the net/netip package doesn't expose this
-			Address: witTypes.Tuple8[uint16, uint16, uint16, uint16, uint16, uint16, uint16, uint16]{
-				F0: binary.BigEndian.Uint16(b[0:2]),
-				F1: binary.BigEndian.Uint16(b[2:4]),
-				F2: binary.BigEndian.Uint16(b[4:6]),
-				F3: binary.BigEndian.Uint16(b[6:8]),
-				F4: binary.BigEndian.Uint16(b[8:10]),
-				F5: binary.BigEndian.Uint16(b[10:12]),
-				F6: binary.BigEndian.Uint16(b[12:14]),
-				F7: binary.BigEndian.Uint16(b[14:16]),
-			},
-			Port:    ip.Port(),
-			ScopeId: scope,
-		})
-
-	} else {
-		panic("parsed ip addr is neither ipv4 nor ipv6")
-	}
-
-	return socketAddr, nil
-}
-
-func fromWasiIpSocketAddr(addr wasiSockets.IpSocketAddress) netip.AddrPort {
-	switch addr.Tag() {
-	case wasiSockets.IpSocketAddressIpv4:
-		v4 := addr.Ipv4()
-		b := v4.Address
-		ip := netip.AddrFrom4([4]byte{b.F0, b.F1, b.F2, b.F3})
-		return netip.AddrPortFrom(ip, v4.Port)
-	case wasiSockets.IpSocketAddressIpv6:
-		v6 := addr.Ipv6()
-		a := v6.Address
-		var b [16]byte
-		binary.BigEndian.PutUint16(b[0:2], a.F0)
-		binary.BigEndian.PutUint16(b[2:4], a.F1)
-		binary.BigEndian.PutUint16(b[4:6], a.F2)
-		binary.BigEndian.PutUint16(b[6:8], a.F3)
-		binary.BigEndian.PutUint16(b[8:10], a.F4)
-		binary.BigEndian.PutUint16(b[10:12], a.F5)
-		binary.BigEndian.PutUint16(b[12:14], a.F6)
-		binary.BigEndian.PutUint16(b[14:16], a.F7)
-		ip := netip.AddrFrom16(b)
-		return netip.AddrPortFrom(ip, v6.Port)
-	default:
-		panic(fmt.Sprintf("unimplemented IpSocketAddress type: %v", addr))
-	}
-}
-
-func toWasiIpAddressFamily(af IpAddressFamily) wasiSockets.IpAddressFamily {
-	switch af {
-	case IpAddressFamilyIpv4:
-		return wasiSockets.IpAddressFamilyIpv4
-	case IpAddressFamilyIpv6:
-		return wasiSockets.IpAddressFamilyIpv6
-	default:
-		panic("Wow, who could've guessed that this code would live to see a THIRD IpAddress family?!")
-	}
-}
-
-func fromWitErrorCode(err wasiSockets.ErrorCode) error {
-	switch err.Tag() {
-	case wasiSockets.ErrorCodeOther:
-		return fmt.Errorf("other error")
-	case wasiSockets.ErrorCodeAccessDenied:
-		return fmt.Errorf("access denied")
-	case wasiSockets.ErrorCodeNotSupported:
-		return fmt.Errorf("operation is not supported")
-	case wasiSockets.ErrorCodeInvalidArgument:
-		return fmt.Errorf("one of the argumenets is invalid")
-	case wasiSockets.ErrorCodeOutOfMemory:
-		return fmt.Errorf("out of memory")
-	case wasiSockets.ErrorCodeTimeout:
-		return fmt.Errorf("not enough memory to complete the operation")
-	case wasiSockets.ErrorCodeInvalidState:
-		return fmt.Errorf("operation is not valid in the socket's current state")
-	case wasiSockets.ErrorCodeAddressNotBindable:
-		return fmt.Errorf("bind operation failed because the provided address is not an address that the `network` can bind to")
-	case wasiSockets.ErrorCodeAddressInUse:
-		return fmt.Errorf("bind operation failed because the provided address is already in use or because there are no ephemeral ports available")
-	case wasiSockets.ErrorCodeRemoteUnreachable:
-		return fmt.Errorf("remote address is not reachable")
-	case wasiSockets.ErrorCodeConnectionRefused:
-		return fmt.Errorf("TCP connection was forcefully rejected")
-	case wasiSockets.ErrorCodeConnectionReset:
-		return fmt.Errorf("TCP connection was reset")
-	case wasiSockets.ErrorCodeConnectionAborted:
-		return fmt.Errorf("TCP connection was aborted")
-	case wasiSockets.ErrorCodeDatagramTooLarge:
-		return fmt.Errorf("size of a datagram sent to a UDP socket exceeded the maximum supported size")
-	default:
-		panic("unimplemented error code")
+	if err := s.inner.SetSendBufferSize(size); err != nil {
+		return newOpError("tcp", "set-send-buffer-size", netip.AddrPort{}, err)
 	}
+	return nil
 }
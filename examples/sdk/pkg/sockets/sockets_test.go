@@ -0,0 +1,292 @@
+package sockets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"pkg/fake"
+	"testing"
+	"time"
+)
+
+// Start listening on an ephemeral loopback port.
+func listenLoopback(t *testing.T) *Listener {
+	t.Helper()
+
+	l, err := (&ListenConfig{}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+// Connect to l, returning both ends of the connection.
+func connectLoopback(t *testing.T, l *Listener) (client, server *TcpSocket) {
+	t.Helper()
+
+	client, err := (&DialConfig{}).Dial(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	server, err = l.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return client, server
+}
+
+func TestTcpLoopback(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+	client, server := connectLoopback(t, l)
+
+	if got, want := client.RemoteAddr().String(), l.Addr().String(); got != want {
+		t.Errorf("client RemoteAddr() = %s, expected %s", got, want)
+	}
+	if got, want := server.RemoteAddr().String(), client.LocalAddr().String(); got != want {
+		t.Errorf("server RemoteAddr() = %s, expected %s", got, want)
+	}
+
+	// More than the fake buffers, so the writer sees backpressure.
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 16*1024)
+	go func() {
+		if _, err := client.Write(payload); err != nil {
+			t.Errorf("Write: %v", err)
+		}
+		if err := client.CloseWrite(); err != nil {
+			t.Errorf("CloseWrite: %v", err)
+		}
+	}()
+
+	got, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Errorf("received %d bytes, expected %d", len(got), len(payload))
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	fake.Install(t)
+
+	_, err := (&DialConfig{}).Dial(context.Background(), "tcp", "127.0.0.1:9")
+	if !errors.Is(err, ErrConnectionRefused) {
+		t.Fatalf("Dial error = %v, expected ErrConnectionRefused", err)
+	}
+
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "connect" {
+		t.Errorf("expected a connect *OpError, got %#v", err)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	host := fake.Install(t)
+	l := listenLoopback(t)
+
+	host.InjectError("tcp-socket.connect", ErrRemoteUnreachable)
+	_, err := (&DialConfig{}).Dial(context.Background(), "tcp", l.Addr().String())
+	if !errors.Is(err, ErrRemoteUnreachable) {
+		t.Fatalf("Dial error = %v, expected ErrRemoteUnreachable", err)
+	}
+
+	client, server := connectLoopback(t, l)
+	host.InjectError("tcp-socket.receive", ErrConnectionReset)
+	client.Write([]byte("hello"))
+
+	_, err = server.Read(make([]byte, 16))
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "read" || !errors.Is(err, ErrConnectionReset) {
+		t.Errorf("Read error = %v, expected a read *OpError matching ErrConnectionReset", err)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	fake.Install(t)
+	_, server := connectLoopback(t, listenLoopback(t))
+
+	server.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err := server.Read(make([]byte, 16))
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("Read error = %v, expected a timeout", err)
+	}
+}
+
+func TestReadContextCancelled(t *testing.T) {
+	fake.Install(t)
+	client, server := connectLoopback(t, listenLoopback(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := server.ReadContext(ctx, make([]byte, 16)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ReadContext error = %v, expected context.Canceled", err)
+	}
+
+	// The abandoned read is picked up by the next one, without losing data.
+	client.Write([]byte("hello"))
+	buf := make([]byte, 16)
+	n, err := server.Read(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Errorf("Read = %q, %v, expected \"hello\"", buf[:n], err)
+	}
+}
+
+func TestClosedSocket(t *testing.T) {
+	fake.Install(t)
+	client, _ := connectLoopback(t, listenLoopback(t))
+
+	client.Close()
+	if _, err := client.Write([]byte("hello")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write error = %v, expected net.ErrClosed", err)
+	}
+	if _, err := client.GetKeepAliveCount(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("GetKeepAliveCount error = %v, expected net.ErrClosed", err)
+	}
+}
+
+func TestListenerClose(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Accept()
+		done <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	l.Close()
+	if err := <-done; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept error = %v, expected net.ErrClosed", err)
+	}
+
+	if _, err := (&DialConfig{}).Dial(context.Background(), "tcp", l.Addr().String()); !errors.Is(err, ErrConnectionRefused) {
+		t.Errorf("Dial error = %v, expected ErrConnectionRefused once the listener is closed", err)
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	host := fake.Install(t)
+	client, _ := connectLoopback(t, listenLoopback(t))
+
+	host.InjectError("tcp-socket.send", ErrConnectionReset)
+	_, err := client.Write([]byte("hello"))
+
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "write" || !errors.Is(err, ErrConnectionReset) {
+		t.Errorf("Write error = %v, expected a write *OpError matching ErrConnectionReset", err)
+	}
+}
+
+func TestWriteBeforeConnect(t *testing.T) {
+	fake.Install(t)
+
+	s, err := NewSocket(IpAddressFamilyIpv4)
+	if err != nil {
+		t.Fatalf("NewSocket: %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.Write([]byte("hello")); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Write error = %v, expected ErrInvalidState", err)
+	}
+	if err := s.CloseWrite(); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("CloseWrite error = %v, expected ErrInvalidState", err)
+	}
+}
+
+func TestCloseWrite(t *testing.T) {
+	fake.Install(t)
+	client, server := connectLoopback(t, listenLoopback(t))
+
+	client.Write([]byte("hello"))
+	if err := client.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite: %v", err)
+	}
+	if err := client.CloseWrite(); err != nil {
+		t.Errorf("second CloseWrite: %v", err)
+	}
+	if _, err := client.Write([]byte("more")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write error = %v after CloseWrite, expected net.ErrClosed", err)
+	}
+
+	got, err := io.ReadAll(server)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("ReadAll = %q, %v, expected \"hello\"", got, err)
+	}
+
+	// Only the client's sending side is shut down.
+	server.Write([]byte("reply"))
+	server.CloseWrite()
+	if got, err := io.ReadAll(client); err != nil || string(got) != "reply" {
+		t.Errorf("client ReadAll = %q, %v, expected \"reply\"", got, err)
+	}
+}
+
+func TestConnectContextCancelled(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+
+	s, err := NewSocket(IpAddressFamilyIpv4)
+	if err != nil {
+		t.Fatalf("NewSocket: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.ConnectContext(ctx, l.Addr().String()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ConnectContext error = %v, expected context.Canceled", err)
+	}
+
+	// Nothing was started, so the socket can still connect.
+	if err := s.Connect(l.Addr().String()); err != nil {
+		t.Errorf("Connect: %v", err)
+	}
+}
+
+func TestAcceptContextDeadline(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.AcceptContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("AcceptContext error = %v, expected context.DeadlineExceeded", err)
+	}
+
+	// The abandoned accept hands its connection to the next one.
+	connectLoopback(t, l)
+}
+
+func TestWriteContextCancelled(t *testing.T) {
+	fake.Install(t)
+	client, server := connectLoopback(t, listenLoopback(t))
+
+	// Nobody reads, so the write blocks on backpressure until ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	payload := bytes.Repeat([]byte("x"), 4<<20)
+	if _, err := client.WriteContext(ctx, payload); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WriteContext error = %v, expected context.DeadlineExceeded", err)
+	}
+
+	// The abandoned write finishes before the next one starts.
+	go func() {
+		client.Write([]byte("end"))
+		client.CloseWrite()
+	}()
+	got, err := io.ReadAll(server)
+	if err != nil || len(got) != len(payload)+3 || string(got[len(payload):]) != "end" {
+		t.Errorf("ReadAll = %d bytes, %v, expected the payload followed by \"end\"", len(got), err)
+	}
+}
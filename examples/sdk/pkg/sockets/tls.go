@@ -0,0 +1,186 @@
+package sockets
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"path"
+	"pkg/internal/backend"
+)
+
+// Cryptographically secure random bytes from wasi:random/random. DialTLS and
+// ListenTLS use it for tls.Config.Rand unless the config provides its own.
+var Rand io.Reader = hostRandom{}
+
+type hostRandom struct{}
+
+func (hostRandom) Read(b []byte) (int, error) {
+	return copy(b, backend.Current().GetRandomBytes(uint64(len(b)))), nil
+}
+
+// Connect to address with DialConfig{}.DialTLS.
+func DialTLS(ctx context.Context, network, address string, config *tls.Config) (*tls.Conn, error) {
+	return (&DialConfig{}).DialTLS(ctx, network, address, config)
+}
+
+// Listen on address with ListenConfig{}.ListenTLS.
+func ListenTLS(ctx context.Context, network, address string, config *tls.Config) (net.Listener, error) {
+	return (&ListenConfig{}).ListenTLS(ctx, network, address, config)
+}
+
+// Connect to address like Dial, then complete a TLS handshake as the client.
+// If config does not set ServerName, the IP address being dialed is verified
+// instead. The host has no system certificate pool, so config.RootCAs should
+// be set, e.g. from LoadRootCAs or RootCAsFromPEM.
+func (d *DialConfig) DialTLS(ctx context.Context, network, address string, config *tls.Config) (*tls.Conn, error) {
+	ip, af, err := resolveAddr("connect", network, address, "tcp", "tcp4", "tcp6")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := d.dial(ctx, ip, af)
+	if err != nil {
+		return nil, err
+	}
+
+	return clientHandshake(ctx, conn, config, ip.Addr().String())
+}
+
+// Complete a TLS handshake as the client over conn, verifying the server
+// against serverName unless config sets ServerName. Closes conn if the
+// handshake fails.
+func clientHandshake(ctx context.Context, conn *TcpSocket, config *tls.Config, serverName string) (*tls.Conn, error) {
+	config = withHostRandom(config)
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// Listen on address like Listen, returning a listener whose connections
+// complete a TLS handshake as the server on their first read or write.
+// config must provide at least one certificate.
+func (lc *ListenConfig) ListenTLS(ctx context.Context, network, address string, config *tls.Config) (net.Listener, error) {
+	if config == nil || len(config.Certificates) == 0 && config.GetCertificate == nil && config.GetConfigForClient == nil {
+		return nil, &OpError{Op: "listen", Net: network, Err: errors.New("tls: neither Certificates, GetCertificate, nor GetConfigForClient set in Config")}
+	}
+
+	l, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(l.NetListener(), withHostRandom(config)), nil
+}
+
+// A copy of config that draws its randomness from the host.
+func withHostRandom(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.Rand == nil {
+		config.Rand = Rand
+	}
+	return config
+}
+
+// Build a certificate pool from PEM-encoded bundles, e.g. ones embedded with
+// go:embed.
+func RootCAsFromPEM(bundles ...[]byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, bundle := range bundles {
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("sockets: no certificates found in PEM bundle")
+		}
+	}
+	return pool, nil
+}
+
+// Build a certificate pool from the .pem, .crt and .cer files in dir, which
+// must be a preopened directory or lie within one.
+func LoadRootCAs(dir string) (*x509.CertPool, error) {
+	d, err := openPreopened(dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: dir, Err: err}
+	}
+	defer d.Drop()
+
+	entries := d.ReadDirectory()
+	defer entries.Drop()
+
+	pool := x509.NewCertPool()
+	found := false
+	for {
+		entry, err := entries.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: dir, Err: err}
+		}
+
+		switch path.Ext(entry.Name) {
+		case ".pem", ".crt", ".cer":
+		default:
+			continue
+		}
+		if entry.Type != backend.DescriptorTypeRegularFile && entry.Type != backend.DescriptorTypeSymbolicLink {
+			continue
+		}
+
+		data, err := readFileAt(d, entry.Name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "read", Path: path.Join(dir, entry.Name), Err: err}
+		}
+		found = pool.AppendCertsFromPEM(data) || found
+	}
+
+	if !found {
+		return nil, fmt.Errorf("sockets: no certificates found in %s", dir)
+	}
+	return pool, nil
+}
+
+// Open the directory at name, which is resolved against the preopen with the
+// longest matching path.
+func openPreopened(name string) (backend.Descriptor, error) {
+	preopens := backend.Current().GetDirectories()
+	defer func() {
+		for _, p := range preopens {
+			p.Descriptor.Drop()
+		}
+	}()
+
+	paths := make([]string, len(preopens))
+	for i, p := range preopens {
+		paths[i] = backend.CleanPreopenPath(p.Path)
+	}
+	i, rel := backend.MatchPreopen(paths, name)
+	if i < 0 {
+		return nil, fs.ErrNotExist
+	}
+	return preopens[i].Descriptor.OpenAt(backend.PathFlagsSymlinkFollow, rel, backend.OpenFlagsDirectory, backend.DescriptorFlagsRead)
+}
+
+func readFileAt(dir backend.Descriptor, name string) ([]byte, error) {
+	f, err := dir.OpenAt(backend.PathFlagsSymlinkFollow, name, 0, backend.DescriptorFlagsRead)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Drop()
+
+	stream := f.ReadViaStream(0)
+	defer stream.Drop()
+	return io.ReadAll(stream)
+}
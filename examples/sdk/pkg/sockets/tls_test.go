@@ -0,0 +1,165 @@
+package sockets
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/fs"
+	"math/big"
+	"net"
+	"pkg/fake"
+	"testing"
+	"time"
+)
+
+// A self-signed certificate for 127.0.0.1, and its PEM encoding.
+func selfSignedCert(t *testing.T) (tls.Certificate, []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sdk test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// Serve TLS on an ephemeral loopback port, echoing back whatever each client
+// sends.
+func startTLSEcho(t *testing.T, cert tls.Certificate) string {
+	t.Helper()
+
+	l, err := ListenTLS(context.Background(), "tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("ListenTLS: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestTLSRoundTrip(t *testing.T) {
+	fake.Install(t)
+	cert, certPEM := selfSignedCert(t)
+	addr := startTLSEcho(t, cert)
+
+	roots, err := RootCAsFromPEM(certPEM)
+	if err != nil {
+		t.Fatalf("RootCAsFromPEM: %v", err)
+	}
+	conn, err := DialTLS(context.Background(), "tcp", addr, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("DialTLS: %v", err)
+	}
+	defer conn.Close()
+
+	if state := conn.ConnectionState(); !state.HandshakeComplete || len(state.VerifiedChains) == 0 {
+		t.Errorf("connection state = %+v", state)
+	}
+
+	conn.Write([]byte("hello, world"))
+	got := make([]byte, 12)
+	if _, err := io.ReadFull(conn, got); err != nil || string(got) != "hello, world" {
+		t.Errorf("read %q, %v, expected \"hello, world\"", got, err)
+	}
+}
+
+func TestTLSVerifiesDialedAddress(t *testing.T) {
+	fake.Install(t)
+	cert, certPEM := selfSignedCert(t)
+	addr := startTLSEcho(t, cert)
+	_, port, _ := net.SplitHostPort(addr)
+
+	// Without a ServerName, the certificate is checked against the address
+	// the address string resolves to.
+	roots, _ := RootCAsFromPEM(certPEM)
+	for _, address := range []string{":" + port, "[::ffff:127.0.0.1]:" + port} {
+		conn, err := DialTLS(context.Background(), "tcp", address, &tls.Config{RootCAs: roots})
+		if err != nil {
+			t.Errorf("DialTLS(%q): %v", address, err)
+			continue
+		}
+		conn.Close()
+	}
+}
+
+func TestTLSUntrustedServer(t *testing.T) {
+	fake.Install(t)
+	cert, _ := selfSignedCert(t)
+	addr := startTLSEcho(t, cert)
+
+	_, err := DialTLS(context.Background(), "tcp", addr, &tls.Config{RootCAs: x509.NewCertPool()})
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		t.Errorf("DialTLS = %v, expected x509.UnknownAuthorityError", err)
+	}
+}
+
+func TestLoadRootCAs(t *testing.T) {
+	host := fake.Install(t)
+	cert, certPEM := selfSignedCert(t)
+	addr := startTLSEcho(t, cert)
+
+	host.Preopen("/etc")
+	host.WriteFile("/etc/ssl/certs/test.pem", certPEM)
+	host.WriteFile("/etc/ssl/certs/README", []byte("not a certificate"))
+
+	roots, err := LoadRootCAs("/etc/ssl/certs")
+	if err != nil {
+		t.Fatalf("LoadRootCAs: %v", err)
+	}
+	conn, err := DialTLS(context.Background(), "tcp", addr, &tls.Config{RootCAs: roots})
+	if err != nil {
+		t.Fatalf("DialTLS: %v", err)
+	}
+	conn.Close()
+
+	if _, err := LoadRootCAs("/opt/certs"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("LoadRootCAs outside any preopen = %v, expected fs.ErrNotExist", err)
+	}
+	if _, err := RootCAsFromPEM([]byte("not a certificate")); err == nil {
+		t.Error("RootCAsFromPEM accepted a bundle without certificates")
+	}
+}
+
+func TestListenTLSRequiresCertificate(t *testing.T) {
+	fake.Install(t)
+
+	if _, err := ListenTLS(context.Background(), "tcp", "127.0.0.1:0", &tls.Config{}); err == nil {
+		t.Error("ListenTLS succeeded without a certificate")
+	}
+}
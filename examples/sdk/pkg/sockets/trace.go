@@ -0,0 +1,118 @@
+package sockets
+
+import (
+	"net/netip"
+	"time"
+)
+
+// Why a TCP connection ended.
+type CloseReason uint8
+
+const (
+	// The socket is still open.
+	CloseReasonNone CloseReason = iota
+
+	// Close was called while the connection was still usable.
+	CloseReasonLocal
+
+	// The peer shut down its side of the connection, and the socket was
+	// closed after reading EOF.
+	CloseReasonPeer
+
+	// A connect, read or write failed with an error reported by the host.
+	CloseReasonError
+
+	// A connect was abandoned because its context was done.
+	CloseReasonCanceled
+
+	// A Server closed the connection because it was idle for too long.
+	CloseReasonIdleTimeout
+
+	// A Server closed the connection because it was shutting down.
+	CloseReasonShutdown
+)
+
+var closeReasonNames = [...]string{
+	CloseReasonNone:        "open",
+	CloseReasonLocal:       "closed locally",
+	CloseReasonPeer:        "closed by peer",
+	CloseReasonError:       "connection error",
+	CloseReasonCanceled:    "connect canceled",
+	CloseReasonIdleTimeout: "idle timeout",
+	CloseReasonShutdown:    "server shutdown",
+}
+
+func (r CloseReason) String() string {
+	if int(r) < len(closeReasonNames) {
+		return closeReasonNames[r]
+	}
+	return "unknown close reason"
+}
+
+// A snapshot of the counters and timestamps of a TCP socket.
+type SocketStats struct {
+	// The number of bytes handed to the caller by Read, and accepted from
+	// the caller by Write.
+	BytesRead    uint64
+	BytesWritten uint64
+
+	// When the socket was created, when it was connected or accepted, and
+	// when it was closed. Connected and Closed are zero until those happen.
+	Created   time.Time
+	Connected time.Time
+	Closed    time.Time
+
+	// Whether the socket was accepted from a Listener rather than dialed.
+	Accepted bool
+
+	// Why the connection ended, and the error that ended it for
+	// CloseReasonError and CloseReasonCanceled.
+	CloseReason CloseReason
+	CloseErr    error
+}
+
+// How long the socket has been connected: until it was closed, or until now
+// if it is still open. Zero if it never connected.
+func (st SocketStats) Lifetime() time.Duration {
+	if st.Connected.IsZero() {
+		return 0
+	}
+	if st.Closed.IsZero() {
+		return time.Since(st.Connected)
+	}
+	return st.Closed.Sub(st.Connected)
+}
+
+// Receives events from TCP sockets, e.g. to record traces or metrics. Set it
+// through DialConfig.Tracer, ListenConfig.Tracer or TcpSocket.SetTracer.
+// Sockets accepted from a listener inherit its tracer. Methods may be called
+// from multiple goroutines at once, and must not block.
+//
+// Embed NopTracer to implement only the events of interest.
+type Tracer interface {
+	// Called before and after connecting to remote. err is nil if the
+	// connection was established.
+	ConnectStart(conn *TcpSocket, remote netip.AddrPort)
+	ConnectDone(conn *TcpSocket, remote netip.AddrPort, err error)
+
+	// Called when a listener accepts conn.
+	Accept(conn *TcpSocket)
+
+	// Called when a read or write fails, including because of a deadline or
+	// a cancelled context. Reads reaching EOF are not reported.
+	ReadError(conn *TcpSocket, err error)
+	WriteError(conn *TcpSocket, err error)
+
+	// Called once, when conn is closed.
+	Close(conn *TcpSocket, stats SocketStats)
+}
+
+// A Tracer that ignores every event.
+type NopTracer struct{}
+
+func (NopTracer) ConnectStart(*TcpSocket, netip.AddrPort)       {}
+func (NopTracer) ConnectDone(*TcpSocket, netip.AddrPort, error) {}
+func (NopTracer) Accept(*TcpSocket)                             {}
+func (NopTracer) ReadError(*TcpSocket, error)                   {}
+func (NopTracer) WriteError(*TcpSocket, error)                  {}
+func (NopTracer) Close(*TcpSocket, SocketStats)                 {}
@@ -0,0 +1,146 @@
+package sockets
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"pkg/fake"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the events it receives, in order.
+type recordingTracer struct {
+	mu     sync.Mutex
+	events []string
+	closed []SocketStats
+}
+
+func (r *recordingTracer) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingTracer) ConnectStart(*TcpSocket, netip.AddrPort) { r.record("connect-start") }
+
+func (r *recordingTracer) ConnectDone(_ *TcpSocket, _ netip.AddrPort, err error) {
+	if err != nil {
+		r.record("connect-error")
+		return
+	}
+	r.record("connect-done")
+}
+
+func (r *recordingTracer) Accept(*TcpSocket)            { r.record("accept") }
+func (r *recordingTracer) ReadError(*TcpSocket, error)  { r.record("read-error") }
+func (r *recordingTracer) WriteError(*TcpSocket, error) { r.record("write-error") }
+
+func (r *recordingTracer) Close(_ *TcpSocket, stats SocketStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "close")
+	r.closed = append(r.closed, stats)
+}
+
+func (r *recordingTracer) snapshot() ([]string, []SocketStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...), append([]SocketStats(nil), r.closed...)
+}
+
+func TestTracerAndStats(t *testing.T) {
+	fake.Install(t)
+
+	serverTrace, clientTrace := &recordingTracer{}, &recordingTracer{}
+	l, err := (&ListenConfig{Tracer: serverTrace}).Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+
+	client, err := (&DialConfig{Tracer: clientTrace}).Dial(context.Background(), "tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+
+	client.Write([]byte("hello, world"))
+	client.CloseWrite()
+	if _, err := io.ReadAll(server); err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+
+	stats := server.Stats()
+	if stats.BytesRead != 12 || stats.BytesWritten != 0 || !stats.Accepted {
+		t.Errorf("server stats = %+v", stats)
+	}
+	if stats.Connected.IsZero() || stats.CloseReason != CloseReasonNone {
+		t.Errorf("open server socket has stats %+v", stats)
+	}
+	if got := client.Stats().BytesWritten; got != 12 {
+		t.Errorf("client BytesWritten = %d, expected 12", got)
+	}
+
+	server.Close()
+	server.Close()
+	client.Close()
+
+	events, closed := serverTrace.snapshot()
+	if !slices.Equal(events, []string{"accept", "close"}) {
+		t.Errorf("server events = %v", events)
+	}
+	if closed[0].CloseReason != CloseReasonPeer || closed[0].Closed.IsZero() || closed[0].Lifetime() <= 0 {
+		t.Errorf("server closed with %+v", closed[0])
+	}
+
+	events, closed = clientTrace.snapshot()
+	if !slices.Equal(events, []string{"connect-start", "connect-done", "close"}) {
+		t.Errorf("client events = %v", events)
+	}
+	if closed[0].CloseReason != CloseReasonLocal {
+		t.Errorf("client CloseReason = %v, expected %v", closed[0].CloseReason, CloseReasonLocal)
+	}
+}
+
+func TestTracerErrors(t *testing.T) {
+	host := fake.Install(t)
+	l := listenLoopback(t)
+
+	tracer := &recordingTracer{}
+	_, err := (&DialConfig{Tracer: tracer}).Dial(context.Background(), "tcp", "127.0.0.1:9")
+	if !errors.Is(err, ErrConnectionRefused) {
+		t.Fatalf("Dial error = %v", err)
+	}
+	events, closed := tracer.snapshot()
+	if !slices.Equal(events, []string{"connect-start", "connect-error", "close"}) {
+		t.Errorf("events = %v", events)
+	}
+	if closed[0].CloseReason != CloseReasonError || !errors.Is(closed[0].CloseErr, ErrConnectionRefused) {
+		t.Errorf("closed with %+v", closed[0])
+	}
+
+	client, _ := connectLoopback(t, l)
+	tracer = &recordingTracer{}
+	client.SetTracer(tracer)
+
+	client.SetReadDeadline(time.Now())
+	client.Read(make([]byte, 1))
+	host.InjectError("tcp-socket.send", ErrConnectionReset)
+	client.Write([]byte("hello"))
+	client.Close()
+
+	events, closed = tracer.snapshot()
+	if !slices.Equal(events, []string{"read-error", "write-error", "close"}) {
+		t.Errorf("events = %v", events)
+	}
+	if closed[0].CloseReason != CloseReasonError || !errors.Is(closed[0].CloseErr, ErrConnectionReset) {
+		t.Errorf("closed with %+v", closed[0])
+	}
+}
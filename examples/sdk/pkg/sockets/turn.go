@@ -0,0 +1,58 @@
+package sockets
+
+import "context"
+
+// A lock that waiters can give up on. Reads, writes and accepts each take a
+// turn, so that concurrent callers are served one at a time while each still
+// honors its own context and deadline.
+type turn chan struct{}
+
+func newTurn() turn {
+	return make(turn, 1)
+}
+
+// Wait for the turn, giving up if ctx is done, the deadline passes or the
+// resource is closed first. d may be nil. Returns false if the turn was not
+// taken, in which case turnError describes why.
+func (t turn) take(ctx context.Context, d *deadline, r *resource) bool {
+	select {
+	case t <- struct{}{}:
+		return true
+	default:
+	}
+
+	var expired <-chan struct{}
+	if d != nil {
+		expired = d.wait()
+	}
+	select {
+	case t <- struct{}{}:
+		return true
+	case <-expired:
+	case <-ctx.Done():
+	case <-r.done:
+	}
+	return false
+}
+
+// Wait for the turn without giving up.
+func (t turn) lock() {
+	t <- struct{}{}
+}
+
+// Give the turn to the next waiter.
+func (t turn) give() {
+	<-t
+}
+
+// Why take gave up.
+func turnError(network, op string, ctx context.Context, d *deadline, r *resource) *OpError {
+	switch {
+	case isClosedChan(r.done):
+		return closedError(network, op)
+	case d != nil && isClosedChan(d.wait()):
+		return timeoutError(network, op)
+	default:
+		return &OpError{Op: op, Net: network, Err: ctx.Err()}
+	}
+}
@@ -0,0 +1,242 @@
+package sockets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"pkg/fake"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentReadAndWrite(t *testing.T) {
+	fake.Install(t)
+	client, server := connectLoopback(t, listenLoopback(t))
+	go func() {
+		io.Copy(server, server)
+		server.CloseWrite()
+	}()
+
+	// More than the fake buffers in each direction, so that the reader and
+	// the writer both block at times.
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 32*1024)
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		for chunk := range slices.Chunk(payload, 1000) {
+			if _, err := client.Write(chunk); err != nil {
+				t.Errorf("Write: %v", err)
+				return
+			}
+		}
+		client.CloseWrite()
+	})
+	wg.Go(func() {
+		for range 100 {
+			client.Stats()
+			client.SetReadDeadline(time.Time{})
+			client.GetLocalAddress()
+		}
+	})
+
+	got, err := io.ReadAll(client)
+	wg.Wait()
+	if err != nil || !bytes.Equal(got, payload) {
+		t.Errorf("echoed %d bytes, %v, expected %d bytes back", len(got), err, len(payload))
+	}
+}
+
+func TestConcurrentWritesAreNotInterleaved(t *testing.T) {
+	fake.Install(t)
+	client, server := connectLoopback(t, listenLoopback(t))
+
+	const writers, records, size = 8, 50, 4096
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Go(func() {
+			record := bytes.Repeat([]byte{byte('a' + w)}, size)
+			for range records {
+				if _, err := client.Write(record); err != nil {
+					t.Errorf("Write: %v", err)
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		wg.Wait()
+		client.CloseWrite()
+	}()
+
+	got, err := io.ReadAll(server)
+	if err != nil || len(got) != writers*records*size {
+		t.Fatalf("read %d bytes, %v, expected %d", len(got), err, writers*records*size)
+	}
+	for i := 0; i < len(got); i += size {
+		if record := got[i : i+size]; !bytes.Equal(record, bytes.Repeat(record[:1], size)) {
+			t.Fatalf("record at offset %d was interleaved with another write", i)
+		}
+	}
+}
+
+func TestConcurrentAccept(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+
+	const acceptors, conns = 8, 64
+	accepted := make(chan netip.AddrPort, conns)
+	var wg sync.WaitGroup
+	for range acceptors {
+		wg.Go(func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				addr, _ := conn.GetRemoteAddress()
+				accepted <- addr
+				conn.Close()
+			}
+		})
+	}
+
+	dialed := make(map[netip.AddrPort]bool)
+	for range conns {
+		conn := dial(t, l.Addr().String())
+		addr, _ := conn.GetLocalAddress()
+		dialed[addr] = true
+	}
+	for range conns {
+		addr := <-accepted
+		if !dialed[addr] {
+			t.Errorf("accepted %v, which was not dialed or was accepted twice", addr)
+		}
+		delete(dialed, addr)
+	}
+
+	l.Close()
+	wg.Wait()
+	if len(accepted) != 0 {
+		t.Errorf("%d connections accepted more than once", len(accepted))
+	}
+}
+
+func TestWaitingCallersHonorTheirOwnContext(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+	_, server := connectLoopback(t, l)
+
+	// One accept and one read block indefinitely...
+	go l.Accept()
+	go server.Read(make([]byte, 1))
+	time.Sleep(10 * time.Millisecond)
+
+	// ...while others waiting for their turn still give up on time.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.AcceptContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AcceptContext = %v, expected context.DeadlineExceeded", err)
+	}
+	if _, err := server.ReadContext(ctx, make([]byte, 1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ReadContext = %v, expected context.DeadlineExceeded", err)
+	}
+}
+
+func TestCloseUnblocksConcurrentCalls(t *testing.T) {
+	fake.Install(t)
+	l := listenLoopback(t)
+	client, _ := connectLoopback(t, l)
+
+	big := make([]byte, 1<<20)
+	errs := make(chan error, 6)
+	for range 2 {
+		go func() {
+			_, err := client.Read(make([]byte, 1))
+			errs <- err
+		}()
+		go func() {
+			_, err := client.Write(big)
+			errs <- err
+		}()
+		go func() {
+			_, err := l.Accept()
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	client.Close()
+	l.Close()
+	for range cap(errs) {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Error("a call blocked across Close succeeded")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Close did not unblock every call")
+		}
+	}
+}
+
+func TestUdpConcurrentSendAndReceive(t *testing.T) {
+	fake.Install(t)
+
+	server, err := ListenUdp("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenUdp: %v", err)
+	}
+	defer server.Close()
+	addr, _ := server.GetLocalAddress()
+
+	client, err := DialUdp(addr.String())
+	if err != nil {
+		t.Fatalf("DialUdp: %v", err)
+	}
+	defer client.Close()
+
+	// Fewer datagrams than the fake queues, so none are dropped.
+	const senders, datagrams = 4, 8
+	var wg sync.WaitGroup
+	for s := range senders {
+		wg.Go(func() {
+			for i := range datagrams {
+				if _, err := client.Write(fmt.Appendf(nil, "%d-%d", s, i)); err != nil {
+					t.Errorf("Write: %v", err)
+				}
+			}
+		})
+	}
+
+	received := make(chan string, senders*datagrams)
+	for range 2 {
+		go func() {
+			buf := make([]byte, 16)
+			for {
+				n, err := server.Read(buf)
+				if err != nil {
+					return
+				}
+				received <- string(buf[:n])
+			}
+		}()
+	}
+
+	wg.Wait()
+	seen := make(map[string]bool)
+	for range senders * datagrams {
+		select {
+		case d := <-received:
+			if seen[d] {
+				t.Errorf("datagram %q received twice", d)
+			}
+			seen[d] = true
+		case <-time.After(time.Second):
+			t.Fatalf("received %d of %d datagrams", len(seen), senders*datagrams)
+		}
+	}
+}
@@ -0,0 +1,526 @@
+package sockets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"pkg/internal/backend"
+	"time"
+)
+
+// A UDP socket. UdpConn implements net.PacketConn, and also net.Conn once it
+// has been connected to a remote address with Connect.
+// Methods may be called from several goroutines at once.
+type UdpConn struct {
+	inner backend.UdpSocket
+	res   *resource
+
+	// Only one receive is in flight at a time. If a read gives up because
+	// of its deadline, the pending receive is picked up by the next read so
+	// that no datagram is lost. Likewise, a send abandoned because of its
+	// deadline is waited for by the next send, so datagrams are sent in
+	// order. Concurrent reads, and concurrent writes, take turns.
+	readTurn    turn
+	pending     chan datagram
+	writeTurn   turn
+	pendingSend chan error
+
+	readDeadline  deadline
+	writeDeadline deadline
+}
+
+var (
+	_ net.PacketConn = (*UdpConn)(nil)
+	_ net.Conn       = (*UdpConn)(nil)
+)
+
+type datagram struct {
+	data []byte
+	addr netip.AddrPort
+	err  error
+}
+
+// Returned, wrapped in an *OpError, when the host rejects a datagram because
+// it exceeds the maximum size supported by the network. It matches
+// ErrDatagramTooLarge with errors.Is.
+type DatagramTooLargeError struct {
+	// The size of the rejected datagram in bytes.
+	Size int
+}
+
+func (e *DatagramTooLargeError) Error() string {
+	return fmt.Sprintf("datagram of %d bytes exceeds the maximum supported size", e.Size)
+}
+
+func (e *DatagramTooLargeError) Unwrap() error {
+	return ErrDatagramTooLarge
+}
+
+// Create a new UDP socket.
+func NewUdpSocket(af IpAddressFamily) (*UdpConn, error) {
+	family, err := toHostAddressFamily(af)
+	if err != nil {
+		return nil, &OpError{Op: "create", Net: "udp", Err: err}
+	}
+	inner, err := backend.Current().CreateUdpSocket(family)
+	if err != nil {
+		return nil, newOpError("udp", "create", netip.AddrPort{}, err)
+	}
+
+	c := &UdpConn{
+		inner:         inner,
+		res:           newResource(inner.Drop),
+		readTurn:      newTurn(),
+		writeTurn:     newTurn(),
+		readDeadline:  makeDeadline(),
+		writeDeadline: makeDeadline(),
+	}
+	trackLeaks(c, c.res, "udp-socket")
+	return c, nil
+}
+
+// Create a UDP socket bound to the provided local IP address and port.
+func ListenUdp(address string) (*UdpConn, error) {
+	return newUdpSocketFor("bind", address, (*UdpConn).Bind)
+}
+
+// Create a UDP socket connected to the provided remote IP address and port.
+func DialUdp(address string) (*UdpConn, error) {
+	return newUdpSocketFor("connect", address, (*UdpConn).Connect)
+}
+
+func newUdpSocketFor(op, address string, setup func(*UdpConn, string) error) (*UdpConn, error) {
+	ip, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return nil, &OpError{Op: op, Net: "udp", Err: err}
+	}
+
+	conn, err := NewUdpSocket(addressFamilyOf(ip.Addr()))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setup(conn, address); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Bind the socket to the provided IP address and port.
+func (c *UdpConn) Bind(address string) error {
+	ip, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &OpError{Op: "bind", Net: "udp", Err: err}
+	}
+	return c.BindSocketAddress(SocketAddress{AddrPort: ip})
+}
+
+// Bind the socket to the provided socket address, including its IPv6 flow
+// information.
+func (c *UdpConn) BindSocketAddress(addr SocketAddress) error {
+	socketAddr, err := toHostSockAddr(addr)
+	if err != nil {
+		return &OpError{Op: "bind", Net: "udp", Addr: addr.AddrPort, Err: err}
+	}
+	if !c.res.acquire() {
+		return closedError("udp", "bind")
+	}
+	defer c.res.release()
+
+	if err := c.inner.Bind(socketAddr); err != nil {
+		return newOpError("udp", "bind", addr.AddrPort, err)
+	}
+	return nil
+}
+
+// Associate the socket with a remote address. Afterwards, Read and Write only
+// exchange datagrams with that peer.
+func (c *UdpConn) Connect(address string) error {
+	ip, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &OpError{Op: "connect", Net: "udp", Err: err}
+	}
+	return c.ConnectSocketAddress(SocketAddress{AddrPort: ip})
+}
+
+// Associate the socket with the provided socket address, including its IPv6
+// flow information.
+func (c *UdpConn) ConnectSocketAddress(addr SocketAddress) error {
+	socketAddr, err := toHostSockAddr(addr)
+	if err != nil {
+		return &OpError{Op: "connect", Net: "udp", Addr: addr.AddrPort, Err: err}
+	}
+	if !c.res.acquire() {
+		return closedError("udp", "connect")
+	}
+	defer c.res.release()
+
+	if err := c.inner.Connect(socketAddr); err != nil {
+		return newOpError("udp", "connect", addr.AddrPort, err)
+	}
+	return nil
+}
+
+// Dissociate the socket from its remote address.
+func (c *UdpConn) Disconnect() error {
+	if !c.res.acquire() {
+		return closedError("udp", "disconnect")
+	}
+	defer c.res.release()
+
+	if err := c.inner.Disconnect(); err != nil {
+		return newOpError("udp", "disconnect", netip.AddrPort{}, err)
+	}
+	return nil
+}
+
+// Read a single datagram into b, returning the number of bytes copied and
+// the address of the sender. Datagrams larger than b are truncated.
+func (c *UdpConn) ReadFromAddrPort(b []byte) (int, netip.AddrPort, error) {
+	ctx := context.Background()
+	if !c.readTurn.take(ctx, &c.readDeadline, c.res) {
+		return 0, netip.AddrPort{}, turnError("udp", "receive", ctx, &c.readDeadline, c.res)
+	}
+	defer c.readTurn.give()
+
+	if c.pending == nil {
+		if isClosedChan(c.readDeadline.wait()) {
+			return 0, netip.AddrPort{}, timeoutError("udp", "receive")
+		}
+		if !c.res.acquire() {
+			return 0, netip.AddrPort{}, closedError("udp", "receive")
+		}
+
+		pending := make(chan datagram, 1)
+		c.pending = pending
+		go func() {
+			defer c.res.release()
+			pending <- c.receive()
+		}()
+	}
+
+	select {
+	case d := <-c.pending:
+		c.pending = nil
+		if d.err != nil {
+			return 0, netip.AddrPort{}, d.err
+		}
+		return copy(b, d.data), d.addr, nil
+	case <-c.readDeadline.wait():
+		return 0, netip.AddrPort{}, timeoutError("udp", "receive")
+	case <-c.res.done:
+		return 0, netip.AddrPort{}, closedError("udp", "receive")
+	}
+}
+
+func (c *UdpConn) receive() datagram {
+	data, from, err := c.inner.Receive()
+	if err != nil {
+		return datagram{err: newOpError("udp", "receive", netip.AddrPort{}, err)}
+	}
+	return datagram{data: data, addr: fromHostSockAddr(from).AddrPort}
+}
+
+// Send b as a single datagram to the provided address. An IPv4-mapped IPv6
+// address is sent to as plain IPv4 from an IPv4 socket.
+func (c *UdpConn) WriteToAddrPort(b []byte, addr netip.AddrPort) (int, error) {
+	if c.GetAddressFamily() == IpAddressFamilyIpv4 {
+		addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	}
+	socketAddr, err := toHostSockAddr(SocketAddress{AddrPort: addr})
+	if err != nil {
+		return 0, &OpError{Op: "send", Net: "udp", Addr: addr, Err: err}
+	}
+	return c.send(b, addr, &socketAddr)
+}
+
+func (c *UdpConn) send(b []byte, remote netip.AddrPort, addr *backend.IpSocketAddress) (int, error) {
+	fail := func(err *OpError) (int, error) {
+		err.Addr = remote
+		return 0, err
+	}
+
+	ctx := context.Background()
+	if !c.writeTurn.take(ctx, &c.writeDeadline, c.res) {
+		return fail(turnError("udp", "send", ctx, &c.writeDeadline, c.res))
+	}
+	defer c.writeTurn.give()
+
+	// Let an earlier send that was abandoned finish first. Its outcome is
+	// lost along with the datagram.
+	if c.pendingSend != nil {
+		select {
+		case <-c.pendingSend:
+			c.pendingSend = nil
+		case <-c.writeDeadline.wait():
+			return fail(timeoutError("udp", "send"))
+		case <-c.res.done:
+			return fail(closedError("udp", "send"))
+		}
+	}
+	if isClosedChan(c.writeDeadline.wait()) {
+		return fail(timeoutError("udp", "send"))
+	}
+	if !c.res.acquire() {
+		return fail(closedError("udp", "send"))
+	}
+
+	// The send may outlive this call if the deadline passes first, so it
+	// gets its own copy of the data.
+	data := bytes.Clone(b)
+	done := make(chan error, 1)
+	c.pendingSend = done
+	go func() {
+		defer c.res.release()
+
+		if err := c.inner.Send(data, addr); err != nil {
+			err := newOpError("udp", "send", remote, err)
+			if errors.Is(err.Err, ErrDatagramTooLarge) {
+				err.Err = &DatagramTooLargeError{Size: len(data)}
+			}
+			done <- err
+			return
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		c.pendingSend = nil
+		if err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	case <-c.writeDeadline.wait():
+		return fail(timeoutError("udp", "send"))
+	case <-c.res.done:
+		return fail(closedError("udp", "send"))
+	}
+}
+
+// Read a single datagram, implementing net.PacketConn. The returned address
+// is a *net.UDPAddr.
+func (c *UdpConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.ReadFromAddrPort(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, net.UDPAddrFromAddrPort(addr), nil
+}
+
+// Send a single datagram, implementing net.PacketConn. The address must be a
+// *net.UDPAddr.
+func (c *UdpConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, &OpError{Op: "send", Net: "udp", Err: fmt.Errorf("unsupported address type %T", addr)}
+	}
+	return c.WriteToAddrPort(b, udpAddr.AddrPort())
+}
+
+// Read a single datagram from the connected peer.
+func (c *UdpConn) Read(b []byte) (int, error) {
+	n, _, err := c.ReadFromAddrPort(b)
+	return n, err
+}
+
+// Send b as a single datagram to the connected peer.
+func (c *UdpConn) Write(b []byte) (int, error) {
+	return c.send(b, netip.AddrPort{}, nil)
+}
+
+// Close the socket. Blocked reads and writes return net.ErrClosed.
+func (c *UdpConn) Close() error {
+	c.res.close()
+	return nil
+}
+
+// Returns the local address as a *net.UDPAddr, or nil if the socket is not
+// bound.
+func (c *UdpConn) LocalAddr() net.Addr {
+	addr, err := c.GetLocalAddress()
+	if err != nil {
+		return nil
+	}
+	return net.UDPAddrFromAddrPort(addr)
+}
+
+// Returns the remote address as a *net.UDPAddr, or nil if the socket is not
+// connected.
+func (c *UdpConn) RemoteAddr() net.Addr {
+	addr, err := c.GetRemoteAddress()
+	if err != nil {
+		return nil
+	}
+	return net.UDPAddrFromAddrPort(addr)
+}
+
+// Set both the read and write deadlines.
+func (c *UdpConn) SetDeadline(t time.Time) error {
+	if !c.res.acquire() {
+		return closedError("udp", "set-deadline")
+	}
+	defer c.res.release()
+
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// Set the deadline for pending and future reads. A zero value disables it.
+func (c *UdpConn) SetReadDeadline(t time.Time) error {
+	if !c.res.acquire() {
+		return closedError("udp", "set-read-deadline")
+	}
+	defer c.res.release()
+
+	c.readDeadline.set(t)
+	return nil
+}
+
+// Set the deadline for pending and future writes. A zero value disables it.
+func (c *UdpConn) SetWriteDeadline(t time.Time) error {
+	if !c.res.acquire() {
+		return closedError("udp", "set-write-deadline")
+	}
+	defer c.res.release()
+
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// Get the bound local address.
+func (c *UdpConn) GetLocalAddress() (netip.AddrPort, error) {
+	addr, err := c.GetLocalSocketAddress()
+	return addr.AddrPort, err
+}
+
+// Get the bound local address, including its IPv6 flow information.
+func (c *UdpConn) GetLocalSocketAddress() (SocketAddress, error) {
+	if !c.res.acquire() {
+		return SocketAddress{}, closedError("udp", "get-local-address")
+	}
+	defer c.res.release()
+
+	addr, err := c.inner.GetLocalAddress()
+	if err != nil {
+		return SocketAddress{}, newOpError("udp", "get-local-address", netip.AddrPort{}, err)
+	}
+	return fromHostSockAddr(addr), nil
+}
+
+// Get the address the socket is connected to.
+func (c *UdpConn) GetRemoteAddress() (netip.AddrPort, error) {
+	addr, err := c.GetRemoteSocketAddress()
+	return addr.AddrPort, err
+}
+
+// Get the address the socket is connected to, including its IPv6 flow information.
+func (c *UdpConn) GetRemoteSocketAddress() (SocketAddress, error) {
+	if !c.res.acquire() {
+		return SocketAddress{}, closedError("udp", "get-remote-address")
+	}
+	defer c.res.release()
+
+	addr, err := c.inner.GetRemoteAddress()
+	if err != nil {
+		return SocketAddress{}, newOpError("udp", "get-remote-address", netip.AddrPort{}, err)
+	}
+	return fromHostSockAddr(addr), nil
+}
+
+// Whether this is a IPv4 or IPv6 socket. Returns 0 once the socket is closed,
+// or if the host reports a family the SDK does not know about.
+func (c *UdpConn) GetAddressFamily() IpAddressFamily {
+	if !c.res.acquire() {
+		return 0
+	}
+	defer c.res.release()
+
+	return fromHostAddressFamily(c.inner.GetAddressFamily())
+}
+
+// Equivalent to the IP_TTL & IPV6_UNICAST_HOPS socket options.
+func (c *UdpConn) GetUnicastHopLimit() (uint8, error) {
+	if !c.res.acquire() {
+		return 0, closedError("udp", "get-unicast-hop-limit")
+	}
+	defer c.res.release()
+
+	v, err := c.inner.GetUnicastHopLimit()
+	if err != nil {
+		return 0, newOpError("udp", "get-unicast-hop-limit", netip.AddrPort{}, err)
+	}
+	return v, nil
+}
+
+// Equivalent to the IP_TTL & IPV6_UNICAST_HOPS socket options.
+func (c *UdpConn) SetUnicastHopLimit(v uint8) error {
+	if !c.res.acquire() {
+		return closedError("udp", "set-unicast-hop-limit")
+	}
+	defer c.res.release()
+
+	if err := c.inner.SetUnicastHopLimit(v); err != nil {
+		return newOpError("udp", "set-unicast-hop-limit", netip.AddrPort{}, err)
+	}
+	return nil
+}
+
+// Kernel buffer space reserved for receiving on this socket.
+func (c *UdpConn) GetReceiveBufferSize() (uint64, error) {
+	if !c.res.acquire() {
+		return 0, closedError("udp", "get-receive-buffer-size")
+	}
+	defer c.res.release()
+
+	v, err := c.inner.GetReceiveBufferSize()
+	if err != nil {
+		return 0, newOpError("udp", "get-receive-buffer-size", netip.AddrPort{}, err)
+	}
+	return v, nil
+}
+
+// Kernel buffer space reserved for receiving on this socket.
+func (c *UdpConn) SetReceiveBufferSize(size uint64) error {
+	if !c.res.acquire() {
+		return closedError("udp", "set-receive-buffer-size")
+	}
+	defer c.res.release()
+
+	if err := c.inner.SetReceiveBufferSize(size); err != nil {
+		return newOpError("udp", "set-receive-buffer-size", netip.AddrPort{}, err)
+	}
+	return nil
+}
+
+// Kernel buffer space reserved for sending on this socket.
+func (c *UdpConn) GetSendBufferSize() (uint64, error) {
+	if !c.res.acquire() {
+		return 0, closedError("udp", "get-send-buffer-size")
+	}
+	defer c.res.release()
+
+	v, err := c.inner.GetSendBufferSize()
+	if err != nil {
+		return 0, newOpError("udp", "get-send-buffer-size", netip.AddrPort{}, err)
+	}
+	return v, nil
+}
+
+// Kernel buffer space reserved for sending on this socket.
+func (c *UdpConn) SetSendBufferSize(size uint64) error {
+	if !c.res.acquire() {
+		return closedError("udp", "set-send-buffer-size")
+	}
+	defer c.res.release()
+
+	if err := c.inner.SetSendBufferSize(size); err != nil {
+		return newOpError("udp", "set-send-buffer-size", netip.AddrPort{}, err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package sockets
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"pkg/fake"
+	"testing"
+	"time"
+)
+
+func TestUdpLoopback(t *testing.T) {
+	fake.Install(t)
+
+	server, err := ListenUdp("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenUdp: %v", err)
+	}
+	defer server.Close()
+
+	serverAddr, err := server.GetLocalAddress()
+	if err != nil {
+		t.Fatalf("GetLocalAddress: %v", err)
+	}
+
+	client, err := DialUdp(serverAddr.String())
+	if err != nil {
+		t.Fatalf("DialUdp: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	buf := make([]byte, 16)
+	n, from, err := server.ReadFromAddrPort(buf)
+	if err != nil {
+		t.Fatalf("ReadFromAddrPort: %v", err)
+	}
+	if string(buf[:n]) != "ping" {
+		t.Errorf("received %q, expected \"ping\"", buf[:n])
+	}
+
+	clientAddr, _ := client.GetLocalAddress()
+	if from != clientAddr {
+		t.Errorf("sender = %v, expected %v", from, clientAddr)
+	}
+
+	if _, err := server.WriteToAddrPort([]byte("pong"), from); err != nil {
+		t.Fatalf("WriteToAddrPort: %v", err)
+	}
+	n, err = client.Read(buf)
+	if err != nil || string(buf[:n]) != "pong" {
+		t.Errorf("Read = %q, %v, expected \"pong\"", buf[:n], err)
+	}
+}
+
+func TestUdpDatagramTooLarge(t *testing.T) {
+	fake.Install(t)
+
+	conn, err := DialUdp("127.0.0.1:9")
+	if err != nil {
+		t.Fatalf("DialUdp: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(make([]byte, 70000))
+
+	var tooLarge *DatagramTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Size != 70000 {
+		t.Errorf("Write error = %v, expected a *DatagramTooLargeError", err)
+	}
+	if !errors.Is(err, ErrDatagramTooLarge) {
+		t.Errorf("expected error to match ErrDatagramTooLarge")
+	}
+}
+
+func TestUdpMappedDestination(t *testing.T) {
+	fake.Install(t)
+
+	server, err := ListenUdp("[::]:0")
+	if err != nil {
+		t.Fatalf("ListenUdp: %v", err)
+	}
+	defer server.Close()
+	serverAddr, _ := server.GetLocalAddress()
+
+	client, err := NewUdpSocket(IpAddressFamilyIpv6)
+	if err != nil {
+		t.Fatalf("NewUdpSocket: %v", err)
+	}
+	defer client.Close()
+
+	// An IPv6 socket reaches IPv4 peers through mapped addresses, so they
+	// must be sent to as they are.
+	to := netip.AddrPortFrom(netip.MustParseAddr("::ffff:127.0.0.1"), serverAddr.Port())
+	if _, err := client.WriteToAddrPort([]byte("ping"), to); err != nil {
+		t.Fatalf("WriteToAddrPort: %v", err)
+	}
+	buf := make([]byte, 16)
+	if n, _, err := server.ReadFromAddrPort(buf); err != nil || string(buf[:n]) != "ping" {
+		t.Errorf("ReadFromAddrPort = %q, %v", buf[:n], err)
+	}
+}
+
+func TestUdpBadAddress(t *testing.T) {
+	fake.Install(t)
+
+	for _, test := range []struct {
+		op     string
+		create func(string) (*UdpConn, error)
+	}{
+		{"bind", ListenUdp},
+		{"connect", DialUdp},
+	} {
+		_, err := test.create("localhost:80")
+		var opErr *OpError
+		if !errors.As(err, &opErr) || opErr.Op != test.op || opErr.Net != "udp" {
+			t.Errorf("%s error = %#v, expected an *OpError", test.op, err)
+		}
+	}
+}
+
+func TestUdpDeadlineAfterClose(t *testing.T) {
+	fake.Install(t)
+
+	conn, err := NewUdpSocket(IpAddressFamilyIpv4)
+	if err != nil {
+		t.Fatalf("NewUdpSocket: %v", err)
+	}
+	conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for name, set := range map[string]func(time.Time) error{
+		"SetDeadline":      conn.SetDeadline,
+		"SetReadDeadline":  conn.SetReadDeadline,
+		"SetWriteDeadline": conn.SetWriteDeadline,
+	} {
+		if err := set(deadline); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("%s error = %v, expected net.ErrClosed", name, err)
+		}
+	}
+}
+
+func TestUdpSendDeadline(t *testing.T) {
+	fake.Install(t)
+
+	conn, err := NewUdpSocket(IpAddressFamilyIpv4)
+	if err != nil {
+		t.Fatalf("NewUdpSocket: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(-time.Second))
+	to := netip.MustParseAddrPort("127.0.0.1:9")
+	_, err = conn.WriteToAddrPort([]byte("late"), to)
+
+	var opErr *OpError
+	if !errors.As(err, &opErr) || opErr.Op != "send" || opErr.Net != "udp" || opErr.Addr != to {
+		t.Fatalf("WriteToAddrPort error = %#v, expected a send *OpError for %v", err, to)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) || !opErr.Timeout() {
+		t.Errorf("WriteToAddrPort error = %v, expected a timeout", err)
+	}
+}